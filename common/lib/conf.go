@@ -240,7 +240,10 @@ func GetIntConf(key string) int {
 	if len(keys) < 2 {
 		return 0
 	}
-	v := ViperConfMap[keys[0]]
+	v, ok := ViperConfMap[keys[0]]
+	if !ok {
+		return 0
+	}
 	conf := v.GetInt(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
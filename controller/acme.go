@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"net/http"
+)
+
+// AcmeChallenge 响应ACME服务器的HTTP-01验证, 验证内容由签发证书的节点写入redis
+func AcmeChallenge(c *gin.Context) {
+	keyAuth, err := public.AcmeStoreHandler.GetChallenge(c.Param("token"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if keyAuth == "" {
+		c.String(http.StatusNotFound, "challenge not found")
+		return
+	}
+	c.String(http.StatusOK, keyAuth)
+}
@@ -1,12 +1,14 @@
 package controller
 
 import (
+	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/common/lib"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/dto"
 	"github.com/yguilai/go-gateway/public"
+	"strings"
 	"time"
 )
 
@@ -17,6 +19,9 @@ func RegisterAppController(r *gin.RouterGroup) {
 	r.POST("", AppAdd)
 	r.PUT("", AppUpdate)
 	r.GET("/:id/stat", AppStatistics)
+	r.GET("/:id/keys", AppApiKeyList)
+	r.POST("/:id/keys", AppApiKeyAdd)
+	r.DELETE("/:id/keys/:key_id", AppApiKeyDelete)
 }
 
 // APPList godoc
@@ -60,6 +65,7 @@ func APPList(c *gin.Context) {
 			WhiteIPS: item.WhiteIPS,
 			Qpd:      item.Qpd,
 			Qps:      item.Qps,
+			TokenExpires: item.TokenExpires,
 			RealQpd:  appCounter.TotalCount,
 			RealQps:  appCounter.QPS,
 		})
@@ -96,6 +102,15 @@ func APPDetail(c *gin.Context) {
 		public.ResponseError(c, 2002, err)
 		return
 	}
+	grants, err := (&dao.AppGrant{}).ListByAppID(c, lib.GORMDefaultPool, detail.AppID)
+	if err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	for _, item := range grants {
+		tmpItem := item
+		detail.Grants = append(detail.Grants, &tmpItem)
+	}
 	public.ResponseSuccess(c, detail)
 	return
 }
@@ -129,6 +144,32 @@ func APPDelete(c *gin.Context) {
 		public.ResponseError(c, 2003, err)
 		return
 	}
+	//删除授权, 避免同名租户重建后继承
+	if err := (&dao.AppGrant{}).DeleteByAppID(c, lib.GORMDefaultPool, info.AppID); err != nil {
+		public.ResponseError(c, 2007, err)
+		return
+	}
+	//已签发的token立即失效
+	if err := public.JwtRevokeHandler.RevokeApp(info.AppID, info.UpdatedAt); err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	if err := public.JwtRevokeHandler.MarkAppUpdated(info.AppID, info.UpdatedAt); err != nil {
+		public.ResponseError(c, 2008, err)
+		return
+	}
+	apiKeys, err := (&dao.AppApiKey{}).ListByAppID(c, lib.GORMDefaultPool, info.AppID)
+	if err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	for _, item := range apiKeys {
+		tmpItem := item
+		if err := tmpItem.Revoke(c, lib.GORMDefaultPool); err != nil {
+			public.ResponseError(c, 2006, err)
+			return
+		}
+	}
 	public.ResponseSuccess(c, "")
 	return
 }
@@ -161,7 +202,7 @@ func AppAdd(c *gin.Context) {
 	if params.Secret == "" {
 		params.Secret = public.MD5(params.AppID)
 	}
-	tx := lib.GORMDefaultPool
+	tx := lib.GORMDefaultPool.Begin()
 	info := &dao.App{
 		AppID:    params.AppID,
 		Name:     params.Name,
@@ -169,11 +210,24 @@ func AppAdd(c *gin.Context) {
 		WhiteIPS: params.WhiteIPS,
 		Qps:      params.Qps,
 		Qpd:      params.Qpd,
+		TokenExpires: params.TokenExpires,
 	}
 	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
 		public.ResponseError(c, 2003, err)
 		return
 	}
+	if err := saveAppGrants(c, tx, info.AppID, params.Grants); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	tx.Commit()
+	//网关据此载入新租户
+	if err := public.JwtRevokeHandler.MarkAppUpdated(info.AppID, info.UpdatedAt); err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
 	public.ResponseSuccess(c, "")
 	return
 }
@@ -205,15 +259,43 @@ func AppUpdate(c *gin.Context) {
 	if params.Secret == "" {
 		params.Secret = public.MD5(params.AppID)
 	}
+	secretChanged := info.Secret != params.Secret
 	info.Name = params.Name
 	info.Secret = params.Secret
 	info.WhiteIPS = params.WhiteIPS
 	info.Qps = params.Qps
 	info.Qpd = params.Qpd
-	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+	info.TokenExpires = params.TokenExpires
+	tx := lib.GORMDefaultPool.Begin()
+	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
 		public.ResponseError(c, 2003, err)
 		return
 	}
+	grant := &dao.AppGrant{}
+	if err := grant.DeleteByAppID(c, tx, info.AppID); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	if err := saveAppGrants(c, tx, info.AppID, params.Grants); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	tx.Commit()
+	//更换密钥后已签发的token立即失效
+	if secretChanged {
+		if err := public.JwtRevokeHandler.RevokeApp(info.AppID, info.UpdatedAt); err != nil {
+			public.ResponseError(c, 2006, err)
+			return
+		}
+	}
+	//网关据此重新载入租户信息
+	if err := public.JwtRevokeHandler.MarkAppUpdated(info.AppID, info.UpdatedAt); err != nil {
+		public.ResponseError(c, 2007, err)
+		return
+	}
 	public.ResponseSuccess(c, "")
 	return
 }
@@ -274,3 +356,125 @@ func AppStatistics(c *gin.Context) {
 	public.ResponseSuccess(c, stat)
 	return
 }
+
+// saveAppGrants 保存租户可访问的服务
+func saveAppGrants(c *gin.Context, tx *gorm.DB, appID string, grants []dto.APPGrantInput) error {
+	for _, item := range grants {
+		grant := &dao.AppGrant{
+			AppID:       appID,
+			ServiceName: item.ServiceName,
+			Methods:     strings.ToUpper(strings.Replace(item.Methods, " ", "", -1)),
+			PathPattern: item.PathPattern,
+		}
+		if err := grant.Save(c, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppApiKeyList godoc
+// @Summary 租户api key列表
+// @Description 租户api key列表, 不返回key明文
+// @Tags 租户管理
+// @ID /apps/keys/list
+// @Accept  json
+// @Produce  json
+// @Param id path string true "租户ID"
+// @Success 200 {object} public.Response{data=[]dao.AppApiKey} "success"
+// @Router /apps/{id}/keys [get]
+func AppApiKeyList(c *gin.Context) {
+	params := &dto.APPApiKeyListInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.App{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	list, err := (&dao.AppApiKey{}).ListByAppID(c, lib.GORMDefaultPool, info.AppID)
+	if err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	public.ResponseSuccess(c, list)
+}
+
+// AppApiKeyAdd godoc
+// @Summary 租户api key添加
+// @Description 生成api key, 明文仅在此时返回一次
+// @Tags 租户管理
+// @ID /apps/keys/add
+// @Accept  json
+// @Produce  json
+// @Param id path string true "租户ID"
+// @Param body body dto.APPApiKeyAddInput true "body"
+// @Success 200 {object} public.Response{data=dto.APPApiKeyAddOutput} "success"
+// @Router /apps/{id}/keys [post]
+func AppApiKeyAdd(c *gin.Context) {
+	params := &dto.APPApiKeyAddInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	if params.ExpireAt > 0 && params.ExpireAt <= time.Now().Unix() {
+		public.ResponseError(c, 2002, errors.New("过期时间不能早于当前时间"))
+		return
+	}
+	search := &dao.App{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	key, apiKey := dao.NewAppApiKey(info.AppID, params.Name, params.ExpireAt)
+	if err := apiKey.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	public.ResponseSuccess(c, &dto.APPApiKeyAddOutput{
+		ID:        apiKey.ID,
+		Key:       key,
+		KeyPrefix: apiKey.KeyPrefix,
+		ExpireAt:  apiKey.ExpireAt,
+	})
+}
+
+// AppApiKeyDelete godoc
+// @Summary 租户api key删除
+// @Description 删除后立即失效
+// @Tags 租户管理
+// @ID /apps/keys/delete
+// @Accept  json
+// @Produce  json
+// @Param id path string true "租户ID"
+// @Param key_id path string true "key ID"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /apps/{id}/keys/{key_id} [delete]
+func AppApiKeyDelete(c *gin.Context) {
+	params := &dto.APPApiKeyDeleteInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.App{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	apiKey := &dao.AppApiKey{ID: params.KeyID, AppID: info.AppID}
+	apiKey, err = apiKey.Find(c, lib.GORMDefaultPool, apiKey)
+	if err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	if err := apiKey.Revoke(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	public.ResponseSuccess(c, "")
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/dto"
+	"github.com/yguilai/go-gateway/public"
+	"strings"
+	"time"
+)
+
+func RegisterCertController(r *gin.RouterGroup) {
+	r.GET("", CertList)
+	r.GET("/:id", CertDetail)
+	r.DELETE("/:id", CertDelete)
+	r.POST("", CertAdd)
+	r.PUT("", CertUpdate)
+}
+
+// CertList godoc
+// @Summary 证书列表
+// @Description 证书列表, 按过期时间排序, 标记即将过期的证书
+// @Tags 证书管理
+// @ID /certs
+// @Accept  json
+// @Produce  json
+// @Param info query string false "关键词"
+// @Param page_size query string true "每页多少条"
+// @Param page_no query string true "页码"
+// @Success 200 {object} public.Response{data=dto.CertListOutput} "success"
+// @Router /certs [GET]
+func CertList(c *gin.Context) {
+	params := &dto.CertListInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	list, total, err := (&dao.Cert{}).PageList(c, lib.GORMDefaultPool, params)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	now := time.Now()
+	outputList := []dto.CertListItemOutput{}
+	for _, item := range list {
+		expireDays := item.ExpireDays(now)
+		outputList = append(outputList, dto.CertListItemOutput{
+			ID:         item.ID,
+			Name:       item.Name,
+			Domains:    item.Domains,
+			NotAfter:   item.NotAfter,
+			ExpireDays: expireDays,
+			Expiring:   expireDays < public.CertExpireWarnDays,
+			IsAcme:     item.IsAcme,
+			UpdatedAt:  item.UpdatedAt,
+		})
+	}
+	expiringNum, err := (&dao.Cert{}).ExpiringCount(c, lib.GORMDefaultPool, now, public.CertExpireWarnDays)
+	if err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	public.ResponseSuccess(c, dto.CertListOutput{List: outputList, Total: total, ExpiringNum: expiringNum})
+}
+
+// CertDetail godoc
+// @Summary 证书详情
+// @Description 证书详情, 不返回私钥
+// @Tags 证书管理
+// @ID /certs/detail
+// @Accept  json
+// @Produce  json
+// @Param id path string true "证书ID"
+// @Success 200 {object} public.Response{data=dao.Cert} "success"
+// @Router /certs/{id} [get]
+func CertDetail(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{ID: params.ID}
+	detail, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if detail.IsDelete == 1 {
+		public.ResponseError(c, 2003, errors.New("证书不存在"))
+		return
+	}
+	public.ResponseSuccess(c, detail)
+}
+
+// CertDelete godoc
+// @Summary 证书删除
+// @Description 证书删除, 已绑定服务的证书不能删除, 需先解除绑定
+// @Tags 证书管理
+// @ID /certs/delete
+// @Accept  json
+// @Produce  json
+// @Param id path string true "证书ID"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /certs/{id} [delete]
+func CertDelete(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	//绑定的服务会静默回落到默认证书, 需先解除绑定
+	services, err := info.BoundServices(c, lib.GORMDefaultPool)
+	if err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	if len(services) > 0 {
+		public.ResponseError(c, 2005, errors.New("证书已绑定服务: "+strings.Join(services, ",")))
+		return
+	}
+	info.IsDelete = 1
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	public.ResponseSuccess(c, "")
+}
+
+// CertAdd godoc
+// @Summary 证书添加
+// @Description 证书添加, 域名与过期时间从证书解析
+// @Tags 证书管理
+// @ID /certs/add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertAddInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /certs [post]
+func CertAdd(c *gin.Context) {
+	params := &dto.CertAddInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	info := &dao.Cert{
+		Name:    params.Name,
+		CertPem: params.CertPem,
+		KeyPem:  params.KeyPem,
+	}
+	if _, err := info.Parse(); err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	public.ResponseSuccess(c, "")
+}
+
+// CertUpdate godoc
+// @Summary 证书更新
+// @Description 证书更新, 网关定时载入后生效, 无需重启
+// @Tags 证书管理
+// @ID /certs/update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertUpdateInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /certs [put]
+func CertUpdate(c *gin.Context) {
+	params := &dto.CertUpdateInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{ID: params.ID}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if info.IsDelete == 1 {
+		public.ResponseError(c, 2003, errors.New("证书不存在"))
+		return
+	}
+	info.Name = params.Name
+	info.CertPem = params.CertPem
+	info.KeyPem = params.KeyPem
+	if _, err := info.Parse(); err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	public.ResponseSuccess(c, "")
+}
+
+// checkHttpRuleCert 证书只能绑定到域名接入的服务, 且证书需包含该域名
+func checkHttpRuleCert(c *gin.Context, tx *gorm.DB, ruleType int, rule string, certID int64) error {
+	if certID == 0 {
+		return nil
+	}
+	if ruleType != public.HTTPRuleTypeDomain {
+		return errors.New("只有域名接入的服务可以绑定证书")
+	}
+	search := &dao.Cert{ID: certID}
+	cert, err := search.Find(c, tx, search)
+	if err != nil || cert.IsDelete == 1 {
+		return errors.New("证书不存在")
+	}
+	pair, err := cert.Parse()
+	if err != nil {
+		return err
+	}
+	if err := pair.Leaf.VerifyHostname(rule); err != nil {
+		return errors.New("证书不包含域名 " + rule)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/dto"
+	"github.com/yguilai/go-gateway/public"
+	"net"
+	"strings"
+)
+
+func RegisterIPBanController(r *gin.RouterGroup) {
+	r.GET("", IPBanList)
+	r.POST("", IPBanAdd)
+	r.DELETE("/:ip", IPBanDelete)
+}
+
+// IPBanList godoc
+// @Summary 动态封禁列表
+// @Description 自动或手动封禁且未过期的ip, 按过期时间排序
+// @Tags 封禁管理
+// @ID /ip_bans
+// @Accept  json
+// @Produce  json
+// @Param info query string false "ip关键词"
+// @Success 200 {object} public.Response{data=dto.IPBanListOutput} "success"
+// @Router /ip_bans [GET]
+func IPBanList(c *gin.Context) {
+	params := &dto.IPBanListInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	list, err := public.IPBanHandler.List()
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	outputList := []*public.IPBan{}
+	for _, item := range list {
+		if params.Info != "" && !strings.Contains(item.IP, params.Info) {
+			continue
+		}
+		outputList = append(outputList, item)
+	}
+	public.ResponseSuccess(c, dto.IPBanListOutput{List: outputList, Total: int64(len(outputList))})
+}
+
+// IPBanAdd godoc
+// @Summary 手动封禁
+// @Description 手动封禁ip, 各网关节点在同步间隔内生效
+// @Tags 封禁管理
+// @ID /ip_bans/add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.IPBanAddInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /ip_bans [post]
+func IPBanAdd(c *gin.Context) {
+	params := &dto.IPBanAddInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	reason := params.Reason
+	if reason == "" {
+		reason = "manual"
+	}
+	if err := public.IPBanHandler.Ban(net.ParseIP(params.IP).String(), reason, params.BanTime); err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	public.ResponseSuccess(c, "")
+}
+
+// IPBanDelete godoc
+// @Summary 解除封禁
+// @Description 解除封禁, 各网关节点在同步间隔内生效
+// @Tags 封禁管理
+// @ID /ip_bans/delete
+// @Accept  json
+// @Produce  json
+// @Param ip path string true "ip"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /ip_bans/{ip} [delete]
+func IPBanDelete(c *gin.Context) {
+	params := &dto.IPBanDeleteInput{}
+	if err := params.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	if err := public.IPBanHandler.Unban(net.ParseIP(params.IP).String()); err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	public.ResponseSuccess(c, "")
+}
@@ -10,17 +10,21 @@ import (
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/dto"
 	"github.com/yguilai/go-gateway/public"
+	"log"
+	"net/http"
 	"strings"
 	"time"
 )
 
 func OAuthRegister(group *gin.RouterGroup) {
 	group.POST("/tokens", Tokens)
+	group.POST("/revoke", Revoke)
+	group.GET("/jwks", Jwks)
 }
 
 // Tokens godoc
 // @Summary 获取TOKEN
-// @Description 获取TOKEN
+// @Description 获取TOKEN, grant_type 支持 client_credentials 与 refresh_token
 // @Tags OAUTH
 // @ID /oauth/tokens
 // @Accept  json
@@ -35,53 +39,191 @@ func Tokens(c *gin.Context) {
 		return
 	}
 
-	splits := strings.Split(c.GetHeader("Authorization"), " ")
-	if len(splits) != 2 {
-		public.ResponseError(c, 2001, errors.New("用户名或密码格式错误"))
+	var appInfo *dao.App
+	var usedRefreshClaims *public.JwtClaims
+	var scopes []string
+	switch params.GrantType {
+	case "client_credentials":
+		var code public.ResponseCode
+		var err error
+		if appInfo, code, err = matchOAuthApp(c); err != nil {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, code, err)
+			return
+		}
+		//token只携带租户被授权的服务
+		scopes = appInfo.IssueScopes(strings.Fields(params.Scope))
+	case "refresh_token":
+		var err error
+		usedRefreshClaims, err = public.JwtDecode(params.RefreshToken)
+		if err != nil || usedRefreshClaims.TokenType != public.JwtTokenTypeRefresh {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2007, errors.New("refresh_token无效"))
+			return
+		}
+		if revoked, err := public.JwtRevokeHandler.IsRevoked(usedRefreshClaims); err != nil || revoked {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2007, errors.New("refresh_token已失效"))
+			return
+		}
+		if appInfo, err = dao.AppManagerHandler.GetApp(usedRefreshClaims.Issuer); err != nil || appInfo == nil {
+			public.ResponseError(c, 2005, errors.New("未匹配正确APP信息"))
+			return
+		}
+		if !appInfo.AllowIP(c.ClientIP()) {
+			log.Printf(" [WARN] app %s ip %s not in white ips, path:%s\n", appInfo.AppID, c.ClientIP(), c.Request.URL.Path)
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, public.AppIPNotAllowedCode, errors.New(c.ClientIP()+" not in app white ip list"))
+			return
+		}
+		//可缩小但不能扩大原有授权范围
+		requested := usedRefreshClaims.Scopes()
+		if params.Scope != "" {
+			requested = []string{}
+			for _, scope := range strings.Fields(params.Scope) {
+				if public.InStringSlice(usedRefreshClaims.Scopes(), scope) {
+					requested = append(requested, scope)
+				}
+			}
+		}
+		if len(requested) > 0 {
+			scopes = appInfo.IssueScopes(requested)
+		}
+	default:
+		public.ResponseError(c, 2009, errors.New("不支持的grant_type"))
+		return
+	}
+	if len(scopes) == 0 {
+		public.ResponseError(c, 2006, errors.New("租户未被授权访问所申请的服务"))
 		return
 	}
+	//refresh_token只能使用一次, 校验全部通过后再原子地吊销, 校验失败时仍可重试, 并发请求只有一个能成功
+	if usedRefreshClaims != nil {
+		claimed, err := public.JwtRevokeHandler.ClaimToken(usedRefreshClaims)
+		if err != nil {
+			public.ResponseError(c, 2008, err)
+			return
+		}
+		if !claimed {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2007, errors.New("refresh_token已失效"))
+			return
+		}
+	}
 
-	appSecret, err := base64.StdEncoding.DecodeString(splits[1])
+	now := time.Now().In(lib.TimeLocation)
+	claims := public.JwtClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        public.NewJwtID(),
+			Issuer:    appInfo.AppID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Duration(appInfo.GetTokenExpires()) * time.Second).Unix(),
+		},
+		Scope: strings.Join(scopes, " "),
+	}
+	token, err := public.JwtEncode(claims)
 	if err != nil {
-		public.ResponseError(c, 2002, err)
+		public.ResponseError(c, 2004, err)
 		return
 	}
-	//fmt.Println("appSecret", string(appSecret))
+	refreshClaims := claims
+	refreshClaims.Id = public.NewJwtID()
+	refreshClaims.ExpiresAt = now.Add(time.Duration(public.JwtKeyHandler.RefreshExpires) * time.Second).Unix()
+	refreshClaims.TokenType = public.JwtTokenTypeRefresh
+	refreshToken, err := public.JwtEncode(refreshClaims)
+	if err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	output := &dto.TokensOutput{
+		ExpiresIn:    appInfo.GetTokenExpires(),
+		TokenType:    "Bearer",
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		Scope:        claims.Scope,
+	}
+	public.ResponseSuccess(c, output)
+}
 
-	//  取出 app_id secret
-	//  生成 app_list
-	//  匹配 app_id
-	//  基于 jwt生成token
-	//  生成 output
+// Revoke godoc
+// @Summary 吊销TOKEN
+// @Description 吊销租户签发的access_token或refresh_token, 无效的token同样返回成功
+// @Tags OAUTH
+// @ID /oauth/revoke
+// @Accept  json
+// @Produce  json
+// @Param body body dto.RevokeInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /oauth/revoke [post]
+func Revoke(c *gin.Context) {
+	params := &dto.RevokeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		public.ResponseError(c, 2000, err)
+		return
+	}
+	appInfo, code, err := matchOAuthApp(c)
+	if err != nil {
+		c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+		public.ResponseError(c, code, err)
+		return
+	}
+	claims, err := public.JwtDecode(params.Token)
+	if err != nil {
+		public.ResponseSuccessWithoutData(c)
+		return
+	}
+	if claims.Issuer != appInfo.AppID {
+		public.ResponseError(c, 2006, errors.New("token不属于该租户"))
+		return
+	}
+	if err := public.JwtRevokeHandler.RevokeToken(claims); err != nil {
+		public.ResponseError(c, 2007, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// matchOAuthApp 通过 Basic 认证的 app_id:secret 匹配租户
+func matchOAuthApp(c *gin.Context) (*dao.App, public.ResponseCode, error) {
+	splits := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(splits) != 2 {
+		return nil, 2001, errors.New("用户名或密码格式错误")
+	}
+
+	appSecret, err := base64.StdEncoding.DecodeString(splits[1])
+	if err != nil {
+		return nil, 2002, err
+	}
 	parts := strings.Split(string(appSecret), ":")
 	if len(parts) != 2 {
-		public.ResponseError(c, 2003, errors.New("用户名或密码格式错误"))
-		return
+		return nil, 2003, errors.New("用户名或密码格式错误")
 	}
 
-	appList := dao.AppManagerHandler.GetAppList()
-	for _, appInfo := range appList {
-		if appInfo.AppID == parts[0] && appInfo.Secret == parts[1] {
-			claims := jwt.StandardClaims{
-				Issuer:    appInfo.AppID,
-				ExpiresAt: time.Now().Add(public.JwtExpires * time.Second).In(lib.TimeLocation).Unix(),
-			}
-			token, err := public.JwtEncode(claims)
-			if err != nil {
-				public.ResponseError(c, 2004, err)
-				return
-			}
-			output := &dto.TokensOutput{
-				ExpiresIn:   public.JwtExpires,
-				TokenType:   "Bearer",
-				AccessToken: token,
-				Scope:       "read_write",
-			}
-			public.ResponseSuccess(c, output)
-			return
+	//控制台删除租户或更换密钥后, 按数据库中的最新信息校验
+	appInfo, err := dao.AppManagerHandler.GetApp(parts[0])
+	if err != nil {
+		return nil, 2005, err
+	}
+	if appInfo != nil && appInfo.Secret == parts[1] {
+		if !appInfo.AllowIP(c.ClientIP()) {
+			log.Printf(" [WARN] app %s ip %s not in white ips, path:%s\n", appInfo.AppID, c.ClientIP(), c.Request.URL.Path)
+			return nil, public.AppIPNotAllowedCode, errors.New(c.ClientIP() + " not in app white ip list")
 		}
+		return appInfo, 0, nil
 	}
-	public.ResponseError(c, 2005, errors.New("未匹配正确APP信息"))
+	return nil, 2005, errors.New("未匹配正确APP信息")
+}
+
+// Jwks godoc
+// @Summary 获取JWKS
+// @Description 网关签发token的公钥, 供下游自行验证, 按标准格式直接返回
+// @Tags OAUTH
+// @ID /oauth/jwks
+// @Produce  json
+// @Success 200 {object} dto.JwksOutput "success"
+// @Router /oauth/jwks [get]
+func Jwks(c *gin.Context) {
+	c.JSON(http.StatusOK, &dto.JwksOutput{Keys: public.JwtKeyHandler.Jwks()})
 }
 
 // AdminLogin godoc
@@ -2,12 +2,15 @@ package controller
 
 import (
 	"fmt"
+	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/common/lib"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/dto"
+	"github.com/yguilai/go-gateway/geoip"
 	"github.com/yguilai/go-gateway/public"
+	"io/ioutil"
 	"strings"
 	"time"
 )
@@ -24,6 +27,13 @@ func RegisterService(r *gin.RouterGroup) {
 	r.PUT("/tcp", ServiceUpdateTCP)
 	r.POST("/grpc", ServiceAddGRPC)
 	r.PUT("/grpc", ServiceUpdateGRPC)
+	r.POST("/grpc/descriptor", ServiceGrpcDescriptorUpload)
+	r.POST("/http/body_schema", ServiceHttpBodySchemaUpload)
+	r.DELETE("/http/body_schema/:id", ServiceHttpBodySchemaDelete)
+	r.POST("/oidc", ServiceOidcSave)
+	r.POST("/mtls", ServiceMtlsSave)
+	r.POST("/mirror", ServiceMirrorSave)
+	r.GET("/:id/mirror_stat", ServiceMirrorStat)
 }
 
 // ServiceList godoc
@@ -274,10 +284,9 @@ func ServiceAddHTTP(c *gin.Context) {
 		return
 	}
 
-	httpRule := &dao.HttpRule{RuleType: p.RuleType, Rule: p.Rule}
-	if _, err := httpRule.Find(c, tx, httpRule); err == nil {
+	if err := checkHttpRuleUnique(c, tx, 0, p.RuleType, p.Rule, p.GeoCountry); err != nil {
 		tx.Rollback()
-		public.ResponseError(c, 2010, errors.New("接入的前缀或域名已存在"))
+		public.ResponseError(c, 2010, err)
 		return
 	}
 
@@ -287,6 +296,12 @@ func ServiceAddHTTP(c *gin.Context) {
 		return
 	}
 
+	if err := checkHttpRuleCert(c, tx, p.RuleType, p.Rule, p.CertID); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2016, err)
+		return
+	}
+
 	s := &dao.ServiceInfo{
 		ServiceName: p.ServiceName,
 		ServiceDesc: p.ServiceDesc,
@@ -307,6 +322,18 @@ func ServiceAddHTTP(c *gin.Context) {
 		NeedStripUri:   p.NeedStripUri,
 		UrlRewrite:     p.UrlRewrite,
 		HeaderTransfor: p.HeaderTransfor,
+		CertID:         p.CertID,
+
+		ForceHttps:            p.ForceHttps,
+		HstsMaxAge:            p.HstsMaxAge,
+		HstsIncludeSubdomains: p.HstsIncludeSubdomains,
+		HstsPreload:           p.HstsPreload,
+		ForwardedPolicy:       p.ForwardedPolicy,
+		GeoCountry:            p.GeoCountry,
+		MaxBodySize:           p.MaxBodySize,
+		AllowContentType:      p.AllowContentType,
+		ResponseRewrite:       p.ResponseRewrite,
+		RewriteLocation:       p.RewriteLocation,
 	}
 	if err := httpR.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -317,10 +344,15 @@ func ServiceAddHTTP(c *gin.Context) {
 	ac := &dao.AccessControl{
 		ServiceID:         s.ID,
 		OpenAuth:          p.OpenAuth,
+		OpenSign:          p.OpenSign,
 		BlackList:         p.BlackList,
 		WhiteList:         p.WhiteList,
 		ClientIPFlowLimit: p.ClientipFlowLimit,
 		ServiceFlowLimit:  p.ServiceFlowLimit,
+		GeoAllowCountry:   p.GeoAllowCountry,
+		GeoDenyCountry:    p.GeoDenyCountry,
+		GeoAllowAsn:       p.GeoAllowAsn,
+		GeoDenyAsn:        p.GeoDenyAsn,
 	}
 	if err := ac.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -337,6 +369,17 @@ func ServiceAddHTTP(c *gin.Context) {
 		UpstreamHeaderTimeout:  p.UpstreamHeaderTimeout,
 		UpstreamIdleTimeout:    p.UpstreamIdleTimeout,
 		UpstreamMaxIdle:        p.UpstreamMaxIdle,
+		UpstreamTLSCaCert:      p.UpstreamTLSCaCert,
+		UpstreamTLSCert:        p.UpstreamTLSCert,
+		UpstreamTLSKey:         p.UpstreamTLSKey,
+		UpstreamTLSServerName:  p.UpstreamTLSServerName,
+		UpstreamTLSInsecure:    p.UpstreamTLSInsecure,
+		UpstreamH2c:            p.UpstreamH2c,
+	}
+	if err := checkUpstreamTLS(lb, httpR); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2017, err)
+		return
 	}
 
 	if err := lb.Save(c, tx); err != nil {
@@ -407,6 +450,27 @@ func ServiceUpdateHTTP(c *gin.Context) {
 	httpRule.NeedWebsocket = p.NeedWebsocket
 	httpRule.UrlRewrite = p.UrlRewrite
 	httpRule.HeaderTransfor = p.HeaderTransfor
+	if err := checkHttpRuleCert(c, tx, httpRule.RuleType, httpRule.Rule, p.CertID); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2009, err)
+		return
+	}
+	httpRule.CertID = p.CertID
+	httpRule.ForceHttps = p.ForceHttps
+	httpRule.HstsMaxAge = p.HstsMaxAge
+	httpRule.HstsIncludeSubdomains = p.HstsIncludeSubdomains
+	httpRule.HstsPreload = p.HstsPreload
+	httpRule.ForwardedPolicy = p.ForwardedPolicy
+	httpRule.GeoCountry = p.GeoCountry
+	httpRule.MaxBodySize = p.MaxBodySize
+	httpRule.AllowContentType = p.AllowContentType
+	httpRule.ResponseRewrite = p.ResponseRewrite
+	httpRule.RewriteLocation = p.RewriteLocation
+	if err := checkHttpRuleUnique(c, tx, httpRule.ServiceID, httpRule.RuleType, httpRule.Rule, httpRule.GeoCountry); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2011, err)
+		return
+	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		public.ResponseError(c, 2006, err)
@@ -415,10 +479,15 @@ func ServiceUpdateHTTP(c *gin.Context) {
 
 	ac := detail.AccessControl
 	ac.OpenAuth = p.OpenAuth
+	ac.OpenSign = p.OpenSign
 	ac.BlackList = p.BlackList
 	ac.WhiteList = p.WhiteList
 	ac.ClientIPFlowLimit = p.ClientipFlowLimit
 	ac.ServiceFlowLimit = p.ServiceFlowLimit
+	ac.GeoAllowCountry = p.GeoAllowCountry
+	ac.GeoDenyCountry = p.GeoDenyCountry
+	ac.GeoAllowAsn = p.GeoAllowAsn
+	ac.GeoDenyAsn = p.GeoDenyAsn
 	if err := ac.Save(c, tx); err != nil {
 		tx.Rollback()
 		public.ResponseError(c, 2007, err)
@@ -433,6 +502,19 @@ func ServiceUpdateHTTP(c *gin.Context) {
 	lb.UpstreamHeaderTimeout = p.UpstreamHeaderTimeout
 	lb.UpstreamIdleTimeout = p.UpstreamIdleTimeout
 	lb.UpstreamMaxIdle = p.UpstreamMaxIdle
+	lb.UpstreamTLSCaCert = p.UpstreamTLSCaCert
+	lb.UpstreamTLSCert = p.UpstreamTLSCert
+	if p.UpstreamTLSKey != "" || p.UpstreamTLSCert == "" {
+		lb.UpstreamTLSKey = p.UpstreamTLSKey
+	}
+	lb.UpstreamTLSServerName = p.UpstreamTLSServerName
+	lb.UpstreamTLSInsecure = p.UpstreamTLSInsecure
+	lb.UpstreamH2c = p.UpstreamH2c
+	if err := checkUpstreamTLS(lb, httpRule); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2010, err)
+		return
+	}
 	if err := lb.Save(c, tx); err != nil {
 		tx.Rollback()
 		public.ResponseError(c, 2008, err)
@@ -524,6 +606,10 @@ func ServiceAddTCP(c *gin.Context) {
 		WhiteHostName:     p.WhiteHostName,
 		ClientIPFlowLimit: p.ClientIPFlowLimit,
 		ServiceFlowLimit:  p.ServiceFlowLimit,
+		GeoAllowCountry:   p.GeoAllowCountry,
+		GeoDenyCountry:    p.GeoDenyCountry,
+		GeoAllowAsn:       p.GeoAllowAsn,
+		GeoDenyAsn:        p.GeoDenyAsn,
 	}
 	if err := ac.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -612,6 +698,10 @@ func ServiceUpdateTCP(c *gin.Context) {
 	ac.WhiteHostName = p.WhiteHostName
 	ac.ClientIPFlowLimit = p.ClientIPFlowLimit
 	ac.ServiceFlowLimit = p.ServiceFlowLimit
+	ac.GeoAllowCountry = p.GeoAllowCountry
+	ac.GeoDenyCountry = p.GeoDenyCountry
+	ac.GeoAllowAsn = p.GeoAllowAsn
+	ac.GeoDenyAsn = p.GeoDenyAsn
 	if err := ac.Save(c, tx); err != nil {
 		tx.Rollback()
 		public.ResponseError(c, 2006, err)
@@ -683,6 +773,11 @@ func ServiceAddGRPC(c *gin.Context) {
 		return
 	}
 
+	if err := checkGrpcWebPrefix(c, 0, p.WebPrefix); err != nil {
+		public.ResponseError(c, 2011, err)
+		return
+	}
+
 	tx := lib.GORMDefaultPool.Begin()
 	info := &dao.ServiceInfo{
 		LoadType:    public.LoadTypeGRPC,
@@ -716,6 +811,10 @@ func ServiceAddGRPC(c *gin.Context) {
 		WhiteHostName:     p.WhiteHostName,
 		ClientIPFlowLimit: p.ClientIPFlowLimit,
 		ServiceFlowLimit:  p.ServiceFlowLimit,
+		GeoAllowCountry:   p.GeoAllowCountry,
+		GeoDenyCountry:    p.GeoDenyCountry,
+		GeoAllowAsn:       p.GeoAllowAsn,
+		GeoDenyAsn:        p.GeoDenyAsn,
 	}
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -727,6 +826,7 @@ func ServiceAddGRPC(c *gin.Context) {
 		ServiceID:      info.ID,
 		Port:           p.Port,
 		HeaderTransfor: p.HeaderTransfor,
+		WebPrefix:      p.WebPrefix,
 	}
 	if err := grpcRule.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -734,6 +834,12 @@ func ServiceAddGRPC(c *gin.Context) {
 		return
 	}
 
+	if err := saveGrpcMethodRules(c, tx, info.ID, p.MethodRules); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2010, err)
+		return
+	}
+
 	tx.Commit()
 	public.ResponseSuccessWithoutData(c)
 }
@@ -761,6 +867,11 @@ func ServiceUpdateGRPC(c *gin.Context) {
 		return
 	}
 
+	if err := checkGrpcWebPrefix(c, p.ID, p.WebPrefix); err != nil {
+		public.ResponseError(c, 2010, err)
+		return
+	}
+
 	tx := lib.GORMDefaultPool.Begin()
 
 	service := &dao.ServiceInfo{
@@ -802,12 +913,25 @@ func ServiceUpdateGRPC(c *gin.Context) {
 	grpcRule.ServiceID = info.ID
 	//grpcRule.Port = p.Port
 	grpcRule.HeaderTransfor = p.HeaderTransfor
+	grpcRule.WebPrefix = p.WebPrefix
 	if err := grpcRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		public.ResponseError(c, 2006, err)
 		return
 	}
 
+	methodRule := &dao.GrpcMethodRule{}
+	if err := methodRule.DeleteByServiceID(c, tx, info.ID); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2008, err)
+		return
+	}
+	if err := saveGrpcMethodRules(c, tx, info.ID, p.MethodRules); err != nil {
+		tx.Rollback()
+		public.ResponseError(c, 2009, err)
+		return
+	}
+
 	ac := &dao.AccessControl{}
 	if detail.AccessControl != nil {
 		ac = detail.AccessControl
@@ -819,6 +943,10 @@ func ServiceUpdateGRPC(c *gin.Context) {
 	ac.WhiteHostName = p.WhiteHostName
 	ac.ClientIPFlowLimit = p.ClientIPFlowLimit
 	ac.ServiceFlowLimit = p.ServiceFlowLimit
+	ac.GeoAllowCountry = p.GeoAllowCountry
+	ac.GeoDenyCountry = p.GeoDenyCountry
+	ac.GeoAllowAsn = p.GeoAllowAsn
+	ac.GeoDenyAsn = p.GeoDenyAsn
 	if err := ac.Save(c, tx); err != nil {
 		tx.Rollback()
 		public.ResponseError(c, 2007, err)
@@ -827,3 +955,428 @@ func ServiceUpdateGRPC(c *gin.Context) {
 	tx.Commit()
 	public.ResponseSuccessWithoutData(c)
 }
+
+// saveGrpcMethodRules 保存grpc方法级规则
+func saveGrpcMethodRules(c *gin.Context, tx *gorm.DB, serviceID int64, rules []dto.ServiceGrpcMethodRuleInput) error {
+	for _, item := range rules {
+		if len(strings.Split(item.IpList, ",")) != len(strings.Split(item.WeightList, ",")) {
+			return errors.New(fmt.Sprintf("%s ip列表与权重设置不匹配", item.Method))
+		}
+		rule := &dao.GrpcMethodRule{
+			ServiceID:  serviceID,
+			Method:     item.Method,
+			Forbid:     item.Forbid,
+			OpenAuth:   item.OpenAuth,
+			FlowLimit:  item.FlowLimit,
+			RoundType:  item.RoundType,
+			IpList:     item.IpList,
+			WeightList: item.WeightList,
+		}
+		if err := rule.Save(c, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGrpcWebPrefix gRPC-Web接入前缀不能与http服务或其他grpc服务重复
+func checkGrpcWebPrefix(c *gin.Context, serviceID int64, webPrefix string) error {
+	if webPrefix == "" {
+		return nil
+	}
+	httpRule := &dao.HttpRule{RuleType: public.HTTPRuleTypePrefixURL, Rule: webPrefix}
+	if _, err := httpRule.Find(c, lib.GORMDefaultPool, httpRule); err == nil {
+		return errors.New("接入前缀已存在")
+	}
+	grpcRule := &dao.GrpcRule{WebPrefix: webPrefix}
+	if rule, err := grpcRule.Find(c, lib.GORMDefaultPool, grpcRule); err == nil && rule.ServiceID != serviceID {
+		return errors.New("接入前缀已存在")
+	}
+	return nil
+}
+
+// ServiceGrpcDescriptorUpload godoc
+// @Summary grpc描述文件上传
+// @Description 上传 protoc --include_imports --descriptor_set_out 生成的描述文件, 用于JSON转码
+// @Tags 服务管理
+// @ID /services/grpc/descriptor
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id formData int true "服务ID"
+// @Param descriptor formData file true "描述文件"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /services/grpc/descriptor [POST]
+func ServiceGrpcDescriptorUpload(c *gin.Context) {
+	p := &dto.ServiceGrpcDescriptorInput{}
+	if err := p.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+
+	service := &dao.ServiceInfo{ID: p.ID}
+	detail, err := service.ServiceDetail(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if detail.Info.LoadType != public.LoadTypeGRPC {
+		public.ResponseError(c, 2003, errors.New("仅grpc服务支持上传描述文件"))
+		return
+	}
+
+	fileHeader, err := c.FormFile("descriptor")
+	if err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		public.ResponseError(c, 2006, err)
+		return
+	}
+
+	descriptor := &dao.GrpcDescriptor{}
+	if detail.GRPCDescriptor != nil {
+		descriptor = detail.GRPCDescriptor
+	}
+	descriptor.ServiceID = detail.Info.ID
+	descriptor.FileName = fileHeader.Filename
+	descriptor.Content = content
+	//保存前校验描述文件可以解析
+	if _, err := descriptor.ParseFiles(); err != nil {
+		public.ResponseError(c, 2007, err)
+		return
+	}
+	if err := descriptor.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2008, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// ServiceHttpBodySchemaUpload godoc
+// @Summary http请求体schema上传
+// @Description 上传JSON Schema文件, 按请求方法与路径校验请求体; 相同方法与路径的schema被覆盖
+// @Tags 服务管理
+// @ID /services/http/body_schema
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id formData int true "服务ID"
+// @Param method formData string true "请求方法, *=全部"
+// @Param path formData string true "路径匹配规则, 支持末尾*通配"
+// @Param schema formData file true "JSON Schema文件"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /services/http/body_schema [POST]
+func ServiceHttpBodySchemaUpload(c *gin.Context) {
+	p := &dto.ServiceHttpBodySchemaInput{}
+	if err := p.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+
+	service := &dao.ServiceInfo{ID: p.ID}
+	detail, err := service.ServiceDetail(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if detail.Info.LoadType != public.LoadTypeHTTP {
+		public.ResponseError(c, 2003, errors.New("仅http服务支持请求体校验"))
+		return
+	}
+
+	fileHeader, err := c.FormFile("schema")
+	if err != nil {
+		public.ResponseError(c, 2004, err)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		public.ResponseError(c, 2006, err)
+		return
+	}
+
+	schema := &dao.HttpBodySchema{}
+	for _, item := range detail.HTTPBodySchemas {
+		if item.Method == p.Method && item.Path == p.Path {
+			schema = item
+			break
+		}
+	}
+	schema.ServiceID = detail.Info.ID
+	schema.Method = p.Method
+	schema.Path = p.Path
+	schema.FileName = fileHeader.Filename
+	schema.Schema = string(content)
+	//保存前校验schema可以解析
+	if _, err := schema.Compile(); err != nil {
+		public.ResponseError(c, 2007, err)
+		return
+	}
+	if err := schema.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2008, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// ServiceHttpBodySchemaDelete godoc
+// @Summary http请求体schema删除
+// @Description http请求体schema删除
+// @Tags 服务管理
+// @ID /services/http/body_schema/delete
+// @Accept  json
+// @Produce  json
+// @Param id path string true "schema ID"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /services/http/body_schema/{id} [delete]
+func ServiceHttpBodySchemaDelete(c *gin.Context) {
+	p := &dto.ServiceHttpBodySchemaDeleteInput{}
+	if err := p.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	schema := &dao.HttpBodySchema{ID: p.ID}
+	if err := schema.Delete(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// ServiceOidcSave godoc
+// @Summary 外部身份提供方鉴权配置
+// @Description 开启后使用外部身份提供方签发的token鉴权, 支持http与grpc服务
+// @Tags 服务管理
+// @ID /services/oidc
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceOidcInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /services/oidc [POST]
+func ServiceOidcSave(c *gin.Context) {
+	p := &dto.ServiceOidcInput{}
+	if err := p.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	if p.Enable == 1 && p.Issuer == "" {
+		public.ResponseError(c, 2002, errors.New("开启时签发方不能为空"))
+		return
+	}
+
+	service := &dao.ServiceInfo{ID: p.ID}
+	detail, err := service.ServiceDetail(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		public.ResponseError(c, 2003, err)
+		return
+	}
+	if detail.Info.LoadType == public.LoadTypeTCP {
+		public.ResponseError(c, 2004, errors.New("tcp服务不支持token鉴权"))
+		return
+	}
+
+	oidcRule := detail.OidcRule
+	oidcRule.ServiceID = detail.Info.ID
+	oidcRule.Enable = p.Enable
+	oidcRule.Issuer = p.Issuer
+	oidcRule.JwksURL = p.JwksURL
+	oidcRule.Audience = p.Audience
+	oidcRule.RequiredClaims = p.RequiredClaims
+	oidcRule.AppClaim = p.AppClaim
+	oidcRule.ForwardClaims = p.ForwardClaims
+	if err := oidcRule.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// ServiceMtlsSave godoc
+// @Summary 客户端证书鉴权配置
+// @Description 开启后要求客户端证书由配置的CA签发, 支持http与grpc服务
+// @Tags 服务管理
+// @ID /services/mtls
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceMtlsInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /services/mtls [POST]
+func ServiceMtlsSave(c *gin.Context) {
+	p := &dto.ServiceMtlsInput{}
+	if err := p.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+
+	service := &dao.ServiceInfo{ID: p.ID}
+	detail, err := service.ServiceDetail(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if detail.Info.LoadType == public.LoadTypeTCP {
+		public.ResponseError(c, 2003, errors.New("tcp服务不支持客户端证书鉴权"))
+		return
+	}
+
+	mtlsRule := detail.MtlsRule
+	mtlsRule.ServiceID = detail.Info.ID
+	mtlsRule.Enable = p.Enable
+	mtlsRule.CaCert = p.CaCert
+	mtlsRule.AppField = p.AppField
+	if p.Enable == 1 {
+		if _, err := (&dao.MtlsRule{CaCert: p.CaCert}).CertPool(); err != nil {
+			public.ResponseError(c, 2004, err)
+			return
+		}
+	}
+	if err := mtlsRule.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2005, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// ServiceMirrorSave godoc
+// @Summary 流量复制配置
+// @Description 按比例将http服务的请求异步复制到影子节点组, 影子响应丢弃, 不影响正常请求
+// @Tags 服务管理
+// @ID /services/mirror
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceMirrorInput true "body"
+// @Success 200 {object} public.Response{data=string} "success"
+// @Router /services/mirror [POST]
+func ServiceMirrorSave(c *gin.Context) {
+	p := &dto.ServiceMirrorInput{}
+	if err := p.GetValidParams(c); err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+
+	service := &dao.ServiceInfo{ID: p.ID}
+	detail, err := service.ServiceDetail(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+	if detail.Info.LoadType != public.LoadTypeHTTP {
+		public.ResponseError(c, 2003, errors.New("仅http服务支持流量复制"))
+		return
+	}
+	if p.Enable == 1 && p.IpList == "" {
+		public.ResponseError(c, 2004, errors.New("开启流量复制时影子节点列表不能为空"))
+		return
+	}
+	if len(strings.Split(p.IpList, ",")) != len(strings.Split(p.WeightList, ",")) {
+		public.ResponseError(c, 2005, errors.New("影子节点列表与权重列表数量不一致"))
+		return
+	}
+
+	mirrorRule := detail.MirrorRule
+	mirrorRule.ServiceID = detail.Info.ID
+	mirrorRule.Enable = p.Enable
+	mirrorRule.Percent = p.Percent
+	mirrorRule.RoundType = p.RoundType
+	mirrorRule.IpList = p.IpList
+	mirrorRule.WeightList = p.WeightList
+	mirrorRule.Timeout = p.Timeout
+	mirrorRule.MaxBodySize = p.MaxBodySize
+	if err := mirrorRule.Save(c, lib.GORMDefaultPool); err != nil {
+		public.ResponseError(c, 2006, err)
+		return
+	}
+	public.ResponseSuccessWithoutData(c)
+}
+
+// ServiceMirrorStat godoc
+// @Summary 流量复制统计
+// @Description 今日影子请求的数量、失败数与和正常请求的差异, 统计由代理定时写入, 存在数秒延迟
+// @Tags 服务管理
+// @ID /services/:id/mirror_stat
+// @Accept  json
+// @Produce  json
+// @Param id path string true "服务ID"
+// @Success 200 {object} public.Response{data=dto.ServiceMirrorStatOutput} "success"
+// @Router /services/{id}/mirror_stat [GET]
+func ServiceMirrorStat(c *gin.Context) {
+	p := &dto.ServiceDeleteInput{}
+	if err := p.BindValidParam(c); err != nil {
+		public.ResponseError(c, 2000, err)
+		return
+	}
+
+	service := &dao.ServiceInfo{ID: p.ID}
+	service, err := service.Find(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		public.ResponseError(c, 2001, err)
+		return
+	}
+	stat, err := public.GetMirrorDayStat(service.ServiceName, time.Now())
+	if err != nil {
+		public.ResponseError(c, 2002, err)
+		return
+	}
+
+	out := &dto.ServiceMirrorStatOutput{
+		Total:      stat[public.MirrorStatTotal],
+		Error:      stat[public.MirrorStatError],
+		Dropped:    stat[public.MirrorStatDropped],
+		Skipped:    stat[public.MirrorStatSkipped],
+		Compared:   stat[public.MirrorStatCompared],
+		StatusDiff: stat[public.MirrorStatStatusDiff],
+	}
+	if out.Compared > 0 {
+		out.AvgLatencyDiff = stat[public.MirrorStatLatencyDiff] / out.Compared
+	}
+	public.ResponseSuccess(c, out)
+}
+
+// checkHttpRuleUnique 相同的前缀或域名只能有一个不带国家条件的服务, 带国家条件的服务之间国家不能重叠
+func checkHttpRuleUnique(c *gin.Context, tx *gorm.DB, serviceID int64, ruleType int, rule, geoCountry string) error {
+	var list []dao.HttpRule
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where("rule_type = ? and rule = ? and service_id <> ?", ruleType, rule, serviceID).Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	countries, _ := geoip.ParseCountries(geoCountry)
+	for _, item := range list {
+		if item.GeoCountry == "" && geoCountry == "" {
+			return errors.New("接入的前缀或域名已存在")
+		}
+		others, _ := geoip.ParseCountries(item.GeoCountry)
+		for country := range countries {
+			if others[country] {
+				return errors.New("接入的前缀或域名已存在国家条件 " + country)
+			}
+		}
+	}
+	return nil
+}
+
+// checkUpstreamTLS 校验下游tls配置, h2c只能用于http下游且不支持websocket
+func checkUpstreamTLS(lb *dao.LoadBalance, httpRule *dao.HttpRule) error {
+	if lb.UpstreamH2c == 1 && httpRule.NeedHttps == 1 {
+		return errors.New("h2c仅用于http下游, https下游会自动协商http2")
+	}
+	if lb.UpstreamH2c == 1 && httpRule.NeedWebsocket == 1 {
+		return errors.New("h2c不支持websocket")
+	}
+	if _, err := lb.UpstreamTLSConfig(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package dao
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/public"
+	"golang.org/x/crypto/acme"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// AcmeAccount ACME账户, 保存在数据库中供所有网关节点共用
+type AcmeAccount struct {
+	ID           int64     `json:"id" gorm:"primary_key"`
+	DirectoryURL string    `json:"directory_url" gorm:"column:directory_url" description:"ACME目录地址"`
+	Email        string    `json:"email" gorm:"column:email" description:"联系邮箱"`
+	KeyPem       string    `json:"-" gorm:"column:key_pem" description:"PEM格式账户私钥"`
+	CreatedAt    time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+}
+
+func (t *AcmeAccount) TableName() string {
+	return "gateway_acme_account"
+}
+
+func (t *AcmeAccount) Find(c *gin.Context, tx *gorm.DB, search *AcmeAccount) (*AcmeAccount, error) {
+	model := &AcmeAccount{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *AcmeAccount) Save(c *gin.Context, tx *gorm.DB) error {
+	return tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error
+}
+
+var AcmeManagerHandler *AcmeManager
+
+// AcmeManager 为开启https或强制https的域名接入服务自动签发与续期证书
+// 证书保存为 Cert 并绑定到服务, 各节点通过 CertManager 定时载入
+// 未绑定证书, 或绑定的是ACME签发证书的服务才会自动签发, 手动上传的证书不受影响
+type AcmeManager struct {
+	Enable        bool
+	DirectoryURL  string
+	Email         string
+	RenewDays     int
+	CheckInterval time.Duration
+	HTTPClient    *http.Client
+	Client        *acme.Client
+	Locker        sync.Mutex
+	stop          chan struct{}
+}
+
+func NewAcmeManager() *AcmeManager {
+	return &AcmeManager{
+		DirectoryURL:  acme.LetsEncryptURL,
+		RenewDays:     public.CertExpireWarnDays,
+		CheckInterval: time.Hour,
+		HTTPClient:    http.DefaultClient,
+		Locker:        sync.Mutex{},
+		stop:          make(chan struct{}),
+	}
+}
+
+func init() {
+	AcmeManagerHandler = NewAcmeManager()
+}
+
+// LoadConf 从 acme.toml 载入配置, 未配置时不开启
+func (s *AcmeManager) LoadConf() {
+	if _, ok := lib.ViperConfMap["acme"]; !ok {
+		return
+	}
+	s.Enable = lib.GetBoolConf("acme.base.enable")
+	if directoryURL := lib.GetStringConf("acme.base.directory_url"); directoryURL != "" {
+		s.DirectoryURL = directoryURL
+	}
+	s.Email = lib.GetStringConf("acme.base.email")
+	if renewDays := lib.GetIntConf("acme.base.renew_days"); renewDays > 0 {
+		s.RenewDays = renewDays
+	}
+	if checkInterval := lib.GetIntConf("acme.base.check_interval"); checkInterval > 0 {
+		s.CheckInterval = time.Duration(checkInterval) * time.Second
+	}
+	if lib.GetBoolConf("acme.base.insecure_skip_verify") {
+		//pebble等本地测试服务使用自签名证书
+		s.HTTPClient = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+}
+
+// client 使用数据库中的账户, 不存在时创建并注册
+func (s *AcmeManager) client(ctx context.Context, c *gin.Context, tx *gorm.DB) (*acme.Client, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if s.Client != nil {
+		return s.Client, nil
+	}
+	search := &AcmeAccount{DirectoryURL: s.DirectoryURL}
+	account, err := search.Find(c, tx, search)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	var key crypto.Signer
+	if err == gorm.ErrRecordNotFound {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		keyDer, err := x509.MarshalECPrivateKey(ecKey)
+		if err != nil {
+			return nil, err
+		}
+		key = ecKey
+		account = &AcmeAccount{
+			DirectoryURL: s.DirectoryURL,
+			Email:        s.Email,
+			KeyPem:       string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})),
+		}
+	} else {
+		block, _ := pem.Decode([]byte(account.KeyPem))
+		if block == nil {
+			return nil, errors.New("acme account key invalid")
+		}
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, errors.WithMessage(err, "parse acme account key")
+		}
+	}
+	client := &acme.Client{Key: key, DirectoryURL: s.DirectoryURL, HTTPClient: s.HTTPClient}
+	acct := &acme.Account{}
+	if s.Email != "" {
+		acct.Contact = []string{"mailto:" + s.Email}
+	}
+	if _, err := client.Register(ctx, acct, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, errors.WithMessage(err, "register acme account")
+	}
+	if account.ID == 0 {
+		if err := account.Save(c, tx); err != nil {
+			return nil, err
+		}
+	}
+	s.Client = client
+	return client, nil
+}
+
+// Issue 通过HTTP-01验证签发证书, 返回PEM格式的证书链与私钥
+func (s *AcmeManager) Issue(ctx context.Context, client *acme.Client, domain string) (string, string, error) {
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return "", "", errors.WithMessage(err, "acme authorize order")
+	}
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return "", "", err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var chal *acme.Challenge
+		for _, item := range authz.Challenges {
+			if item.Type == "http-01" {
+				chal = item
+				break
+			}
+		}
+		if chal == nil {
+			return "", "", errors.New("acme http-01 challenge not offered for " + domain)
+		}
+		keyAuth, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return "", "", err
+		}
+		if err := public.AcmeStoreHandler.SetChallenge(chal.Token, keyAuth, 3600); err != nil {
+			return "", "", err
+		}
+		if _, err := client.Accept(ctx, chal); err != nil {
+			return "", "", errors.WithMessage(err, "acme accept challenge")
+		}
+		if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+			return "", "", errors.WithMessage(err, "acme wait authorization")
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return "", "", errors.WithMessage(err, "acme wait order")
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}, certKey)
+	if err != nil {
+		return "", "", err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", errors.WithMessage(err, "acme create cert")
+	}
+	certPem := []byte{}
+	for _, der := range chain {
+		certPem = append(certPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return "", "", err
+	}
+	return string(certPem), string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})), nil
+}
+
+// 续期锁的有效期, 需大于单个域名的签发超时, 每签发一个域名前延长一次
+const (
+	acmeRenewLockExpires = 600
+	acmeIssueTimeout     = 5 * time.Minute
+)
+
+func newAcmeLockOwner() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Renew 检查全部域名接入服务, 签发缺少的证书并续期即将过期的证书
+// 多个节点同时检查时, 只有取得锁的节点执行, 锁被其他节点取得后停止本轮签发
+func (s *AcmeManager) Renew(ctx context.Context) error {
+	owner := newAcmeLockOwner()
+	locked, err := public.AcmeStoreHandler.Lock("renew", owner, acmeRenewLockExpires)
+	if err != nil || !locked {
+		return err
+	}
+	defer public.AcmeStoreHandler.Unlock("renew", owner)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	var rules []HttpRule
+	err = tx.SetCtx(public.GetGinTraceContext(c)).Table((&HttpRule{}).TableName()+" a").Select("a.*").
+		Joins("join "+(&ServiceInfo{}).TableName()+" b on a.service_id=b.id").
+		Where("b.is_delete=0 and a.rule_type=? and (a.need_https=1 or a.force_https=1)", public.HTTPRuleTypeDomain).Find(&rules).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	now := time.Now()
+	issued := false
+	for _, item := range rules {
+		rule := item
+		cert := &Cert{Name: rule.Rule, IsAcme: 1}
+		if rule.CertID > 0 {
+			search := &Cert{ID: rule.CertID}
+			if cert, err = search.Find(c, tx, search); err != nil {
+				log.Printf(" [ERROR] acme find cert %d for %s err:%v\n", rule.CertID, rule.Rule, err)
+				continue
+			}
+			//手动上传的证书不自动续期
+			if cert.IsAcme != 1 {
+				continue
+			}
+			if cert.IsDelete == 0 && cert.ExpireDays(now) >= s.RenewDays {
+				continue
+			}
+		}
+		locked, err := public.AcmeStoreHandler.Refresh("renew", owner, acmeRenewLockExpires)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return errors.New("acme renew lock lost")
+		}
+		client, err := s.client(ctx, c, tx)
+		if err != nil {
+			return err
+		}
+		issueCtx, cancel := context.WithTimeout(ctx, acmeIssueTimeout)
+		certPem, keyPem, err := s.Issue(issueCtx, client, rule.Rule)
+		cancel()
+		if err != nil {
+			log.Printf(" [ERROR] acme issue %s err:%v\n", rule.Rule, err)
+			continue
+		}
+		cert.CertPem = certPem
+		cert.KeyPem = keyPem
+		cert.IsDelete = 0
+		if _, err := cert.Parse(); err != nil {
+			return err
+		}
+		if err := cert.Save(c, tx); err != nil {
+			return err
+		}
+		//签发耗时较长, 只更新证书id, 避免覆盖期间控制台对规则的修改
+		if rule.CertID != cert.ID {
+			if err := tx.SetCtx(public.GetGinTraceContext(c)).Model(&HttpRule{}).Where("id = ?", rule.ID).Update("cert_id", cert.ID).Error; err != nil {
+				return err
+			}
+			rule.CertID = cert.ID
+		}
+		log.Printf(" [INFO] acme issued %s not_after:%s\n", rule.Rule, cert.NotAfter.Format(time.RFC3339))
+		issued = true
+	}
+	if issued {
+		return CertManagerHandler.Load()
+	}
+	return nil
+}
+
+// Watch 启动时检查一次, 之后按间隔检查, 直至调用 Stop
+func (s *AcmeManager) Watch() {
+	ticker := time.NewTicker(s.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.Renew(context.Background()); err != nil {
+			log.Printf(" [ERROR] acme renew err:%v\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *AcmeManager) Stop() {
+	close(s.stop)
+}
@@ -0,0 +1,103 @@
+package dao
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"github.com/yguilai/go-gateway/public"
+	"golang.org/x/crypto/acme"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcmeStoreLock(t *testing.T) {
+	store := public.NewMemoryAcmeStore()
+	if locked, _ := store.Lock("renew", "a", 60); !locked {
+		t.Fatal("first lock should succeed")
+	}
+	if locked, _ := store.Lock("renew", "b", 60); locked {
+		t.Fatal("second lock should fail until unlock")
+	}
+	if refreshed, _ := store.Refresh("renew", "b", 60); refreshed {
+		t.Fatal("refresh by other owner should fail")
+	}
+	if refreshed, _ := store.Refresh("renew", "a", 60); !refreshed {
+		t.Fatal("refresh by owner should succeed")
+	}
+	store.Unlock("renew", "b")
+	if locked, _ := store.Lock("renew", "b", 60); locked {
+		t.Fatal("unlock by other owner should not release the lock")
+	}
+	store.Unlock("renew", "a")
+	if locked, _ := store.Lock("renew", "b", 60); !locked {
+		t.Fatal("lock should succeed after unlock")
+	}
+}
+
+// TestAcmeIssuePebble 使用pebble测试签发, 未设置 PEBBLE_DIRECTORY_URL 时跳过
+//
+//	PEBBLE_VA_ALWAYS_VALID=1 pebble -config test/config/pebble-config.json
+//	PEBBLE_DIRECTORY_URL=https://127.0.0.1:14000/dir go test ./dao -run Pebble
+//
+// 不设置 PEBBLE_VA_ALWAYS_VALID 时, pebble 需能将 PEBBLE_DOMAIN 解析到本机,
+// 并通过 PEBBLE_HTTP01_ADDR (默认 :5002, 与pebble的httpPort一致) 访问验证内容
+func TestAcmeIssuePebble(t *testing.T) {
+	directoryURL := os.Getenv("PEBBLE_DIRECTORY_URL")
+	if directoryURL == "" {
+		t.Skip("PEBBLE_DIRECTORY_URL not set")
+	}
+	domain := os.Getenv("PEBBLE_DOMAIN")
+	if domain == "" {
+		domain = "gateway.test"
+	}
+	addr := os.Getenv("PEBBLE_HTTP01_ADDR")
+	if addr == "" {
+		addr = ":5002"
+	}
+	public.AcmeStoreHandler = public.NewMemoryAcmeStore()
+	server := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyAuth, _ := public.AcmeStoreHandler.GetChallenge(strings.TrimPrefix(r.URL.Path, public.AcmeChallengePath))
+		if keyAuth == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(keyAuth))
+	})}
+	go server.ListenAndServe()
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewAcmeManager()
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: directoryURL,
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS); err != nil {
+		t.Fatal(err)
+	}
+	certPem, keyPem, err := manager.Issue(ctx, client, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &Cert{Name: domain, CertPem: certPem, KeyPem: keyPem}
+	pair, err := cert.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pair.Leaf.VerifyHostname(domain); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/yguilai/go-gateway/common/lib"
 	"github.com/yguilai/go-gateway/dto"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/public"
+	"log"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,12 +19,19 @@ type App struct {
 	AppID     string    `json:"app_id" gorm:"column:app_id" description:"租户id	"`
 	Name      string    `json:"name" gorm:"column:name" description:"租户名称	"`
 	Secret    string    `json:"secret" gorm:"column:secret" description:"密钥"`
-	WhiteIPS  string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持前缀匹配"`
+	WhiteIPS  string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持ip、CIDR、ip范围与前缀匹配"`
 	Qpd       int64     `json:"qpd" gorm:"column:qpd" description:"日请求量限制"`
 	Qps       int64     `json:"qps" gorm:"column:qps" description:"每秒请求量限制"`
+	TokenExpires int    `json:"token_expires" gorm:"column:token_expires" description:"token有效期, 单位s, 0=使用默认"`
 	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间	"`
 	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
 	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+
+	Grants []*AppGrant `json:"grants" gorm:"-" description:"可访问的服务"`
+
+	whiteMatcher  *ip_matcher.Matcher
+	whiteInvalid  bool //白名单不为空但存在无法解析的项, 拒绝全部来源ip
+	whiteCompiled bool
 }
 
 func (t *App) TableName() string {
@@ -41,6 +51,95 @@ func (t *App) Save(c *gin.Context, tx *gorm.DB) error {
 	return nil
 }
 
+// CompileWhiteIPS 载入租户时解析ip白名单
+// 升级前保存的前缀(如 192.168.1)等无法解析的项不能忽略, 否则白名单失效, 此时拒绝全部来源ip直到在控制台修正
+func (t *App) CompileWhiteIPS() {
+	matcher, err := ip_matcher.Parse(t.WhiteIPS)
+	if err != nil {
+		log.Printf(" [WARN] app %s white_ips err:%v, all ips are denied\n", t.AppID, err)
+	}
+	t.whiteMatcher = matcher
+	t.whiteInvalid = strings.TrimSpace(t.WhiteIPS) != "" && (err != nil || matcher.Empty())
+	t.whiteCompiled = true
+}
+
+// AllowIP 白名单为空时不限制来源ip
+// 租户管理器载入的租户已解析白名单, 其他来源的租户在首次调用时解析
+func (t *App) AllowIP(ip string) bool {
+	if !t.whiteCompiled {
+		t.CompileWhiteIPS()
+	}
+	if t.whiteInvalid {
+		return false
+	}
+	return t.whiteMatcher.Empty() || t.whiteMatcher.Match(ip)
+}
+
+// GetTokenExpires 租户token有效期, 未设置时使用默认值
+func (t *App) GetTokenExpires() int {
+	if t.TokenExpires > 0 {
+		return t.TokenExpires
+	}
+	return public.JwtKeyHandler.Expires
+}
+
+// legacyGrants 升级前创建的租户没有授权记录, 视为可访问全部服务, 与升级前的行为一致
+var legacyGrants = []*AppGrant{{ServiceName: "*"}}
+
+// EffectiveGrants 租户实际生效的授权, 没有授权记录时为全部服务
+func (t *App) EffectiveGrants() []*AppGrant {
+	if len(t.Grants) == 0 {
+		return legacyGrants
+	}
+	return t.Grants
+}
+
+// GrantScopes 租户被授权的全部scope
+func (t *App) GrantScopes() []string {
+	scopes := []string{}
+	for _, grant := range t.EffectiveGrants() {
+		if !public.InStringSlice(scopes, grant.Scope()) {
+			scopes = append(scopes, grant.Scope())
+		}
+	}
+	return scopes
+}
+
+// IssueScopes 签发token时的scope, 为申请的服务与授权的交集, 未申请具体服务时签发全部授权
+func (t *App) IssueScopes(requested []string) []string {
+	granted := t.GrantScopes()
+	narrowed := false
+	scopes := []string{}
+	for _, scope := range requested {
+		if !strings.HasPrefix(scope, public.ServiceScopePrefix) {
+			continue
+		}
+		narrowed = true
+		if public.InStringSlice(granted, scope) || public.InStringSlice(granted, public.ServiceScopePrefix+"*") {
+			scopes = append(scopes, scope)
+		}
+	}
+	if !narrowed {
+		return granted
+	}
+	return scopes
+}
+
+// Allow 请求需同时在token的scope与租户当前授权范围内
+// 升级前签发的token不带scope, 仅按租户当前授权判断
+func (t *App) Allow(scopes []string, serviceName, method, path string) bool {
+	if len(scopes) > 0 && !public.InStringSlice(scopes, public.ServiceScopePrefix+serviceName) &&
+		!public.InStringSlice(scopes, public.ServiceScopePrefix+"*") {
+		return false
+	}
+	for _, grant := range t.EffectiveGrants() {
+		if grant.Match(serviceName, method, path) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *App) APPList(c *gin.Context, tx *gorm.DB, params *dto.APPListInput) ([]App, int64, error) {
 	var list []App
 	var count int64
@@ -74,25 +173,102 @@ func init() {
 type AppManager struct {
 	AppMap   map[string]*App
 	AppSlice []*App
-	Locker   sync.RWMutex
+	//已删除的租户及确认删除时的修改时间, 避免已删除租户的请求每次都查询数据库
+	DeletedMap map[string]int64
+	Locker     sync.RWMutex
 	init     sync.Once
 	err      error
 }
 
 func NewAppManager() *AppManager {
 	return &AppManager{
-		AppMap:   map[string]*App{},
-		AppSlice: []*App{},
-		Locker:   sync.RWMutex{},
-		init:     sync.Once{},
+		AppMap:     map[string]*App{},
+		AppSlice:   []*App{},
+		DeletedMap: map[string]int64{},
+		Locker:     sync.RWMutex{},
+		init:       sync.Once{},
 	}
 }
 
 
 func (s *AppManager) GetAppList() []*App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
 	return s.AppSlice
 }
 
+// GetApp 按租户id获取, 控制台修改过的租户从数据库重新载入, 不存在或已删除时返回nil
+// 请求路径上查找租户都应使用此方法, 以便控制台的修改及时生效
+func (s *AppManager) GetApp(appID string) (*App, error) {
+	s.Locker.RLock()
+	appInfo := s.AppMap[appID]
+	deletedAt, deleted := s.DeletedMap[appID]
+	s.Locker.RUnlock()
+	updatedAt, err := public.JwtRevokeHandler.AppUpdatedAt(appID)
+	if err != nil {
+		return nil, err
+	}
+	if updatedAt == 0 || (appInfo != nil && updatedAt <= appInfo.UpdatedAt.Unix()) {
+		return appInfo, nil
+	}
+	if appInfo == nil && deleted && updatedAt <= deletedAt {
+		return nil, nil
+	}
+	appInfo, err = s.ReloadApp(appID)
+	if err != nil || appInfo != nil {
+		return appInfo, err
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if _, ok := s.AppMap[appID]; !ok && s.DeletedMap[appID] < updatedAt {
+		s.DeletedMap[appID] = updatedAt
+	}
+	return nil, nil
+}
+
+// ReloadApp 重新载入单个租户及其授权, 列表整体替换, 已取得旧列表的请求不受影响
+func (s *AppManager) ReloadApp(appID string) (*App, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, err
+	}
+	appInfo, err := (&App{}).Find(c, tx, &App{AppID: appID})
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err == gorm.ErrRecordNotFound || appInfo.IsDelete == 1 {
+		appInfo = nil
+	} else {
+		grants, err := (&AppGrant{}).ListByAppID(c, tx, appID)
+		if err != nil {
+			return nil, err
+		}
+		for _, grantItem := range grants {
+			tmpGrant := grantItem
+			appInfo.Grants = append(appInfo.Grants, &tmpGrant)
+		}
+		appInfo.CompileWhiteIPS()
+	}
+
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	appSlice := make([]*App, 0, len(s.AppSlice)+1)
+	for _, item := range s.AppSlice {
+		if item.AppID != appID {
+			appSlice = append(appSlice, item)
+		}
+	}
+	delete(s.AppMap, appID)
+	if appInfo != nil {
+		appSlice = append(appSlice, appInfo)
+		s.AppMap[appID] = appInfo
+		delete(s.DeletedMap, appID)
+	}
+	s.AppSlice = appSlice
+	return appInfo, nil
+}
+
 func (s *AppManager) LoadOnce() error {
 	s.init.Do(func() {
 		appInfo := &App{}
@@ -108,10 +284,22 @@ func (s *AppManager) LoadOnce() error {
 			s.err = err
 			return
 		}
+		grants, err := (&AppGrant{}).ListByAppID(c, tx, "")
+		if err != nil {
+			s.err = err
+			return
+		}
+		grantMap := map[string][]*AppGrant{}
+		for _, grantItem := range grants {
+			tmpGrant := grantItem
+			grantMap[tmpGrant.AppID] = append(grantMap[tmpGrant.AppID], &tmpGrant)
+		}
 		s.Locker.Lock()
 		defer s.Locker.Unlock()
 		for _, listItem := range list {
 			tmpItem := listItem
+			tmpItem.Grants = grantMap[tmpItem.AppID]
+			tmpItem.CompileWhiteIPS()
 			s.AppMap[listItem.AppID] = &tmpItem
 			s.AppSlice = append(s.AppSlice, &tmpItem)
 		}
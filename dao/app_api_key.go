@@ -0,0 +1,199 @@
+package dao
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/public"
+	"log"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// AppApiKey 租户的api key, 只保存sha256摘要, 明文仅在创建时返回一次
+type AppApiKey struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	AppID     string    `json:"app_id" gorm:"column:app_id" description:"租户id"`
+	Name      string    `json:"name" gorm:"column:name" description:"名称"`
+	KeyPrefix string    `json:"key_prefix" gorm:"column:key_prefix" description:"key前缀, 用于识别"`
+	KeyHash   string    `json:"-" gorm:"column:key_hash" description:"key的sha256摘要"`
+	ExpireAt  int64     `json:"expire_at" gorm:"column:expire_at" description:"过期时间戳, 0=永不过期"`
+	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+func (t *AppApiKey) TableName() string {
+	return "gateway_app_api_key"
+}
+
+func (t *AppApiKey) Find(c *gin.Context, tx *gorm.DB, search *AppApiKey) (*AppApiKey, error) {
+	model := &AppApiKey{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *AppApiKey) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListByAppID appID为空时返回全部租户的key
+func (t *AppApiKey) ListByAppID(c *gin.Context, tx *gorm.DB, appID string) ([]AppApiKey, error) {
+	var list []AppApiKey
+	query := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()).Where("is_delete=?", 0)
+	if appID != "" {
+		query = query.Where("app_id=?", appID)
+	}
+	err := query.Order("id asc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (t *AppApiKey) Expired(now time.Time) bool {
+	return t.ExpireAt > 0 && now.Unix() >= t.ExpireAt
+}
+
+// Revoke 删除key, 并通知已加载该key的网关节点
+func (t *AppApiKey) Revoke(c *gin.Context, tx *gorm.DB) error {
+	t.IsDelete = 1
+	if err := t.Save(c, tx); err != nil {
+		return err
+	}
+	return public.JwtRevokeHandler.RevokeApiKey(t.KeyHash)
+}
+
+// NewAppApiKey 生成api key, 返回明文与待保存的记录
+func NewAppApiKey(appID, name string, expireAt int64) (string, *AppApiKey) {
+	b := make([]byte, 24)
+	rand.Read(b)
+	key := public.ApiKeyPrefix + hex.EncodeToString(b)
+	return key, &AppApiKey{
+		AppID:     appID,
+		Name:      name,
+		KeyPrefix: key[:len(public.ApiKeyPrefix)+6],
+		KeyHash:   HashApiKey(key),
+		ExpireAt:  expireAt,
+	}
+}
+
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+var AppApiKeyHandler *AppApiKeyManager
+
+// AppApiKeyManager 按摘要索引全部api key, 定时从数据库重新载入, 控制台新建的key无需重启即可使用
+type AppApiKeyManager struct {
+	KeyMap map[string]*AppApiKey
+	Locker sync.RWMutex
+	init   sync.Once
+	err    error
+	stop   chan struct{}
+}
+
+func NewAppApiKeyManager() *AppApiKeyManager {
+	return &AppApiKeyManager{
+		KeyMap: map[string]*AppApiKey{},
+		Locker: sync.RWMutex{},
+		init:   sync.Once{},
+		stop:   make(chan struct{}),
+	}
+}
+
+func init() {
+	AppApiKeyHandler = NewAppApiKeyManager()
+}
+
+func (s *AppApiKeyManager) LoadOnce() error {
+	s.init.Do(func() {
+		s.err = s.Reload()
+	})
+	return s.err
+}
+
+// Reload 整体替换key列表, 已删除的key随之移除
+func (s *AppApiKeyManager) Reload() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	list, err := (&AppApiKey{}).ListByAppID(c, tx, "")
+	if err != nil {
+		return err
+	}
+	keyMap := map[string]*AppApiKey{}
+	for _, item := range list {
+		tmpItem := item
+		keyMap[tmpItem.KeyHash] = &tmpItem
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.KeyMap = keyMap
+	return nil
+}
+
+// Watch 定时重新载入, 直至调用 Stop
+func (s *AppApiKeyManager) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				log.Printf(" [ERROR] reload api key err:%v\n", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *AppApiKeyManager) Stop() {
+	close(s.stop)
+}
+
+func (s *AppApiKeyManager) AddKey(key *AppApiKey) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.KeyMap[key.KeyHash] = key
+}
+
+// Resolve 校验api key并返回所属租户
+func (s *AppApiKeyManager) Resolve(key string) (*App, error) {
+	keyHash := HashApiKey(key)
+	s.Locker.RLock()
+	apiKey, ok := s.KeyMap[keyHash]
+	s.Locker.RUnlock()
+	if !ok {
+		return nil, errors.New("invalid api key")
+	}
+	if apiKey.Expired(time.Now()) {
+		return nil, errors.New("api key expired")
+	}
+	revoked, err := public.JwtRevokeHandler.IsApiKeyRevoked(keyHash)
+	if err != nil {
+		return nil, errors.WithMessage(err, "check api key revoked")
+	}
+	if revoked {
+		return nil, errors.New("api key revoked")
+	}
+	appInfo, err := AppManagerHandler.GetApp(apiKey.AppID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get api key app")
+	}
+	if appInfo == nil {
+		return nil, errors.New("api key app not found")
+	}
+	return appInfo, nil
+}
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"github.com/yguilai/go-gateway/public"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppApiKeyResolve(t *testing.T) {
+	origin := public.JwtRevokeHandler
+	defer func() { public.JwtRevokeHandler = origin }()
+	public.JwtRevokeHandler = public.NewJwtRevokeManager(public.NewMemoryJwtRevokeStore())
+
+	app := &App{AppID: "app_api_key"}
+	AppManagerHandler.AppMap[app.AppID] = app
+	manager := NewAppApiKeyManager()
+
+	key, apiKey := NewAppApiKey(app.AppID, "partner", 0)
+	if !strings.HasPrefix(key, apiKey.KeyPrefix) || apiKey.KeyHash == key || apiKey.KeyHash != HashApiKey(key) {
+		t.Fatalf("unexpected api key %s %+v", key, apiKey)
+	}
+	manager.AddKey(apiKey)
+	expiredKey, expired := NewAppApiKey(app.AppID, "expired", time.Now().Add(-time.Minute).Unix())
+	manager.AddKey(expired)
+	orphanKey, orphan := NewAppApiKey("app_not_exist", "orphan", 0)
+	manager.AddKey(orphan)
+
+	if resolved, err := manager.Resolve(key); err != nil || resolved != app {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	for name, item := range map[string]string{"unknown": public.ApiKeyPrefix + "unknown", "expired": expiredKey, "orphan": orphanKey} {
+		if _, err := manager.Resolve(item); err == nil {
+			t.Fatalf("%s key should be rejected", name)
+		}
+	}
+	if err := public.JwtRevokeHandler.RevokeApiKey(apiKey.KeyHash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Resolve(key); err == nil {
+		t.Fatal("revoked key should be rejected")
+	}
+}
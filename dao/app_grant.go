@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"strings"
+)
+
+// AppGrant 租户可访问的服务, 可选限制http方法与路径
+// ServiceName 为 * 时表示全部服务; grpc服务的路径为 /pkg.Service/Method
+type AppGrant struct {
+	ID          int64  `json:"id" gorm:"primary_key"`
+	AppID       string `json:"app_id" gorm:"column:app_id" description:"租户id"`
+	ServiceName string `json:"service_name" gorm:"column:service_name" description:"服务名称, *=全部服务"`
+	Methods     string `json:"methods" gorm:"column:methods" description:"允许的http方法, 逗号间隔, 为空不限制"`
+	PathPattern string `json:"path_pattern" gorm:"column:path_pattern" description:"允许的路径, 支持末尾*前缀匹配, 为空不限制"`
+}
+
+func (t *AppGrant) TableName() string {
+	return "gateway_app_grant"
+}
+
+func (t *AppGrant) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteByAppID 删除租户下所有授权, 更新租户时整体覆盖
+func (t *AppGrant) DeleteByAppID(c *gin.Context, tx *gorm.DB, appID string) error {
+	return tx.SetCtx(public.GetGinTraceContext(c)).Where("app_id=?", appID).Delete(&AppGrant{}).Error
+}
+
+func (t *AppGrant) ListByAppID(c *gin.Context, tx *gorm.DB, appID string) ([]AppGrant, error) {
+	var list []AppGrant
+	query := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName())
+	if appID != "" {
+		query = query.Where("app_id=?", appID)
+	}
+	err := query.Order("id asc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Scope 授权在token中的scope, 格式 service:服务名
+func (t *AppGrant) Scope() string {
+	return public.ServiceScopePrefix + t.ServiceName
+}
+
+// Match 判断请求是否在授权范围内
+func (t *AppGrant) Match(serviceName, method, path string) bool {
+	if t.ServiceName != "*" && t.ServiceName != serviceName {
+		return false
+	}
+	if t.Methods != "" {
+		matched := false
+		for _, item := range strings.Split(t.Methods, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), method) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if t.PathPattern == "" {
+		return true
+	}
+	if strings.HasSuffix(t.PathPattern, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(t.PathPattern, "*"))
+	}
+	return t.PathPattern == path
+}
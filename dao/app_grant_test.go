@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"github.com/yguilai/go-gateway/public"
+	"testing"
+)
+
+func TestAppGrant(t *testing.T) {
+	app := &App{AppID: "app_grant", Grants: []*AppGrant{
+		{ServiceName: "test_http_service", Methods: "GET,POST", PathPattern: "/test_http_service/*"},
+		{ServiceName: "test_grpc_service"},
+	}}
+
+	scopes := app.IssueScopes([]string{"read_write"})
+	if len(scopes) != 2 {
+		t.Fatalf("unexpected scopes %v", scopes)
+	}
+	narrowed := app.IssueScopes([]string{public.ServiceScopePrefix + "test_grpc_service", public.ServiceScopePrefix + "unknown"})
+	if len(narrowed) != 1 || narrowed[0] != public.ServiceScopePrefix+"test_grpc_service" {
+		t.Fatalf("unexpected narrowed scopes %v", narrowed)
+	}
+	if len(app.IssueScopes([]string{public.ServiceScopePrefix + "unknown"})) != 0 {
+		t.Fatal("ungranted scope should not be issued")
+	}
+
+	cases := []struct {
+		scopes  []string
+		service string
+		method  string
+		path    string
+		allow   bool
+	}{
+		{scopes, "test_http_service", "GET", "/test_http_service/abc", true},
+		{scopes, "test_http_service", "DELETE", "/test_http_service/abc", false},
+		{scopes, "test_http_service", "GET", "/other/abc", false},
+		{scopes, "test_grpc_service", "POST", "/pkg.Echo/Ping", true},
+		{narrowed, "test_http_service", "GET", "/test_http_service/abc", false},
+		{scopes, "unknown_service", "GET", "/", false},
+	}
+	for _, item := range cases {
+		if allow := app.Allow(item.scopes, item.service, item.method, item.path); allow != item.allow {
+			t.Fatalf("%s %s %s allow=%v, want %v", item.service, item.method, item.path, allow, item.allow)
+		}
+	}
+
+	//通配授权
+	all := &App{AppID: "app_all", Grants: []*AppGrant{{ServiceName: "*"}}}
+	allScopes := all.IssueScopes([]string{public.ServiceScopePrefix + "test_http_service"})
+	if !all.Allow(allScopes, "test_http_service", "GET", "/") || all.Allow(allScopes, "test_grpc_service", "GET", "/") {
+		t.Fatalf("unexpected wildcard result with scopes %v", allScopes)
+	}
+
+	//升级前的租户没有授权记录, 旧token不带scope
+	legacy := &App{AppID: "app_legacy"}
+	if legacyScopes := legacy.IssueScopes(nil); len(legacyScopes) != 1 || legacyScopes[0] != public.ServiceScopePrefix+"*" {
+		t.Fatalf("unexpected legacy scopes %v", legacyScopes)
+	}
+	if !legacy.Allow(nil, "test_http_service", "GET", "/") || !app.Allow(nil, "test_grpc_service", "POST", "/pkg.Echo/Ping") {
+		t.Fatal("token without scope should follow current grants")
+	}
+	if app.Allow(nil, "unknown_service", "GET", "/") {
+		t.Fatal("token without scope should not exceed current grants")
+	}
+}
@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"github.com/yguilai/go-gateway/public"
+	"testing"
+	"time"
+)
+
+func TestAppAllowIP(t *testing.T) {
+	app := &App{AppID: "app_white_ip", WhiteIPS: "10.0.0.0/8,192.168.1.,2001:db8::1"}
+	app.CompileWhiteIPS()
+	for ip, want := range map[string]bool{
+		"10.1.2.3":     true,
+		"192.168.1.20": true,
+		"192.168.2.20": false,
+		"2001:db8::1":  true,
+		"172.16.0.1":   false,
+	} {
+		if got := app.AllowIP(ip); got != want {
+			t.Fatalf("allow %s: want %v, got %v", ip, want, got)
+		}
+	}
+	if !(&App{AppID: "app_any_ip"}).AllowIP("172.16.0.1") {
+		t.Fatal("empty white ips should allow any ip")
+	}
+	//白名单存在无法解析的项时拒绝全部来源ip, 未解析的租户在首次校验时解析
+	for _, whiteIPS := range []string{"192.168.1", "10.0.0.0/8,bad"} {
+		if (&App{AppID: "app_bad_ip", WhiteIPS: whiteIPS}).AllowIP("10.1.2.3") {
+			t.Fatalf("%s: invalid white ips should deny", whiteIPS)
+		}
+	}
+}
+
+func TestAppManagerGetAppDeleted(t *testing.T) {
+	origin := public.JwtRevokeHandler
+	defer func() { public.JwtRevokeHandler = origin }()
+	public.JwtRevokeHandler = public.NewJwtRevokeManager(public.NewMemoryJwtRevokeStore())
+
+	manager := NewAppManager()
+	deletedAt := time.Now().Add(-time.Minute)
+	manager.DeletedMap["app_deleted"] = deletedAt.Unix()
+	if err := public.JwtRevokeHandler.MarkAppUpdated("app_deleted", deletedAt); err != nil {
+		t.Fatal(err)
+	}
+	//已确认删除的租户不再查询数据库
+	if appInfo, err := manager.GetApp("app_deleted"); err != nil || appInfo != nil {
+		t.Fatalf("deleted app: %v %v", appInfo, err)
+	}
+	//删除后再次修改时重新载入, 测试环境没有数据库, 返回载入错误
+	if err := public.JwtRevokeHandler.MarkAppUpdated("app_deleted", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.GetApp("app_deleted"); err == nil {
+		t.Fatal("updated app should be reloaded")
+	}
+}
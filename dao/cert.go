@@ -0,0 +1,239 @@
+package dao
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/dto"
+	"github.com/yguilai/go-gateway/public"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cert 证书, 绑定到域名类型的http服务后按SNI选择
+type Cert struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	Name      string    `json:"name" gorm:"column:name" description:"名称"`
+	Domains   string    `json:"domains" gorm:"column:domains" description:"证书包含的域名, 逗号间隔, 上传时从证书解析"`
+	CertPem   string    `json:"cert_pem" gorm:"column:cert_pem" description:"PEM格式证书链"`
+	KeyPem    string    `json:"-" gorm:"column:key_pem" description:"PEM格式私钥"`
+	NotAfter  time.Time `json:"not_after" gorm:"column:not_after" description:"过期时间"`
+	IsAcme    int8      `json:"is_acme" gorm:"column:is_acme" description:"是否通过ACME自动签发 1=是"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+func (t *Cert) TableName() string {
+	return "gateway_cert"
+}
+
+func (t *Cert) Find(c *gin.Context, tx *gorm.DB, search *Cert) (*Cert, error) {
+	model := &Cert{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (t *Cert) Save(c *gin.Context, tx *gorm.DB) error {
+	return tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error
+}
+
+func (t *Cert) PageList(c *gin.Context, tx *gorm.DB, params *dto.CertListInput) ([]Cert, int64, error) {
+	var list []Cert
+	var count int64
+	offset := (params.PageNo - 1) * params.PageSize
+	query := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()).Where("is_delete=0")
+	if params.Info != "" {
+		query = query.Where("(name like ? or domains like ?)", "%"+params.Info+"%", "%"+params.Info+"%")
+	}
+	if err := query.Limit(params.PageSize).Offset(offset).Order("not_after asc").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, count, nil
+}
+
+// ExpiringCount 在now之后warnDays天内过期或已过期的证书数量
+func (t *Cert) ExpiringCount(c *gin.Context, tx *gorm.DB, now time.Time, warnDays int) (int64, error) {
+	var count int64
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()).
+		Where("is_delete=0 and not_after<?", now.AddDate(0, 0, warnDays)).Count(&count).Error
+	return count, err
+}
+
+// Parse 解析证书与私钥, 并填充域名与过期时间
+func (t *Cert) Parse() (*tls.Certificate, error) {
+	pair, err := tls.X509KeyPair([]byte(t.CertPem), []byte(t.KeyPem))
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse cert "+t.Name)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse cert "+t.Name)
+	}
+	pair.Leaf = leaf
+	domains := leaf.DNSNames
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = []string{leaf.Subject.CommonName}
+	}
+	t.Domains = strings.Join(domains, ",")
+	t.NotAfter = leaf.NotAfter
+	return &pair, nil
+}
+
+// ExpireDays 剩余有效天数, 已过期时为负数
+func (t *Cert) ExpireDays(now time.Time) int {
+	return int(t.NotAfter.Sub(now).Hours() / 24)
+}
+
+// BoundServices 绑定了该证书的服务名称
+func (t *Cert) BoundServices(c *gin.Context, tx *gorm.DB) ([]string, error) {
+	var names []string
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table((&HttpRule{}).TableName()+" a").
+		Joins("join "+(&ServiceInfo{}).TableName()+" b on a.service_id=b.id").
+		Where("b.is_delete=0 and a.cert_id=?", t.ID).Pluck("b.service_name", &names).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return names, nil
+}
+
+var CertManagerHandler *CertManager
+
+// CertManager 按SNI选择证书, 定时从数据库重新载入, 替换证书无需重启
+// 优先使用绑定到服务域名的证书, 其次是包含该域名的证书, 最后使用配置文件中的默认证书
+type CertManager struct {
+	DomainMap   map[string]*tls.Certificate
+	CertSlice   []*tls.Certificate
+	DefaultCert *tls.Certificate
+	Locker      sync.RWMutex
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+func NewCertManager() *CertManager {
+	return &CertManager{
+		DomainMap: map[string]*tls.Certificate{},
+		CertSlice: []*tls.Certificate{},
+		Locker:    sync.RWMutex{},
+		stop:      make(chan struct{}),
+	}
+}
+
+func init() {
+	CertManagerHandler = NewCertManager()
+}
+
+// GetCertificate 用于 tls.Config.GetCertificate
+func (s *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	serverName := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	if cert, ok := s.DomainMap[serverName]; ok {
+		return cert, nil
+	}
+	if serverName != "" {
+		for _, cert := range s.CertSlice {
+			if cert.Leaf.VerifyHostname(serverName) == nil {
+				return cert, nil
+			}
+		}
+	}
+	if s.DefaultCert == nil {
+		return nil, errors.New("no certificate for " + serverName)
+	}
+	return s.DefaultCert, nil
+}
+
+// Load 载入默认证书与数据库中的证书, 解析失败的证书跳过, 不影响其余证书
+func (s *CertManager) Load() error {
+	domainMap := map[string]*tls.Certificate{}
+	certSlice := []*tls.Certificate{}
+	var defaultCert *tls.Certificate
+	certFile, keyFile := public.ProxyCertFile()
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		defaultCert = &pair
+	} else {
+		log.Printf(" [WARN] load default cert err:%v\n", err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	var list []Cert
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Where("is_delete=0").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	certMap := map[int64]*tls.Certificate{}
+	now := time.Now()
+	for _, item := range list {
+		tmpItem := item
+		pair, err := tmpItem.Parse()
+		if err != nil {
+			log.Printf(" [WARN] %v\n", err)
+			continue
+		}
+		if days := tmpItem.ExpireDays(now); days < public.CertExpireWarnDays {
+			log.Printf(" [WARN] cert %s expires in %d days\n", tmpItem.Name, days)
+		}
+		certMap[tmpItem.ID] = pair
+		certSlice = append(certSlice, pair)
+	}
+
+	//域名类型的服务绑定的证书
+	var rules []HttpRule
+	err = tx.SetCtx(public.GetGinTraceContext(c)).Table((&HttpRule{}).TableName()+" a").Select("a.*").
+		Joins("join "+(&ServiceInfo{}).TableName()+" b on a.service_id=b.id").
+		Where("b.is_delete=0 and a.rule_type=? and a.cert_id>0", public.HTTPRuleTypeDomain).Find(&rules).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	for _, rule := range rules {
+		if pair, ok := certMap[rule.CertID]; ok {
+			domainMap[strings.ToLower(rule.Rule)] = pair
+		}
+	}
+
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.DomainMap = domainMap
+	s.CertSlice = certSlice
+	s.DefaultCert = defaultCert
+	return nil
+}
+
+// Watch 定时重新载入证书, 直至调用 Stop
+func (s *CertManager) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Load(); err != nil {
+				log.Printf(" [ERROR] reload cert err:%v\n", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop 可重复调用
+func (s *CertManager) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestServerCert(t *testing.T, name string, notAfter time.Time, domains ...string) *Cert {
+	cert, key := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}, nil, nil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Cert{
+		Name:    name,
+		CertPem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		KeyPem:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})),
+	}
+}
+
+func TestCertParse(t *testing.T) {
+	now := time.Now()
+	cert := newTestServerCert(t, "example", now.Add(10*24*time.Hour+time.Hour), "example.com", "*.example.com")
+	if _, err := cert.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if cert.Domains != "example.com,*.example.com" {
+		t.Fatalf("unexpected domains %s", cert.Domains)
+	}
+	if days := cert.ExpireDays(now); days != 10 {
+		t.Fatalf("expire days should be 10, got %d", days)
+	}
+	cert.KeyPem = newTestServerCert(t, "other", now.Add(time.Hour), "other.com").KeyPem
+	if _, err := cert.Parse(); err == nil {
+		t.Fatal("mismatched key should fail")
+	}
+}
+
+func TestCertManagerGetCertificate(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour)
+	parse := func(cert *Cert) *tls.Certificate {
+		pair, err := cert.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair
+	}
+	bound := parse(newTestServerCert(t, "bound", notAfter, "api.example.com"))
+	wildcard := parse(newTestServerCert(t, "wildcard", notAfter, "*.example.com"))
+	defaultCert := parse(newTestServerCert(t, "default", notAfter, "localhost"))
+
+	manager := NewCertManager()
+	manager.DomainMap["api.example.com"] = bound
+	manager.CertSlice = []*tls.Certificate{wildcard, bound}
+	manager.DefaultCert = defaultCert
+
+	for serverName, want := range map[string]*tls.Certificate{
+		"API.example.com.": bound,
+		"www.example.com":  wildcard,
+		"other.com":        defaultCert,
+		"":                 defaultCert,
+	} {
+		got, err := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("unexpected cert for %q", serverName)
+		}
+	}
+
+	manager.DefaultCert = nil
+	if _, err := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"}); err == nil {
+		t.Fatal("no matching cert should fail")
+	}
+}
@@ -0,0 +1,130 @@
+package dao
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/geoip"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var GeoIPManagerHandler *GeoIPManager
+
+// GeoIPManager 载入 proxy.geoip 配置的MaxMind库, 定时检查文件修改时间, 替换库文件无需重启
+type GeoIPManager struct {
+	DB          *geoip.DB
+	CountryFile string
+	AsnFile     string
+	ModTime     map[string]time.Time
+	Locker      sync.RWMutex
+	stop        chan struct{}
+}
+
+func NewGeoIPManager() *GeoIPManager {
+	return &GeoIPManager{
+		ModTime: map[string]time.Time{},
+		Locker:  sync.RWMutex{},
+		stop:    make(chan struct{}),
+	}
+}
+
+func init() {
+	GeoIPManagerHandler = NewGeoIPManager()
+}
+
+// Configured 是否配置了库文件, 未配置时无需定时检查
+func (s *GeoIPManager) Configured() bool {
+	return lib.GetStringConf("proxy.geoip.country_db") != "" || lib.GetStringConf("proxy.geoip.asn_db") != ""
+}
+
+// Load 未配置库文件时不做地理查询, 载入失败时保留之前的库
+func (s *GeoIPManager) Load() error {
+	countryFile := lib.GetStringConf("proxy.geoip.country_db")
+	asnFile := lib.GetStringConf("proxy.geoip.asn_db")
+	modTime := map[string]time.Time{}
+	for _, file := range []string{countryFile, asnFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTime[file] = info.ModTime()
+	}
+	db, err := geoip.Open(countryFile, asnFile)
+	if err != nil {
+		return err
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.DB = db
+	s.CountryFile = countryFile
+	s.AsnFile = asnFile
+	s.ModTime = modTime
+	return nil
+}
+
+// changed 库文件修改时间变化时需要重新载入
+func (s *GeoIPManager) changed() bool {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for _, file := range []string{s.CountryFile, s.AsnFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(s.ModTime[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *GeoIPManager) Lookup(ip string) geoip.Record {
+	s.Locker.RLock()
+	db := s.DB
+	s.Locker.RUnlock()
+	return db.Lookup(net.ParseIP(ip))
+}
+
+// Watch 定时检查库文件, 直至调用 Stop
+func (s *GeoIPManager) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.Load(); err != nil {
+				log.Printf(" [ERROR] reload geoip err:%v\n", err)
+				continue
+			}
+			log.Printf(" [INFO] geoip reloaded\n")
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *GeoIPManager) Stop() {
+	close(s.stop)
+}
+
+// GeoRecord 查询客户端ip的地理信息, 同一请求只查询一次
+func GeoRecord(c *gin.Context) geoip.Record {
+	if record, ok := c.Get("geo"); ok {
+		return record.(geoip.Record)
+	}
+	record := GeoIPManagerHandler.Lookup(c.ClientIP())
+	c.Set("geo", record)
+	return record
+}
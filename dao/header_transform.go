@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"github.com/yguilai/go-gateway/header_transform"
+	"log"
+	"sync"
+)
+
+var HeaderTransformHandler *HeaderTransformManager
+
+// HeaderTransformManager 按服务缓存解析结果, 规则文本变化时重新解析并替换, 缓存数量不超过服务数
+type HeaderTransformManager struct {
+	RuleMap map[string]*headerTransformItem
+	Locker  sync.RWMutex
+}
+
+type headerTransformItem struct {
+	text  string
+	rules *header_transform.RuleSet
+}
+
+func NewHeaderTransformManager() *HeaderTransformManager {
+	return &HeaderTransformManager{
+		RuleMap: map[string]*headerTransformItem{},
+		Locker:  sync.RWMutex{},
+	}
+}
+
+func init() {
+	HeaderTransformHandler = NewHeaderTransformManager()
+}
+
+// GetRuleSet 升级前保存的规则可能不符合新格式, 无法解析的规则记录日志后跳过, 其余规则照常执行
+func (m *HeaderTransformManager) GetRuleSet(serviceName, text string) *header_transform.RuleSet {
+	m.Locker.RLock()
+	item, ok := m.RuleMap[serviceName]
+	m.Locker.RUnlock()
+	if ok && item.text == text {
+		return item.rules
+	}
+
+	rules, errs := header_transform.ParseValid(text)
+	for _, err := range errs {
+		log.Printf(" [WARN] service %s header transfor rule skipped: %v\n", serviceName, err)
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.RuleMap[serviceName] = &headerTransformItem{text: text, rules: rules}
+	return rules
+}
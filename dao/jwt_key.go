@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/public"
+	"net/http/httptest"
+	"time"
+)
+
+// JwtKey 数据库中的jwt签名密钥, 与 jwt.toml 中的密钥合并, 同kid时以数据库为准
+type JwtKey struct {
+	ID         int64     `json:"id" gorm:"primary_key"`
+	Kid        string    `json:"kid" gorm:"column:kid" description:"密钥id"`
+	Alg        string    `json:"alg" gorm:"column:alg" description:"签名算法 HS256/RS256/ES256"`
+	Secret     string    `json:"-" gorm:"column:secret" description:"HS256共享密钥"`
+	PrivateKey string    `json:"-" gorm:"column:private_key" description:"PEM格式私钥"`
+	PublicKey  string    `json:"public_key" gorm:"column:public_key" description:"PEM格式公钥, 仅验证的密钥使用"`
+	IsActive   int8      `json:"is_active" gorm:"column:is_active" description:"是否用于签发 1=是"`
+	CreatedAt  time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	IsDelete   int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+func (t *JwtKey) TableName() string {
+	return "gateway_jwt_key"
+}
+
+func (t *JwtKey) List(c *gin.Context, tx *gorm.DB) ([]JwtKey, error) {
+	var list []JwtKey
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()).Where("is_delete=?", 0).Order("id asc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+// LoadJwtKeys 服务启动时载入配置文件与数据库中的签名密钥, 没有可签发的密钥时返回错误
+func LoadJwtKeys() error {
+	if err := public.JwtKeyHandler.LoadConf(); err != nil {
+		return err
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	list, err := (&JwtKey{}).List(c, tx)
+	if err != nil {
+		return err
+	}
+	activeKid := ""
+	for _, item := range list {
+		key, err := public.NewJwtKey(item.Kid, item.Alg, []byte(item.Secret), []byte(item.PrivateKey), []byte(item.PublicKey))
+		if err != nil {
+			return err
+		}
+		public.JwtKeyHandler.AddKey(key)
+		if item.IsActive == 1 {
+			activeKid = item.Kid
+		}
+	}
+	if activeKid != "" {
+		if err := public.JwtKeyHandler.SetActive(activeKid); err != nil {
+			return err
+		}
+	}
+	return public.JwtKeyHandler.Check()
+}
@@ -0,0 +1,49 @@
+package dao
+
+import (
+	"github.com/yguilai/go-gateway/response_rewrite"
+	"sync"
+)
+
+var ResponseRewriteHandler *ResponseRewriteManager
+
+// ResponseRewriteManager 按服务缓存解析后的响应改写规则, 避免每个请求重复编译正则
+// 规则文本变化时重新解析并替换, 缓存数量不超过服务数
+type ResponseRewriteManager struct {
+	RuleMap map[string]*responseRewriteItem
+	Locker  sync.RWMutex
+}
+
+type responseRewriteItem struct {
+	text  string
+	rules *response_rewrite.RuleSet
+}
+
+func NewResponseRewriteManager() *ResponseRewriteManager {
+	return &ResponseRewriteManager{
+		RuleMap: map[string]*responseRewriteItem{},
+		Locker:  sync.RWMutex{},
+	}
+}
+
+func init() {
+	ResponseRewriteHandler = NewResponseRewriteManager()
+}
+
+func (m *ResponseRewriteManager) GetRuleSet(serviceName, text string) (*response_rewrite.RuleSet, error) {
+	m.Locker.RLock()
+	item, ok := m.RuleMap[serviceName]
+	m.Locker.RUnlock()
+	if ok && item.text == text {
+		return item.rules, nil
+	}
+
+	rules, err := response_rewrite.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.RuleMap[serviceName] = &responseRewriteItem{text: text, rules: rules}
+	return rules, nil
+}
@@ -0,0 +1,18 @@
+package dao
+
+import "testing"
+
+func TestResponseRewriteCache(t *testing.T) {
+	m := NewResponseRewriteManager()
+	first, err := m.GetRuleSet("test_http", "replace a b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := m.GetRuleSet("test_http", "replace a b"); cached != first {
+		t.Fatal("same text should reuse the parsed rules")
+	}
+	//规则修改后替换旧的缓存
+	if updated, _ := m.GetRuleSet("test_http", "replace a c"); updated == first || len(m.RuleMap) != 1 {
+		t.Fatalf("edited rules should replace the cache, got %d entries", len(m.RuleMap))
+	}
+}
@@ -12,12 +12,18 @@ import (
 )
 
 type ServiceDetail struct {
-	Info          *ServiceInfo   `json:"info" description:"基本信息"`
-	HTTPRule      *HttpRule      `json:"http_rule" description:"http规则"`
-	TCPRule       *TcpRule       `json:"tcp_rule" description:"tcp规则"`
-	GRPCRule      *GrpcRule      `json:"grpc_rule" description:"grpc规则"`
-	LoadBalance   *LoadBalance   `json:"load_balance" description:"负载均衡信息"`
-	AccessControl *AccessControl `json:"access_control" description:"请求控制信息"`
+	Info            *ServiceInfo      `json:"info" description:"基本信息"`
+	HTTPRule        *HttpRule         `json:"http_rule" description:"http规则"`
+	TCPRule         *TcpRule          `json:"tcp_rule" description:"tcp规则"`
+	GRPCRule        *GrpcRule         `json:"grpc_rule" description:"grpc规则"`
+	GRPCMethodRules []*GrpcMethodRule `json:"grpc_method_rules" description:"grpc方法级规则"`
+	GRPCDescriptor  *GrpcDescriptor   `json:"grpc_descriptor" description:"grpc描述文件"`
+	LoadBalance     *LoadBalance      `json:"load_balance" description:"负载均衡信息"`
+	AccessControl   *AccessControl    `json:"access_control" description:"请求控制信息"`
+	OidcRule        *OidcRule         `json:"oidc_rule" description:"外部身份提供方鉴权"`
+	MtlsRule        *MtlsRule         `json:"mtls_rule" description:"客户端证书鉴权"`
+	HTTPBodySchemas []*HttpBodySchema `json:"http_body_schemas" description:"http请求体校验"`
+	MirrorRule      *MirrorRule       `json:"mirror_rule" description:"流量复制"`
 }
 
 type ServiceManager struct {
@@ -73,20 +79,28 @@ func (s *ServiceManager) HTTPAccessMode(c *gin.Context) (*ServiceDetail, error)
 	host := c.Request.Host
 	host = host[:strings.Index(host, ":")]
 	path := c.Request.URL.Path
+	//带国家条件的服务优先匹配, 命中规则后再查询客户端所在国家
 	for _, item := range s.ServiceSlice {
-		if item.Info.LoadType != public.LoadTypeHTTP {
+		if item.Info.LoadType != public.LoadTypeHTTP || item.HTTPRule.GeoCountry == "" {
 			continue
 		}
-
-		if item.HTTPRule.RuleType == public.HTTPRuleTypeDomain {
-			if item.HTTPRule.Rule == host {
-				return item, nil
-			}
+		if item.HTTPRule.MatchRule(host, path) && item.HTTPRule.MatchCountry(GeoRecord(c).Country) {
+			return item, nil
 		}
-		if item.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL {
-			if strings.HasPrefix(path, item.HTTPRule.Rule) {
+	}
+	for _, item := range s.ServiceSlice {
+		//grpc服务开启 gRPC-Web/JSON 接入时按前缀匹配
+		if item.Info.LoadType == public.LoadTypeGRPC {
+			if item.GRPCRule.WebPrefix != "" && strings.HasPrefix(path, item.GRPCRule.WebPrefix) {
 				return item, nil
 			}
+			continue
+		}
+		if item.Info.LoadType != public.LoadTypeHTTP || item.HTTPRule.GeoCountry != "" {
+			continue
+		}
+		if item.HTTPRule.MatchRule(host, path) {
+			return item, nil
 		}
 	}
 	return nil, errors.New("not matched service")
@@ -3,18 +3,34 @@ package dao
 import (
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/geoip"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/public"
+	"log"
+	"sync"
 )
 
 type AccessControl struct {
 	ID                int64  `json:"id" gorm:"primary_key"`
 	ServiceID         int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
 	OpenAuth          int    `json:"open_auth" gorm:"column:open_auth" description:"是否开启权限 1=开启"`
+	OpenSign          int    `json:"open_sign" gorm:"column:open_sign" description:"是否开启请求签名 1=开启, 仅http服务"`
 	BlackList         string `json:"black_list" gorm:"column:black_list" description:"黑名单ip	"`
 	WhiteList         string `json:"white_list" gorm:"column:white_list" description:"白名单ip	"`
 	WhiteHostName     string `json:"white_host_name" gorm:"column:white_host_name" description:"白名单主机	"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" gorm:"column:clientip_flow_limit" description:"客户端ip限流	"`
 	ServiceFlowLimit  int    `json:"service_flow_limit" gorm:"column:service_flow_limit" description:"服务端限流	"`
+	GeoAllowCountry   string `json:"geo_allow_country" gorm:"column:geo_allow_country" description:"允许访问的国家码, 逗号间隔"`
+	GeoDenyCountry    string `json:"geo_deny_country" gorm:"column:geo_deny_country" description:"拒绝访问的国家码, 逗号间隔"`
+	GeoAllowAsn       string `json:"geo_allow_asn" gorm:"column:geo_allow_asn" description:"允许访问的ASN, 逗号间隔"`
+	GeoDenyAsn        string `json:"geo_deny_asn" gorm:"column:geo_deny_asn" description:"拒绝访问的ASN, 逗号间隔"`
+
+	whiteOnce    sync.Once
+	whiteMatcher *ip_matcher.Matcher
+	blackOnce    sync.Once
+	blackMatcher *ip_matcher.Matcher
+	geoOnce      sync.Once
+	geoPolicy    *geoip.Policy
 }
 
 func (t *AccessControl) TableName() string {
@@ -33,3 +49,40 @@ func (t *AccessControl) Save(c *gin.Context, tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// WhiteMatcher 编译后的ip白名单, 每个服务只解析一次
+func (t *AccessControl) WhiteMatcher() *ip_matcher.Matcher {
+	t.whiteOnce.Do(func() {
+		t.whiteMatcher = compileIPList(t.ServiceID, t.WhiteList)
+	})
+	return t.whiteMatcher
+}
+
+// BlackMatcher 编译后的ip黑名单, 每个服务只解析一次
+func (t *AccessControl) BlackMatcher() *ip_matcher.Matcher {
+	t.blackOnce.Do(func() {
+		t.blackMatcher = compileIPList(t.ServiceID, t.BlackList)
+	})
+	return t.blackMatcher
+}
+
+// GeoPolicy 编译后的国家与ASN规则, 每个服务只解析一次
+func (t *AccessControl) GeoPolicy() *geoip.Policy {
+	t.geoOnce.Do(func() {
+		policy, err := geoip.NewPolicy(t.GeoAllowCountry, t.GeoDenyCountry, t.GeoAllowAsn, t.GeoDenyAsn)
+		if err != nil {
+			log.Printf(" [WARN] service %d geo policy err:%v\n", t.ServiceID, err)
+		}
+		t.geoPolicy = policy
+	})
+	return t.geoPolicy
+}
+
+// compileIPList 保存时已校验格式, 此处忽略无法解析的项
+func compileIPList(serviceID int64, list string) *ip_matcher.Matcher {
+	matcher, err := ip_matcher.Parse(list)
+	if err != nil {
+		log.Printf(" [WARN] service %d ip list err:%v\n", serviceID, err)
+	}
+	return matcher
+}
@@ -0,0 +1,95 @@
+package dao
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"sync"
+	"time"
+)
+
+// GrpcDescriptor grpc服务的protobuf描述文件, 用于 gRPC-Web 接入时的 JSON 转码
+// 内容为 protoc --include_imports --descriptor_set_out 生成的 FileDescriptorSet
+type GrpcDescriptor struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	ServiceID int64     `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	FileName  string    `json:"file_name" gorm:"column:file_name" description:"上传的文件名"`
+	Content   []byte    `json:"-" gorm:"column:content" description:"FileDescriptorSet内容"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+}
+
+func (t *GrpcDescriptor) TableName() string {
+	return "gateway_service_grpc_descriptor"
+}
+
+func (t *GrpcDescriptor) Find(c *gin.Context, tx *gorm.DB, search *GrpcDescriptor) (*GrpcDescriptor, error) {
+	model := &GrpcDescriptor{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *GrpcDescriptor) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseFiles 解析描述文件, 依赖的proto需一并包含在内
+func (t *GrpcDescriptor) ParseFiles() (*protoregistry.Files, error) {
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(t.Content, fdSet); err != nil {
+		return nil, errors.WithMessage(err, "unmarshal descriptor set")
+	}
+	files, err := protodesc.NewFiles(fdSet)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse descriptor set")
+	}
+	return files, nil
+}
+
+var GrpcDescriptorHandler *GrpcDescriptorManager
+
+// GrpcDescriptorManager 缓存解析后的描述文件, 避免每个请求重复解析
+type GrpcDescriptorManager struct {
+	FilesMap map[string]*protoregistry.Files
+	Locker   sync.RWMutex
+}
+
+func NewGrpcDescriptorManager() *GrpcDescriptorManager {
+	return &GrpcDescriptorManager{
+		FilesMap: map[string]*protoregistry.Files{},
+		Locker:   sync.RWMutex{},
+	}
+}
+
+func init() {
+	GrpcDescriptorHandler = NewGrpcDescriptorManager()
+}
+
+// GetFiles 服务未上传描述文件时返回nil
+func (m *GrpcDescriptorManager) GetFiles(service *ServiceDetail) (*protoregistry.Files, error) {
+	if service.GRPCDescriptor == nil || len(service.GRPCDescriptor.Content) == 0 {
+		return nil, nil
+	}
+	m.Locker.RLock()
+	files, ok := m.FilesMap[service.Info.ServiceName]
+	m.Locker.RUnlock()
+	if ok {
+		return files, nil
+	}
+
+	files, err := service.GRPCDescriptor.ParseFiles()
+	if err != nil {
+		return nil, err
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.FilesMap[service.Info.ServiceName] = files
+	return files, nil
+}
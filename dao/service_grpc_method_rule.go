@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"strings"
+)
+
+// GrpcMethodRule grpc方法级规则, 以 info.FullMethod(/pkg.Service/Method) 匹配
+// Method 支持精确匹配, 以及末尾通配: /pkg.Service/* 匹配服务下所有方法, /pkg.* 匹配包下所有服务, * 匹配全部
+type GrpcMethodRule struct {
+	ID         int64  `json:"id" gorm:"primary_key"`
+	ServiceID  int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Method     string `json:"method" gorm:"column:method" description:"方法匹配规则 /pkg.Service/Method, 支持末尾*通配"`
+	Forbid     int    `json:"forbid" gorm:"column:forbid" description:"禁止访问 1=禁止"`
+	OpenAuth   int    `json:"open_auth" gorm:"column:open_auth" description:"需要鉴权 1=需要"`
+	FlowLimit  int    `json:"flow_limit" gorm:"column:flow_limit" description:"方法限流qps 0=不限流"`
+	RoundType  int    `json:"round_type" gorm:"column:round_type" description:"轮询方式 round/weight_round/random/ip_hash"`
+	IpList     string `json:"ip_list" gorm:"column:ip_list" description:"独立下游ip列表, 为空时使用服务负载均衡"`
+	WeightList string `json:"weight_list" gorm:"column:weight_list" description:"权重列表"`
+}
+
+func (t *GrpcMethodRule) TableName() string {
+	return "gateway_service_grpc_method_rule"
+}
+
+func (t *GrpcMethodRule) Find(c *gin.Context, tx *gorm.DB, search *GrpcMethodRule) (*GrpcMethodRule, error) {
+	model := &GrpcMethodRule{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *GrpcMethodRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteByServiceID 删除服务下所有方法规则, 更新服务时整体覆盖
+func (t *GrpcMethodRule) DeleteByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) error {
+	return tx.SetCtx(public.GetGinTraceContext(c)).Where("service_id=?", serviceID).Delete(&GrpcMethodRule{}).Error
+}
+
+func (t *GrpcMethodRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]GrpcMethodRule, int64, error) {
+	var list []GrpcMethodRule
+	var count int64
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("service_id=?", serviceID)
+	err := query.Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	errCount := query.Count(&count).Error
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+	return list, count, nil
+}
+
+func (t *GrpcMethodRule) GetIPListByModel() []string {
+	return strings.Split(t.IpList, ",")
+}
+
+func (t *GrpcMethodRule) GetWeightListByModel() []string {
+	return strings.Split(t.WeightList, ",")
+}
+
+// matchLen 返回规则与方法的匹配长度, -1 表示不匹配; 精确匹配优先于任何通配
+func (t *GrpcMethodRule) matchLen(fullMethod string) int {
+	if !strings.HasSuffix(t.Method, "*") {
+		if t.Method == fullMethod {
+			return len(fullMethod) + 1
+		}
+		return -1
+	}
+	prefix := strings.TrimSuffix(t.Method, "*")
+	if strings.HasPrefix(fullMethod, prefix) {
+		return len(prefix)
+	}
+	return -1
+}
+
+// MatchGrpcMethodRule 选出与方法最具体匹配的规则, 没有匹配时返回nil
+func MatchGrpcMethodRule(rules []*GrpcMethodRule, fullMethod string) *GrpcMethodRule {
+	var matched *GrpcMethodRule
+	matchedLen := -1
+	for _, rule := range rules {
+		if l := rule.matchLen(fullMethod); l > matchedLen {
+			matched = rule
+			matchedLen = l
+		}
+	}
+	return matched
+}
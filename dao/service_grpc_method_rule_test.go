@@ -0,0 +1,27 @@
+package dao
+
+import "testing"
+
+func TestMatchGrpcMethodRule(t *testing.T) {
+	rules := []*GrpcMethodRule{
+		{ID: 1, Method: "*"},
+		{ID: 2, Method: "/pkg.*"},
+		{ID: 3, Method: "/pkg.Echo/*"},
+		{ID: 4, Method: "/pkg.Echo/Ping"},
+	}
+	cases := map[string]int64{
+		"/pkg.Echo/Ping":   4,
+		"/pkg.Echo/Stream": 3,
+		"/pkg.Other/Get":   2,
+		"/other.Echo/Ping": 1,
+	}
+	for method, want := range cases {
+		rule := MatchGrpcMethodRule(rules, method)
+		if rule == nil || rule.ID != want {
+			t.Fatalf("%s matched %+v, want rule %d", method, rule, want)
+		}
+	}
+	if rule := MatchGrpcMethodRule(rules[3:], "/pkg.Echo/Pong"); rule != nil {
+		t.Fatalf("unexpected match %+v", rule)
+	}
+}
@@ -10,7 +10,8 @@ type GrpcRule struct {
 	ID             int64  `json:"id" gorm:"primary_key"`
 	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id	"`
 	Port           int    `json:"port" gorm:"column:port" description:"端口	"`
-	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue"`
+	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"metadata转换规则, 格式与http服务相同"`
+	WebPrefix      string `json:"web_prefix" gorm:"column:web_prefix" description:"gRPC-Web/JSON接入前缀, 为空不开启"`
 }
 
 func (t *GrpcRule) TableName() string {
@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"github.com/yguilai/go-gateway/public"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HttpBodySchema http服务请求体的JSON Schema, 以请求方法与网关收到的路径匹配
+// Path 支持精确匹配与末尾通配: /api/users/* 匹配该前缀下所有路径; Method 为 * 时匹配所有方法
+type HttpBodySchema struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	ServiceID int64     `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Method    string    `json:"method" gorm:"column:method" description:"请求方法, *=全部"`
+	Path      string    `json:"path" gorm:"column:path" description:"路径匹配规则, 支持末尾*通配"`
+	FileName  string    `json:"file_name" gorm:"column:file_name" description:"上传的文件名"`
+	Schema    string    `json:"schema" gorm:"column:schema" description:"JSON Schema内容"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+}
+
+func (t *HttpBodySchema) TableName() string {
+	return "gateway_service_http_body_schema"
+}
+
+func (t *HttpBodySchema) Find(c *gin.Context, tx *gorm.DB, search *HttpBodySchema) (*HttpBodySchema, error) {
+	model := &HttpBodySchema{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *HttpBodySchema) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *HttpBodySchema) Delete(c *gin.Context, tx *gorm.DB) error {
+	return tx.SetCtx(public.GetGinTraceContext(c)).Where("id=?", t.ID).Delete(&HttpBodySchema{}).Error
+}
+
+func (t *HttpBodySchema) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]HttpBodySchema, error) {
+	var list []HttpBodySchema
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where("service_id=?", serviceID).Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Compile 解析schema, 上传时校验与请求时缓存均使用
+func (t *HttpBodySchema) Compile() (*gojsonschema.Schema, error) {
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(t.Schema))
+	if err != nil {
+		return nil, errors.WithMessage(err, "compile json schema")
+	}
+	return schema, nil
+}
+
+// matchLen 返回规则与路径的匹配长度, -1 表示不匹配; 精确匹配优先于通配
+func (t *HttpBodySchema) matchLen(method, path string) int {
+	if t.Method != "*" && !strings.EqualFold(t.Method, method) {
+		return -1
+	}
+	if !strings.HasSuffix(t.Path, "*") {
+		if t.Path == path {
+			return len(path) + 1
+		}
+		return -1
+	}
+	prefix := strings.TrimSuffix(t.Path, "*")
+	if strings.HasPrefix(path, prefix) {
+		return len(prefix)
+	}
+	return -1
+}
+
+// MatchHttpBodySchema 选出与请求最具体匹配的schema, 没有匹配时返回nil
+func MatchHttpBodySchema(schemas []*HttpBodySchema, method, path string) *HttpBodySchema {
+	var matched *HttpBodySchema
+	matchedLen := -1
+	for _, schema := range schemas {
+		if l := schema.matchLen(method, path); l > matchedLen {
+			matched = schema
+			matchedLen = l
+		}
+	}
+	return matched
+}
+
+var HttpBodySchemaHandler *HttpBodySchemaManager
+
+// HttpBodySchemaManager 缓存编译后的schema, 避免每个请求重复解析
+type HttpBodySchemaManager struct {
+	SchemaMap map[string]*gojsonschema.Schema
+	Locker    sync.RWMutex
+}
+
+func NewHttpBodySchemaManager() *HttpBodySchemaManager {
+	return &HttpBodySchemaManager{
+		SchemaMap: map[string]*gojsonschema.Schema{},
+		Locker:    sync.RWMutex{},
+	}
+}
+
+func init() {
+	HttpBodySchemaHandler = NewHttpBodySchemaManager()
+}
+
+// GetSchema 以id与更新时间为key, schema更新后重新编译
+func (m *HttpBodySchemaManager) GetSchema(item *HttpBodySchema) (*gojsonschema.Schema, error) {
+	key := strconv.FormatInt(item.ID, 10) + "_" + strconv.FormatInt(item.UpdatedAt.UnixNano(), 10)
+	m.Locker.RLock()
+	schema, ok := m.SchemaMap[key]
+	m.Locker.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := item.Compile()
+	if err != nil {
+		return nil, err
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.SchemaMap[key] = schema
+	return schema, nil
+}
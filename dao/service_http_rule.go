@@ -3,7 +3,12 @@ package dao
 import (
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/geoip"
 	"github.com/yguilai/go-gateway/public"
+	"log"
+	"mime"
+	"strconv"
+	"strings"
 )
 
 type HttpRule struct {
@@ -14,8 +19,25 @@ type HttpRule struct {
 	NeedHttps      int    `json:"need_https" gorm:"column:need_https" description:"type=支持https 1=支持"`
 	NeedWebsocket  int    `json:"need_websocket" gorm:"column:need_websocket" description:"启用websocket 1=启用"`
 	NeedStripUri   int    `json:"need_strip_uri" gorm:"column:need_strip_uri" description:"启用strip_uri 1=启用"`
-	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写功能，每行一个	"`
-	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue	"`
+	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写规则, 每行一条: regex|path 匹配 目标 [last] [drop_query] [host=域名], 兼容 正则 替换路径"`
+	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换规则, 每行一条: [req|resp] set|append|del|rename 名称 [值] [if 条件], 兼容 add headname headvalue"`
+	CertID         int64  `json:"cert_id" gorm:"column:cert_id" description:"域名绑定的证书id, 0=使用默认证书"`
+
+	ForceHttps            int `json:"force_https" gorm:"column:force_https" description:"客户端强制https, http请求重定向到https 1=开启"`
+	HstsMaxAge            int `json:"hsts_max_age" gorm:"column:hsts_max_age" description:"https响应的Strict-Transport-Security max-age, 单位s, 0=不发送"`
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" gorm:"column:hsts_include_subdomains" description:"hsts包含子域名 1=包含"`
+	HstsPreload           int `json:"hsts_preload" gorm:"column:hsts_preload" description:"hsts preload 1=开启"`
+	ForwardedPolicy       int `json:"forwarded_policy" gorm:"column:forwarded_policy" description:"转发头策略 0=追加 1=覆盖 2=剔除"`
+
+	GeoCountry string `json:"geo_country" gorm:"column:geo_country" description:"路由条件, 仅匹配来自这些国家的请求, 逗号间隔, 为空不限制"`
+
+	MaxBodySize      int64  `json:"max_body_size" gorm:"column:max_body_size" description:"请求体最大字节数, 0=不限制"`
+	AllowContentType string `json:"allow_content_type" gorm:"column:allow_content_type" description:"允许的请求体类型, 逗号间隔, 支持 type/* 通配, 为空不限制"`
+
+	ResponseRewrite string `json:"response_rewrite" gorm:"column:response_rewrite" description:"响应体改写规则, 每行一条: replace|regex|json_del|json_rename"`
+	RewriteLocation int    `json:"rewrite_location" gorm:"column:rewrite_location" description:"下游返回的Location与Set-Cookie改写为网关地址 1=开启"`
+
+	geoCountries map[string]bool
 }
 
 func (t *HttpRule) TableName() string {
@@ -51,3 +73,71 @@ func (t *HttpRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64)
 	}
 	return list, count, nil
 }
+
+// HstsHeader Strict-Transport-Security 的值, 未开启时为空
+func (t *HttpRule) HstsHeader() string {
+	if t.HstsMaxAge <= 0 {
+		return ""
+	}
+	header := "max-age=" + strconv.Itoa(t.HstsMaxAge)
+	if t.HstsIncludeSubdomains == 1 {
+		header += "; includeSubDomains"
+	}
+	if t.HstsPreload == 1 {
+		header += "; preload"
+	}
+	return header
+}
+
+// MatchRule 按接入类型匹配域名或url前缀
+func (t *HttpRule) MatchRule(host, path string) bool {
+	if t.RuleType == public.HTTPRuleTypeDomain {
+		return t.Rule == host
+	}
+	if t.RuleType == public.HTTPRuleTypePrefixURL {
+		return strings.HasPrefix(path, t.Rule)
+	}
+	return false
+}
+
+// CompileGeoCountry 载入服务时解析国家条件, 保存时已校验格式, 解析失败时不匹配任何国家
+func (t *HttpRule) CompileGeoCountry() {
+	if t.GeoCountry == "" {
+		return
+	}
+	countryMap, err := geoip.ParseCountries(t.GeoCountry)
+	if err != nil {
+		log.Printf(" [WARN] service %d geo country err:%v\n", t.ServiceID, err)
+		countryMap = map[string]bool{}
+	}
+	t.geoCountries = countryMap
+}
+
+// MatchCountry 未配置国家条件时全部匹配, 国家未知时不匹配带条件的服务
+func (t *HttpRule) MatchCountry(country string) bool {
+	if t.GeoCountry == "" {
+		return true
+	}
+	return t.geoCountries[country]
+}
+
+// AllowContentTypeOf 未配置时全部允许, contentType 为空时不允许
+func (t *HttpRule) AllowContentTypeOf(contentType string) bool {
+	if strings.TrimSpace(t.AllowContentType) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, item := range strings.Split(t.AllowContentType, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == mediaType {
+			return true
+		}
+		if strings.HasSuffix(item, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(item, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package dao
+
+import "testing"
+
+func TestHttpRuleMatchCountry(t *testing.T) {
+	rule := &HttpRule{GeoCountry: "cn, us"}
+	rule.CompileGeoCountry()
+	for country, want := range map[string]bool{"CN": true, "US": true, "JP": false, "": false} {
+		if got := rule.MatchCountry(country); got != want {
+			t.Errorf("country %q: want %v, got %v", country, want, got)
+		}
+	}
+	if !(&HttpRule{}).MatchCountry("") {
+		t.Errorf("rule without country should match all")
+	}
+	invalid := &HttpRule{GeoCountry: "china"}
+	invalid.CompileGeoCountry()
+	if invalid.MatchCountry("CN") {
+		t.Errorf("invalid country rule should match nothing")
+	}
+}
@@ -36,6 +36,7 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
+	httpRule.CompileGeoCountry()
 
 	tcpRule := &TcpRule{ServiceID: search.ID}
 	tcpRule, err = tcpRule.Find(c, tx, tcpRule)
@@ -49,6 +50,37 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 		return nil, err
 	}
 
+	grpcMethodRules := []*GrpcMethodRule{}
+	var grpcDescriptor *GrpcDescriptor
+	if search.LoadType == public.LoadTypeGRPC {
+		grpcDescriptor = &GrpcDescriptor{ServiceID: search.ID}
+		grpcDescriptor, err = grpcDescriptor.Find(c, tx, grpcDescriptor)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+
+		list, _, err := (&GrpcMethodRule{}).ListByServiceID(c, tx, search.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			tmpItem := item
+			grpcMethodRules = append(grpcMethodRules, &tmpItem)
+		}
+	}
+
+	httpBodySchemas := []*HttpBodySchema{}
+	if search.LoadType == public.LoadTypeHTTP {
+		list, err := (&HttpBodySchema{}).ListByServiceID(c, tx, search.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			tmpItem := item
+			httpBodySchemas = append(httpBodySchemas, &tmpItem)
+		}
+	}
+
 	loadBalanceRule := &LoadBalance{ServiceID: search.ID}
 	loadBalanceRule, err = loadBalanceRule.Find(c, tx, loadBalanceRule)
 	if err != nil && err != gorm.ErrRecordNotFound {
@@ -61,13 +93,37 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 		return nil, err
 	}
 
+	oidcRule := &OidcRule{ServiceID: search.ID}
+	oidcRule, err = oidcRule.Find(c, tx, oidcRule)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	mtlsRule := &MtlsRule{ServiceID: search.ID}
+	mtlsRule, err = mtlsRule.Find(c, tx, mtlsRule)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	mirrorRule := &MirrorRule{ServiceID: search.ID}
+	mirrorRule, err = mirrorRule.Find(c, tx, mirrorRule)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
 	return &ServiceDetail{
-		Info:          search,
-		HTTPRule:      httpRule,
-		TCPRule:       tcpRule,
-		GRPCRule:      grpcRule,
-		LoadBalance:   loadBalanceRule,
-		AccessControl: accessControlRule,
+		Info:            search,
+		HTTPRule:        httpRule,
+		TCPRule:         tcpRule,
+		GRPCRule:        grpcRule,
+		GRPCMethodRules: grpcMethodRules,
+		GRPCDescriptor:  grpcDescriptor,
+		LoadBalance:     loadBalanceRule,
+		AccessControl:   accessControlRule,
+		OidcRule:        oidcRule,
+		MtlsRule:        mtlsRule,
+		HTTPBodySchemas: httpBodySchemas,
+		MirrorRule:      mirrorRule,
 	}, nil
 }
 
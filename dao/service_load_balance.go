@@ -1,11 +1,15 @@
 package dao
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/public"
 	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"golang.org/x/net/http2"
 	"net"
 	"net/http"
 	"strings"
@@ -28,6 +32,13 @@ type LoadBalance struct {
 	UpstreamHeaderTimeout  int `json:"upstream_header_timeout" gorm:"column:upstream_header_timeout" description:"下游获取header超时, 单位s	"`
 	UpstreamIdleTimeout    int `json:"upstream_idle_timeout" gorm:"column:upstream_idle_timeout" description:"下游链接最大空闲时间, 单位s	"`
 	UpstreamMaxIdle        int `json:"upstream_max_idle" gorm:"column:upstream_max_idle" description:"下游最大空闲链接数"`
+
+	UpstreamTLSCaCert     string `json:"upstream_tls_ca_cert" gorm:"column:upstream_tls_ca_cert" description:"校验下游证书的PEM格式CA证书, 为空时使用系统CA"`
+	UpstreamTLSCert       string `json:"upstream_tls_cert" gorm:"column:upstream_tls_cert" description:"下游要求客户端证书时使用的PEM格式证书"`
+	UpstreamTLSKey        string `json:"-" gorm:"column:upstream_tls_key" description:"下游要求客户端证书时使用的PEM格式私钥"`
+	UpstreamTLSServerName string `json:"upstream_tls_server_name" gorm:"column:upstream_tls_server_name" description:"覆盖发送给下游的SNI, 同时用于校验下游证书"`
+	UpstreamTLSInsecure   int    `json:"upstream_tls_insecure" gorm:"column:upstream_tls_insecure" description:"不校验下游证书 1=不校验, 仅用于开发环境"`
+	UpstreamH2c           int    `json:"upstream_h2c" gorm:"column:upstream_h2c" description:"使用明文http2连接下游 1=开启"`
 }
 
 func (t *LoadBalance) TableName() string {
@@ -47,6 +58,29 @@ func (t *LoadBalance) Save(c *gin.Context, tx *gorm.DB) error {
 	return nil
 }
 
+// UpstreamTLSConfig 连接https下游使用的tls配置
+func (t *LoadBalance) UpstreamTLSConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         t.UpstreamTLSServerName,
+		InsecureSkipVerify: t.UpstreamTLSInsecure == 1,
+	}
+	if t.UpstreamTLSCaCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.UpstreamTLSCaCert)) {
+			return nil, errors.New("upstream tls ca cert invalid")
+		}
+		conf.RootCAs = pool
+	}
+	if t.UpstreamTLSCert != "" || t.UpstreamTLSKey != "" {
+		pair, err := tls.X509KeyPair([]byte(t.UpstreamTLSCert), []byte(t.UpstreamTLSKey))
+		if err != nil {
+			return nil, errors.WithMessage(err, "upstream tls client cert")
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+	return conf, nil
+}
+
 func (t *LoadBalance) GetIPListByModel() []string {
 	return strings.Split(t.IpList, ",")
 }
@@ -64,8 +98,9 @@ type LoadBalancer struct {
 }
 
 type LoadBalancerItem struct {
-	LoadBalance load_balance.LoadBalance
-	ServiceName string
+	LoadBalance     load_balance.LoadBalance
+	LoadBalanceConf load_balance.LoadBalanceConf
+	ServiceName     string
 }
 
 func NewLoadBalancer() *LoadBalancer {
@@ -93,30 +128,74 @@ func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.L
 	if service.Info.LoadType == public.LoadTypeTCP || service.Info.LoadType == public.LoadTypeGRPC {
 		schema = ""
 	}
-	ipList := service.LoadBalance.GetIPListByModel()
-	weightList := service.LoadBalance.GetWeightListByModel()
-	ipConf := map[string]string{}
-	for ipIndex, ipItem := range ipList {
-		ipConf[ipItem] = weightList[ipIndex]
-	}
-	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf)
+	lbItem, err := newLoadBalancerItem(service.Info.ServiceName, schema,
+		service.LoadBalance.GetIPListByModel(),
+		service.LoadBalance.GetWeightListByModel(),
+		service.LoadBalance.RoundType)
 	if err != nil {
 		return nil, err
 	}
-	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbType(service.LoadBalance.RoundType), mConf)
+	lbr.LoadBalanceSlice = append(lbr.LoadBalanceSlice, lbItem)
 
-	lbItem := &LoadBalancerItem{
-		LoadBalance: lb,
-		ServiceName: service.Info.ServiceName,
+	lbr.Locker.Lock()
+	defer lbr.Locker.Unlock()
+	lbr.LoadBalanceMap[service.Info.ServiceName] = lbItem
+	return lbItem.LoadBalance, nil
+}
+
+// GetGrpcMethodLoadBalancer 获取grpc方法规则独立的下游节点组
+func (lbr *LoadBalancer) GetGrpcMethodLoadBalancer(service *ServiceDetail, rule *GrpcMethodRule) (*LoadBalancerItem, error) {
+	lbName := fmt.Sprintf("%s_method_%d", service.Info.ServiceName, rule.ID)
+	for _, lbrItem := range lbr.LoadBalanceSlice {
+		if lbrItem.ServiceName == lbName {
+			return lbrItem, nil
+		}
+	}
+	lbItem, err := newLoadBalancerItem(lbName, "", rule.GetIPListByModel(), rule.GetWeightListByModel(), rule.RoundType)
+	if err != nil {
+		return nil, err
 	}
 	lbr.LoadBalanceSlice = append(lbr.LoadBalanceSlice, lbItem)
 
 	lbr.Locker.Lock()
 	defer lbr.Locker.Unlock()
-	lbr.LoadBalanceMap[service.Info.ServiceName] = lbItem
-	return lb, nil
+	lbr.LoadBalanceMap[lbName] = lbItem
+	return lbItem, nil
+}
+
+func newLoadBalancerItem(name, schema string, ipList, weightList []string, roundType int) (*LoadBalancerItem, error) {
+	if len(ipList) != len(weightList) {
+		return nil, errors.New("ip list and weight list not match")
+	}
+	ipConf := map[string]string{}
+	for ipIndex, ipItem := range ipList {
+		ipConf[ipItem] = weightList[ipIndex]
+	}
+	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf)
+	if err != nil {
+		return nil, err
+	}
+	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbType(roundType), mConf)
+	return &LoadBalancerItem{
+		LoadBalance:     lb,
+		LoadBalanceConf: mConf,
+		ServiceName:     name,
+	}, nil
 }
 
+// GetLoadBalanceConf 获取服务负载均衡所观察的节点配置
+func (lbr *LoadBalancer) GetLoadBalanceConf(service *ServiceDetail) (load_balance.LoadBalanceConf, error) {
+	if _, err := lbr.GetLoadBalancer(service); err != nil {
+		return nil, err
+	}
+	lbr.Locker.RLock()
+	defer lbr.Locker.RUnlock()
+	lbItem, ok := lbr.LoadBalanceMap[service.Info.ServiceName]
+	if !ok {
+		return nil, errors.New("load balance conf not found")
+	}
+	return lbItem.LoadBalanceConf, nil
+}
 
 var TransportorHandler *Transportor
 
@@ -127,7 +206,7 @@ type Transportor struct {
 }
 
 type TransportItem struct {
-	Trans       *http.Transport
+	Trans       http.RoundTripper
 	ServiceName string
 }
 
@@ -143,7 +222,7 @@ func init() {
 	TransportorHandler = NewTransportor()
 }
 
-func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error) {
+func (t *Transportor) GetTrans(service *ServiceDetail) (http.RoundTripper, error) {
 	for _, transItem := range t.TransportSlice {
 		if transItem.ServiceName == service.Info.ServiceName {
 			return transItem.Trans, nil
@@ -151,30 +230,56 @@ func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error)
 	}
 
 	//todo 优化点5
-	if service.LoadBalance.UpstreamConnectTimeout==0{
+	if service.LoadBalance.UpstreamConnectTimeout == 0 {
 		service.LoadBalance.UpstreamConnectTimeout = 30
 	}
-	if service.LoadBalance.UpstreamMaxIdle==0{
+	if service.LoadBalance.UpstreamMaxIdle == 0 {
 		service.LoadBalance.UpstreamMaxIdle = 100
 	}
-	if service.LoadBalance.UpstreamIdleTimeout==0{
+	if service.LoadBalance.UpstreamIdleTimeout == 0 {
 		service.LoadBalance.UpstreamIdleTimeout = 90
 	}
-	if service.LoadBalance.UpstreamHeaderTimeout==0{
+	if service.LoadBalance.UpstreamHeaderTimeout == 0 {
 		service.LoadBalance.UpstreamHeaderTimeout = 30
 	}
-	trans := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout: time.Duration(service.LoadBalance.UpstreamConnectTimeout)*time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          service.LoadBalance.UpstreamMaxIdle,
-		IdleConnTimeout:       time.Duration(service.LoadBalance.UpstreamIdleTimeout)*time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout)*time.Second,
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(service.LoadBalance.UpstreamConnectTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}
+	var trans http.RoundTripper
+	if service.LoadBalance.UpstreamH2c == 1 {
+		//h2c: 以http2协议直接发送明文请求, 不经过tls协商
+		//http2.Transport 的空闲连接与响应头超时取自关联的 http.Transport
+		h2Trans, err := http2.ConfigureTransports(&http.Transport{
+			IdleConnTimeout:       time.Duration(service.LoadBalance.UpstreamIdleTimeout) * time.Second,
+			ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout) * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+		//ConfigureTransports 的连接池只复用 http.Transport 建立的连接, 改用自行拨号的默认连接池
+		h2Trans.ConnPool = nil
+		h2Trans.AllowHTTP = true
+		h2Trans.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		}
+		trans = h2Trans
+	} else {
+		tlsConf, err := service.LoadBalance.UpstreamTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		trans = &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       tlsConf,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          service.LoadBalance.UpstreamMaxIdle,
+			IdleConnTimeout:       time.Duration(service.LoadBalance.UpstreamIdleTimeout) * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout) * time.Second,
+		}
 	}
 
 	//save to map and slice
@@ -188,4 +293,3 @@ func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error)
 	t.TransportMap[service.Info.ServiceName] = transItem
 	return trans, nil
 }
-
@@ -0,0 +1,159 @@
+package dao
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransportorUpstreamTLS(t *testing.T) {
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "upstream ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca, caKey := newTestCert(t, caTemplate, nil, nil)
+	client, clientKey := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "gateway"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	clientKeyDer, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(ca)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName + " " + r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+	server.StartTLS()
+	defer server.Close()
+
+	detail := &ServiceDetail{
+		Info: &ServiceInfo{ServiceName: "test_upstream_tls"},
+		LoadBalance: &LoadBalance{
+			UpstreamTLSCaCert:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+			UpstreamTLSCert:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.Raw})),
+			UpstreamTLSKey:        string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: clientKeyDer})),
+			UpstreamTLSServerName: "example.com",
+		},
+	}
+	trans, err := NewTransportor().GetTrans(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: trans}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	resp.Body.Close()
+	if string(body[:n]) != "example.com gateway" {
+		t.Fatalf("unexpected upstream response %q", body[:n])
+	}
+
+	detail.LoadBalance.UpstreamTLSCaCert = ""
+	detail.Info.ServiceName = "test_upstream_tls_untrusted"
+	trans, err = NewTransportor().GetTrans(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: trans}).Get(server.URL); err == nil {
+		t.Fatal("upstream cert signed by unknown ca should fail")
+	}
+}
+
+func TestTransportorUpstreamH2c(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	detail := &ServiceDetail{
+		Info:        &ServiceInfo{ServiceName: "test_upstream_h2c"},
+		LoadBalance: &LoadBalance{UpstreamH2c: 1},
+	}
+	trans, err := NewTransportor().GetTrans(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: trans}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("upstream should be requested over http2, got %s", resp.Proto)
+	}
+}
+
+func TestTransportorUpstreamH2cTimeout(t *testing.T) {
+	var connCount int32
+	server := httptest.NewUnstartedServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}), &http2.Server{}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connCount, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	detail := &ServiceDetail{
+		Info:        &ServiceInfo{ServiceName: "test_upstream_h2c_timeout"},
+		LoadBalance: &LoadBalance{UpstreamH2c: 1, UpstreamHeaderTimeout: 1, UpstreamIdleTimeout: 1},
+	}
+	trans, err := NewTransportor().GetTrans(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: trans}
+
+	//空闲超过 UpstreamIdleTimeout 的连接被关闭, 之后的请求建立新连接
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		time.Sleep(1500 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&connCount); n != 2 {
+		t.Fatalf("idle h2c connection should be closed, got %d connections", n)
+	}
+
+	//下游超过 UpstreamHeaderTimeout 未返回响应头时请求失败
+	start := time.Now()
+	if _, err := client.Get(server.URL + "/hang"); err == nil {
+		t.Fatal("hung h2c upstream should time out")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("header timeout not applied, took %s", elapsed)
+	}
+}
@@ -0,0 +1,178 @@
+package dao
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MirrorRule http服务的流量复制, 按比例将请求异步发送到影子节点组, 影子响应直接丢弃
+// 影子请求使用独立的超时与连接, 不影响正常请求的响应
+type MirrorRule struct {
+	ID          int64  `json:"id" gorm:"primary_key"`
+	ServiceID   int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Enable      int    `json:"enable" gorm:"column:enable" description:"是否开启 1=开启"`
+	Percent     int    `json:"percent" gorm:"column:percent" description:"复制比例, 1-100"`
+	RoundType   int    `json:"round_type" gorm:"column:round_type" description:"影子节点轮询方式 round/weight_round/random/ip_hash"`
+	IpList      string `json:"ip_list" gorm:"column:ip_list" description:"影子节点列表, 逗号间隔"`
+	WeightList  string `json:"weight_list" gorm:"column:weight_list" description:"影子节点权重列表"`
+	Timeout     int    `json:"timeout" gorm:"column:timeout" description:"影子请求超时, 包含连接与读取响应, 单位s, 0=5s"`
+	MaxBodySize int64  `json:"max_body_size" gorm:"column:max_body_size" description:"复制的请求体上限, 超过时不复制, 0=1MB"`
+}
+
+const (
+	mirrorDefaultTimeout     = 5
+	mirrorDefaultMaxBodySize = 1 << 20
+)
+
+func (t *MirrorRule) TableName() string {
+	return "gateway_service_mirror"
+}
+
+func (t *MirrorRule) Find(c *gin.Context, tx *gorm.DB, search *MirrorRule) (*MirrorRule, error) {
+	model := &MirrorRule{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *MirrorRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *MirrorRule) IsEnable() bool {
+	return t != nil && t.Enable == 1 && t.Percent > 0 && t.IpList != ""
+}
+
+func (t *MirrorRule) GetIPListByModel() []string {
+	return strings.Split(t.IpList, ",")
+}
+
+func (t *MirrorRule) GetWeightListByModel() []string {
+	return strings.Split(t.WeightList, ",")
+}
+
+func (t *MirrorRule) GetTimeout() time.Duration {
+	if t.Timeout <= 0 {
+		return mirrorDefaultTimeout * time.Second
+	}
+	return time.Duration(t.Timeout) * time.Second
+}
+
+func (t *MirrorRule) GetMaxBodySize() int64 {
+	if t.MaxBodySize <= 0 {
+		return mirrorDefaultMaxBodySize
+	}
+	return t.MaxBodySize
+}
+
+// mirrorCacheName 影子节点组与连接池按复制规则内容命名, 规则修改后的服务详情使用新的名称重新创建
+func mirrorCacheName(service *ServiceDetail) string {
+	rule := service.MirrorRule
+	sum := md5.Sum([]byte(fmt.Sprintf("%d|%s|%s|%d|%d", service.HTTPRule.NeedHttps, rule.IpList, rule.WeightList, rule.RoundType, rule.Timeout)))
+	return mirrorCachePrefix(service) + hex.EncodeToString(sum[:4])
+}
+
+// 服务名只含字母、数字与下划线, 以 # 分隔避免与其他服务的名称冲突
+func mirrorCachePrefix(service *ServiceDetail) string {
+	return service.Info.ServiceName + "#mirror#"
+}
+
+// GetMirrorLoadBalancer 获取影子节点组的负载均衡, 协议与服务的下游一致
+// 复制规则变化时替换该服务之前创建的节点组
+func (lbr *LoadBalancer) GetMirrorLoadBalancer(service *ServiceDetail) (load_balance.LoadBalance, error) {
+	lbName := mirrorCacheName(service)
+	lbr.Locker.RLock()
+	lbItem, ok := lbr.LoadBalanceMap[lbName]
+	lbr.Locker.RUnlock()
+	if ok {
+		return lbItem.LoadBalance, nil
+	}
+	schema := "http://"
+	if service.HTTPRule.NeedHttps == 1 {
+		schema = "https://"
+	}
+	rule := service.MirrorRule
+	lbItem, err := newLoadBalancerItem(lbName, schema, rule.GetIPListByModel(), rule.GetWeightListByModel(), rule.RoundType)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := mirrorCachePrefix(service)
+	lbr.Locker.Lock()
+	defer lbr.Locker.Unlock()
+	slice := []*LoadBalancerItem{}
+	for _, item := range lbr.LoadBalanceSlice {
+		if strings.HasPrefix(item.ServiceName, prefix) {
+			delete(lbr.LoadBalanceMap, item.ServiceName)
+			continue
+		}
+		slice = append(slice, item)
+	}
+	lbr.LoadBalanceSlice = append(slice, lbItem)
+	lbr.LoadBalanceMap[lbName] = lbItem
+	return lbItem.LoadBalance, nil
+}
+
+// GetMirrorTrans 影子请求使用独立的连接池, 连接与响应超时取复制规则的超时
+// 复制规则变化时替换之前的连接池并关闭其空闲连接
+func (t *Transportor) GetMirrorTrans(service *ServiceDetail) (http.RoundTripper, error) {
+	transName := mirrorCacheName(service)
+	t.Locker.RLock()
+	transItem, ok := t.TransportMap[transName]
+	t.Locker.RUnlock()
+	if ok {
+		return transItem.Trans, nil
+	}
+
+	tlsConf, err := service.LoadBalance.UpstreamTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	timeout := service.MirrorRule.GetTimeout()
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	trans := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConf,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+	}
+
+	prefix := mirrorCachePrefix(service)
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	slice := []*TransportItem{}
+	for _, item := range t.TransportSlice {
+		if strings.HasPrefix(item.ServiceName, prefix) {
+			delete(t.TransportMap, item.ServiceName)
+			if old, ok := item.Trans.(*http.Transport); ok {
+				old.CloseIdleConnections()
+			}
+			continue
+		}
+		slice = append(slice, item)
+	}
+	transItem = &TransportItem{
+		Trans:       trans,
+		ServiceName: transName,
+	}
+	t.TransportSlice = append(slice, transItem)
+	t.TransportMap[transName] = transItem
+	return trans, nil
+}
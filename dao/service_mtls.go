@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/public"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	MtlsAppFieldCN    = "cn"
+	MtlsAppFieldDNS   = "san_dns"
+	MtlsAppFieldURI   = "san_uri"
+	MtlsAppFieldEmail = "san_email"
+
+	MtlsSubjectHeader     = "X-Client-Cert-Subject"
+	MtlsCNHeader          = "X-Client-Cert-Cn"
+	MtlsSANHeader         = "X-Client-Cert-San"
+	MtlsFingerprintHeader = "X-Client-Cert-Fingerprint"
+)
+
+// MtlsHeaders 转发给下游的客户端证书信息, 客户端携带的同名请求头会被清除
+var MtlsHeaders = []string{MtlsSubjectHeader, MtlsCNHeader, MtlsSANHeader, MtlsFingerprintHeader}
+
+// MtlsRule 服务要求客户端证书, 证书需由 CaCert 中的CA签发
+// AppField 指定用于映射租户id的证书字段, 映射成功时使用租户限流与统计
+type MtlsRule struct {
+	ID        int64  `json:"id" gorm:"primary_key"`
+	ServiceID int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Enable    int    `json:"enable" gorm:"column:enable" description:"是否开启 1=开启"`
+	CaCert    string `json:"ca_cert" gorm:"column:ca_cert" description:"PEM格式CA证书, 可包含多个"`
+	AppField  string `json:"app_field" gorm:"column:app_field" description:"映射租户id的证书字段 cn/san_dns/san_uri/san_email"`
+
+	poolOnce sync.Once
+	pool     *x509.CertPool
+	poolErr  error
+}
+
+func (t *MtlsRule) TableName() string {
+	return "gateway_service_mtls"
+}
+
+func (t *MtlsRule) Find(c *gin.Context, tx *gorm.DB, search *MtlsRule) (*MtlsRule, error) {
+	model := &MtlsRule{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *MtlsRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *MtlsRule) IsEnable() bool {
+	return t != nil && t.Enable == 1
+}
+
+// CertPool 解析后的CA证书
+func (t *MtlsRule) CertPool() (*x509.CertPool, error) {
+	t.poolOnce.Do(func() {
+		t.pool = x509.NewCertPool()
+		if !t.pool.AppendCertsFromPEM([]byte(t.CaCert)) {
+			t.poolErr = errors.New("mtls ca cert invalid")
+		}
+	})
+	return t.pool, t.poolErr
+}
+
+// Verify 使用服务的CA校验客户端证书链, 返回客户端证书
+func (t *MtlsRule) Verify(certs []*x509.Certificate) (*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("client certificate required")
+	}
+	pool, err := t.CertPool()
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, errors.WithMessage(err, "verify client certificate")
+	}
+	return certs[0], nil
+}
+
+// AppID 通过证书字段映射的租户id, SAN取第一个值
+func (t *MtlsRule) AppID(cert *x509.Certificate) string {
+	switch t.AppField {
+	case MtlsAppFieldDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case MtlsAppFieldURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case MtlsAppFieldEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	default:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+func (t *MtlsRule) GetApp(cert *x509.Certificate) (*App, bool) {
+	appID := t.AppID(cert)
+	if appID == "" {
+		return nil, false
+	}
+	appInfo, err := AppManagerHandler.GetApp(appID)
+	if err != nil {
+		log.Printf(" [ERROR] get app %s err:%v\n", appID, err)
+		return nil, false
+	}
+	return appInfo, appInfo != nil
+}
+
+// ForwardHeaders 客户端证书的身份信息
+func (t *MtlsRule) ForwardHeaders(cert *x509.Certificate) map[string]string {
+	sans := []string{}
+	for _, item := range cert.DNSNames {
+		sans = append(sans, "DNS:"+item)
+	}
+	for _, item := range cert.URIs {
+		sans = append(sans, "URI:"+item.String())
+	}
+	for _, item := range cert.EmailAddresses {
+		sans = append(sans, "email:"+item)
+	}
+	fingerprint := sha256.Sum256(cert.Raw)
+	return map[string]string{
+		MtlsSubjectHeader:     cert.Subject.String(),
+		MtlsCNHeader:          cert.Subject.CommonName,
+		MtlsSANHeader:         strings.Join(sans, ","),
+		MtlsFingerprintHeader: hex.EncodeToString(fingerprint[:]),
+	}
+}
@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/yguilai/go-gateway/public"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestMtlsRuleVerify(t *testing.T) {
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca, caKey := newTestCert(t, caTemplate, nil, nil)
+	otherCA, otherKey := newTestCert(t, caTemplate, nil, nil)
+	clientTemplate := func(usage x509.ExtKeyUsage) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "app_mtls", Organization: []string{"partner"}},
+			DNSNames:     []string{"client.partner.local"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+	}
+	client, _ := newTestCert(t, clientTemplate(x509.ExtKeyUsageClientAuth), ca, caKey)
+	serverOnly, _ := newTestCert(t, clientTemplate(x509.ExtKeyUsageServerAuth), ca, caKey)
+	untrusted, _ := newTestCert(t, clientTemplate(x509.ExtKeyUsageClientAuth), otherCA, otherKey)
+
+	origin := public.JwtRevokeHandler
+	defer func() { public.JwtRevokeHandler = origin }()
+	public.JwtRevokeHandler = public.NewJwtRevokeManager(public.NewMemoryJwtRevokeStore())
+	app := &App{AppID: "app_mtls"}
+	AppManagerHandler.AppMap[app.AppID] = app
+	mtlsRule := &MtlsRule{
+		Enable: 1,
+		CaCert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+	}
+
+	cert, err := mtlsRule.Verify([]*x509.Certificate{client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapped, ok := mtlsRule.GetApp(cert); !ok || mapped != app {
+		t.Fatal("client cert cn should map to app")
+	}
+	headers := mtlsRule.ForwardHeaders(cert)
+	if headers[MtlsCNHeader] != "app_mtls" || headers[MtlsSANHeader] != "DNS:client.partner.local" || len(headers[MtlsFingerprintHeader]) != 64 {
+		t.Fatalf("unexpected forward headers %v", headers)
+	}
+	mtlsRule.AppField = MtlsAppFieldDNS
+	if mtlsRule.AppID(cert) != "client.partner.local" {
+		t.Fatalf("unexpected app id %s", mtlsRule.AppID(cert))
+	}
+
+	for name, certs := range map[string][]*x509.Certificate{
+		"empty":       nil,
+		"server only": {serverOnly},
+		"untrusted":   {untrusted},
+	} {
+		if _, err := mtlsRule.Verify(certs); err == nil {
+			t.Fatalf("%s cert should be rejected", name)
+		}
+	}
+	if _, err := (&MtlsRule{CaCert: "invalid"}).CertPool(); err == nil {
+		t.Fatal("invalid ca should be rejected")
+	}
+}
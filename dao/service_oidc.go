@@ -0,0 +1,201 @@
+package dao
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/public"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OidcRule 服务使用外部身份提供方签发的token鉴权
+// 开启后不再接受网关签发的token, 通过 AppClaim 映射到租户以使用租户限流与统计
+type OidcRule struct {
+	ID             int64  `json:"id" gorm:"primary_key"`
+	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Enable         int    `json:"enable" gorm:"column:enable" description:"是否开启 1=开启"`
+	Issuer         string `json:"issuer" gorm:"column:issuer" description:"签发方, 校验iss"`
+	JwksURL        string `json:"jwks_url" gorm:"column:jwks_url" description:"公钥地址, 为空时通过issuer自动发现"`
+	Audience       string `json:"audience" gorm:"column:audience" description:"接受的aud, 逗号间隔, 满足其一即可"`
+	RequiredClaims string `json:"required_claims" gorm:"column:required_claims" description:"必须的claim, 逗号间隔, 支持 claim=value"`
+	AppClaim       string `json:"app_claim" gorm:"column:app_claim" description:"映射租户id的claim, 为空使用azp"`
+	ForwardClaims  string `json:"forward_claims" gorm:"column:forward_claims" description:"转发到下游的claim, 逗号间隔, 格式 claim:header"`
+}
+
+func (t *OidcRule) TableName() string {
+	return "gateway_service_oidc"
+}
+
+func (t *OidcRule) Find(c *gin.Context, tx *gorm.DB, search *OidcRule) (*OidcRule, error) {
+	model := &OidcRule{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *OidcRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *OidcRule) IsEnable() bool {
+	return t != nil && t.Enable == 1
+}
+
+// Verify 校验签名、iss、aud、exp 与必须的claim
+func (t *OidcRule) Verify(tokenString string) (jwt.MapClaims, error) {
+	parser := &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}}
+	token, err := parser.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		jwksURL := t.JwksURL
+		if jwksURL == "" {
+			var err error
+			if jwksURL, err = public.OidcJwksHandler.DiscoverJwksURL(t.Issuer); err != nil {
+				return nil, err
+			}
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, err := public.OidcJwksHandler.GetKey(jwksURL, kid)
+		if err != nil {
+			return nil, err
+		}
+		//签名算法必须与公钥类型一致
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			if _, ok := key.(*rsa.PublicKey); ok {
+				return key, nil
+			}
+		case *jwt.SigningMethodECDSA:
+			if _, ok := key.(*ecdsa.PublicKey); ok {
+				return key, nil
+			}
+		}
+		return nil, errors.New(fmt.Sprintf("unexpected jwt alg %s", token.Method.Alg()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("token miss exp")
+	}
+	if iss, _ := claims["iss"].(string); iss != t.Issuer {
+		return nil, errors.New("token issuer mismatch")
+	}
+	if t.Audience != "" && !t.matchAudience(claims["aud"]) {
+		return nil, errors.New("token audience mismatch")
+	}
+	for _, item := range splitTrim(t.RequiredClaims, ",") {
+		name, want := item, ""
+		if pos := strings.Index(item, "="); pos > 0 {
+			name, want = item[:pos], item[pos+1:]
+		}
+		value, ok := claims[name]
+		if !ok || (want != "" && !claimContains(value, want)) {
+			return nil, errors.New("token miss required claim " + name)
+		}
+	}
+	return claims, nil
+}
+
+func (t *OidcRule) matchAudience(aud interface{}) bool {
+	for _, want := range splitTrim(t.Audience, ",") {
+		if claimContains(aud, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppID 通过claim映射的租户id
+func (t *OidcRule) AppID(claims jwt.MapClaims) string {
+	name := t.AppClaim
+	if name == "" {
+		name = "azp"
+	}
+	return claimString(claims[name])
+}
+
+// ForwardHeaders 需要转发到下游的请求头, claim不存在时值为空
+// 调用方需先清除同名请求头, 防止客户端伪造
+func (t *OidcRule) ForwardHeaders(claims jwt.MapClaims) map[string]string {
+	headers := map[string]string{}
+	for _, item := range splitTrim(t.ForwardClaims, ",") {
+		pos := strings.Index(item, ":")
+		if pos <= 0 || pos == len(item)-1 {
+			continue
+		}
+		header := http.CanonicalHeaderKey(strings.TrimSpace(item[pos+1:]))
+		headers[header] = ""
+		if value, ok := claims[strings.TrimSpace(item[:pos])]; ok {
+			headers[header] = claimString(value)
+		}
+	}
+	return headers
+}
+
+// GetApp 查找claim映射的租户
+func (t *OidcRule) GetApp(claims jwt.MapClaims) (*App, bool) {
+	appID := t.AppID(claims)
+	if appID == "" {
+		return nil, false
+	}
+	appInfo, err := AppManagerHandler.GetApp(appID)
+	if err != nil {
+		log.Printf(" [ERROR] get app %s err:%v\n", appID, err)
+		return nil, false
+	}
+	return appInfo, appInfo != nil
+}
+
+func splitTrim(s, sep string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// claimContains claim为数组时判断是否包含
+func claimContains(value interface{}, want string) bool {
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if claimString(item) == want {
+				return true
+			}
+		}
+		return false
+	}
+	return claimString(value) == want
+}
+
+func claimString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			items = append(items, claimString(item))
+		}
+		return strings.Join(items, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOidcRuleVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//本地模拟身份提供方的发现与JWKS接口
+	var server *httptest.Server
+	jwksHits := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwksHits++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": "idp1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	oidcRule := &OidcRule{
+		Enable:         1,
+		Issuer:         server.URL,
+		Audience:       "gateway,other",
+		RequiredClaims: "sub,email_verified=true",
+		AppClaim:       "client_id",
+		ForwardClaims:  "sub:X-User-Id,groups:x-user-groups,email:X-User-Email",
+	}
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		tokenString, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokenString
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            server.URL,
+			"aud":            []string{"gateway"},
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"sub":            "user1",
+			"email_verified": true,
+			"client_id":      "app_oidc",
+			"groups":         []string{"dev", "ops"},
+		}
+	}
+
+	claims, err := oidcRule.Verify(sign("idp1", validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oidcRule.AppID(claims) != "app_oidc" {
+		t.Fatalf("unexpected app id %s", oidcRule.AppID(claims))
+	}
+	headers := oidcRule.ForwardHeaders(claims)
+	if headers["X-User-Id"] != "user1" || headers["X-User-Groups"] != "dev,ops" || headers["X-User-Email"] != "" {
+		t.Fatalf("unexpected forward headers %v", headers)
+	}
+	//公钥已缓存
+	if _, err := oidcRule.Verify(sign("idp1", validClaims())); err != nil || jwksHits != 1 {
+		t.Fatalf("jwks should be cached, hits=%d err=%v", jwksHits, err)
+	}
+
+	invalid := map[string]func(jwt.MapClaims){
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "unknown" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no exp":   func(c jwt.MapClaims) { delete(c, "exp") },
+		"required": func(c jwt.MapClaims) { c["email_verified"] = false },
+		"missing":  func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range invalid {
+		c := validClaims()
+		mutate(c)
+		if _, err := oidcRule.Verify(sign("idp1", c)); err == nil {
+			t.Fatalf("%s: token should be rejected", name)
+		}
+	}
+
+	//未知kid与网关自身的HS256 token均拒绝
+	if _, err := oidcRule.Verify(sign("unknown", validClaims())); err == nil {
+		t.Fatal("unknown kid should be rejected")
+	}
+	hsToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("secret"))
+	if _, err := oidcRule.Verify(hsToken); err == nil {
+		t.Fatal("hs256 token should be rejected")
+	}
+}
@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"github.com/yguilai/go-gateway/url_rewrite"
+	"log"
+	"sync"
+)
+
+var UrlRewriteHandler *UrlRewriteManager
+
+// UrlRewriteManager 服务的url重写规则只编译一次, 规则修改后按新文本重新编译并替换, 缓存数量不超过服务数
+type UrlRewriteManager struct {
+	RuleMap map[string]*urlRewriteItem
+	Locker  sync.RWMutex
+}
+
+type urlRewriteItem struct {
+	text  string
+	rules *url_rewrite.RuleSet
+}
+
+func NewUrlRewriteManager() *UrlRewriteManager {
+	return &UrlRewriteManager{
+		RuleMap: map[string]*urlRewriteItem{},
+		Locker:  sync.RWMutex{},
+	}
+}
+
+func init() {
+	UrlRewriteHandler = NewUrlRewriteManager()
+}
+
+// GetRuleSet 升级前保存的规则可能不符合新格式, 无法解析的规则记录日志后跳过, 其余规则照常执行
+func (m *UrlRewriteManager) GetRuleSet(serviceName, text string) *url_rewrite.RuleSet {
+	m.Locker.RLock()
+	item, ok := m.RuleMap[serviceName]
+	m.Locker.RUnlock()
+	if ok && item.text == text {
+		return item.rules
+	}
+
+	rules, errs := url_rewrite.ParseValid(text)
+	for _, err := range errs {
+		log.Printf(" [WARN] service %s url rewrite rule skipped: %v\n", serviceName, err)
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.RuleMap[serviceName] = &urlRewriteItem{text: text, rules: rules}
+	return rules
+}
@@ -95,6 +95,42 @@ var doc = `{
                 }
             }
         },
+        "/admin_login/logout": {
+            "get": {
+                "description": "管理员退出",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "管理员接口"
+                ],
+                "summary": "管理员退出",
+                "operationId": "/admin_login/logout",
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
         "/apps": {
             "get": {
                 "description": "租户列表",
@@ -331,9 +367,9 @@ var doc = `{
                 }
             }
         },
-        "/apps/{id}/stat": {
+        "/apps/{id}/keys": {
             "get": {
-                "description": "租户统计",
+                "description": "租户api key列表, 不返回key明文",
                 "consumes": [
                     "application/json"
                 ],
@@ -343,8 +379,8 @@ var doc = `{
                 "tags": [
                     "租户管理"
                 ],
-                "summary": "租户统计",
-                "operationId": "/apps/stat",
+                "summary": "租户api key列表",
+                "operationId": "/apps/keys/list",
                 "parameters": [
                     {
                         "type": "string",
@@ -366,7 +402,10 @@ var doc = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/dto.StatisticsOutput"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/dao.AppApiKey"
+                                            }
                                         }
                                     }
                                 }
@@ -374,11 +413,9 @@ var doc = `{
                         }
                     }
                 }
-            }
-        },
-        "/services": {
-            "get": {
-                "description": "服务列表",
+            },
+            "post": {
+                "description": "生成api key, 明文仅在此时返回一次",
                 "consumes": [
                     "application/json"
                 ],
@@ -386,76 +423,25 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "租户管理"
                 ],
-                "summary": "服务列表",
-                "operationId": "/services/list",
+                "summary": "租户api key添加",
+                "operationId": "/apps/keys/add",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "关键词",
-                        "name": "info",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "每页个数",
-                        "name": "page_size",
-                        "in": "query",
+                        "description": "租户ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
-                    {
-                        "type": "integer",
-                        "description": "当前页数",
-                        "name": "page_no",
-                        "in": "query",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "success",
-                        "schema": {
-                            "allOf": [
-                                {
-                                    "$ref": "#/definitions/public.Response"
-                                },
-                                {
-                                    "type": "object",
-                                    "properties": {
-                                        "data": {
-                                            "$ref": "#/definitions/dto.ServiceListOutput"
-                                        }
-                                    }
-                                }
-                            ]
-                        }
-                    }
-                }
-            }
-        },
-        "/services/grpc": {
-            "put": {
-                "description": "grpc服务更新",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "服务管理"
-                ],
-                "summary": "grpc服务更新",
-                "operationId": "/services/update_grpc",
-                "parameters": [
                     {
                         "description": "body",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.ServiceUpdateGrpcInput"
+                            "$ref": "#/definitions/dto.APPApiKeyAddInput"
                         }
                     }
                 ],
@@ -471,7 +457,7 @@ var doc = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "string"
+                                            "$ref": "#/definitions/dto.APPApiKeyAddOutput"
                                         }
                                     }
                                 }
@@ -479,9 +465,11 @@ var doc = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "grpc服务添加",
+            }
+        },
+        "/apps/{id}/keys/{key_id}": {
+            "delete": {
+                "description": "删除后立即失效",
                 "consumes": [
                     "application/json"
                 ],
@@ -489,19 +477,24 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "租户管理"
                 ],
-                "summary": "grpc服务添加",
-                "operationId": "/services/add_grpc",
+                "summary": "租户api key删除",
+                "operationId": "/apps/keys/delete",
                 "parameters": [
                     {
-                        "description": "body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.ServiceAddGrpcInput"
-                        }
+                        "type": "string",
+                        "description": "租户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "key ID",
+                        "name": "key_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -526,9 +519,9 @@ var doc = `{
                 }
             }
         },
-        "/services/http": {
-            "put": {
-                "description": "修改HTTP服务",
+        "/apps/{id}/stat": {
+            "get": {
+                "description": "租户统计",
                 "consumes": [
                     "application/json"
                 ],
@@ -536,19 +529,17 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "租户管理"
                 ],
-                "summary": "修改HTTP服务",
-                "operationId": "/services/update_http",
+                "summary": "租户统计",
+                "operationId": "/apps/stat",
                 "parameters": [
                     {
-                        "description": "body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.ServiceUpdateHTTPInput"
-                        }
+                        "type": "string",
+                        "description": "租户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -563,7 +554,7 @@ var doc = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "string"
+                                            "$ref": "#/definitions/dto.StatisticsOutput"
                                         }
                                     }
                                 }
@@ -571,9 +562,11 @@ var doc = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "添加HTTP服务",
+            }
+        },
+        "/certs": {
+            "get": {
+                "description": "证书列表, 按过期时间排序, 标记即将过期的证书",
                 "consumes": [
                     "application/json"
                 ],
@@ -581,19 +574,30 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "证书管理"
                 ],
-                "summary": "添加HTTP服务",
-                "operationId": "/services/add_http",
+                "summary": "证书列表",
+                "operationId": "/certs",
                 "parameters": [
                     {
-                        "description": "body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.ServiceAddHTTPInput"
-                        }
+                        "type": "string",
+                        "description": "关键词",
+                        "name": "info",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "每页多少条",
+                        "name": "page_size",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "页码",
+                        "name": "page_no",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -608,7 +612,7 @@ var doc = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "string"
+                                            "$ref": "#/definitions/dto.CertListOutput"
                                         }
                                     }
                                 }
@@ -616,11 +620,9 @@ var doc = `{
                         }
                     }
                 }
-            }
-        },
-        "/services/tcp": {
+            },
             "put": {
-                "description": "tcp服务更新",
+                "description": "证书更新, 网关定时载入后生效, 无需重启",
                 "consumes": [
                     "application/json"
                 ],
@@ -628,10 +630,10 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "证书管理"
                 ],
-                "summary": "tcp服务更新",
-                "operationId": "/services/update_tcp",
+                "summary": "证书更新",
+                "operationId": "/certs/update",
                 "parameters": [
                     {
                         "description": "body",
@@ -639,7 +641,7 @@ var doc = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.ServiceUpdateTcpInput"
+                            "$ref": "#/definitions/dto.CertUpdateInput"
                         }
                     }
                 ],
@@ -665,7 +667,7 @@ var doc = `{
                 }
             },
             "post": {
-                "description": "tcp服务添加",
+                "description": "证书添加, 域名与过期时间从证书解析",
                 "consumes": [
                     "application/json"
                 ],
@@ -673,10 +675,10 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "证书管理"
                 ],
-                "summary": "tcp服务添加",
-                "operationId": "/services/add_tcp",
+                "summary": "证书添加",
+                "operationId": "/certs/add",
                 "parameters": [
                     {
                         "description": "body",
@@ -684,7 +686,7 @@ var doc = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.ServiceAddTcpInput"
+                            "$ref": "#/definitions/dto.CertAddInput"
                         }
                     }
                 ],
@@ -710,9 +712,9 @@ var doc = `{
                 }
             }
         },
-        "/services/{id}": {
+        "/certs/{id}": {
             "get": {
-                "description": "服务详情",
+                "description": "证书详情, 不返回私钥",
                 "consumes": [
                     "application/json"
                 ],
@@ -720,14 +722,14 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "证书管理"
                 ],
-                "summary": "服务详情",
-                "operationId": "/services/detail",
+                "summary": "证书详情",
+                "operationId": "/certs/detail",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "服务ID",
+                        "description": "证书ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -745,7 +747,7 @@ var doc = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/dao.ServiceDetail"
+                                            "$ref": "#/definitions/dao.Cert"
                                         }
                                     }
                                 }
@@ -755,7 +757,7 @@ var doc = `{
                 }
             },
             "delete": {
-                "description": "服务删除",
+                "description": "证书删除, 已绑定服务的证书不能删除, 需先解除绑定",
                 "consumes": [
                     "application/json"
                 ],
@@ -763,14 +765,14 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "证书管理"
                 ],
-                "summary": "服务删除",
-                "operationId": "/services/:id",
+                "summary": "证书删除",
+                "operationId": "/certs/delete",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "服务ID",
+                        "description": "证书ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -780,15 +782,27 @@ var doc = `{
                     "200": {
                         "description": "success",
                         "schema": {
-                            "$ref": "#/definitions/public.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             }
         },
-        "/services/{id}/stat": {
+        "/ip_bans": {
             "get": {
-                "description": "服务统计",
+                "description": "自动或手动封禁且未过期的ip, 按过期时间排序",
                 "consumes": [
                     "application/json"
                 ],
@@ -796,17 +810,16 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "服务管理"
+                    "封禁管理"
                 ],
-                "summary": "服务统计",
-                "operationId": "/services/:id/stat",
+                "summary": "动态封禁列表",
+                "operationId": "/ip_bans",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "服务ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "ip关键词",
+                        "name": "info",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -821,7 +834,7 @@ var doc = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/dto.ServiceStatOutput"
+                                            "$ref": "#/definitions/dto.IPBanListOutput"
                                         }
                                     }
                                 }
@@ -829,11 +842,9 @@ var doc = `{
                         }
                     }
                 }
-            }
-        },
-        "/sign/in": {
+            },
             "post": {
-                "description": "管理员登录",
+                "description": "手动封禁ip, 各网关节点在同步间隔内生效",
                 "consumes": [
                     "application/json"
                 ],
@@ -841,10 +852,10 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "管理员接口"
+                    "封禁管理"
                 ],
-                "summary": "管理员登录",
-                "operationId": "/sign/in",
+                "summary": "手动封禁",
+                "operationId": "/ip_bans/add",
                 "parameters": [
                     {
                         "description": "body",
@@ -852,7 +863,1018 @@ var doc = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.AdminInput"
+                            "$ref": "#/definitions/dto.IPBanAddInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/ip_bans/{ip}": {
+            "delete": {
+                "description": "解除封禁, 各网关节点在同步间隔内生效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "封禁管理"
+                ],
+                "summary": "解除封禁",
+                "operationId": "/ip_bans/delete",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ip",
+                        "name": "ip",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/jwks": {
+            "get": {
+                "description": "网关签发token的公钥, 供下游自行验证, 按标准格式直接返回",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAUTH"
+                ],
+                "summary": "获取JWKS",
+                "operationId": "/oauth/jwks",
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "$ref": "#/definitions/dto.JwksOutput"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/revoke": {
+            "post": {
+                "description": "吊销租户签发的access_token或refresh_token, 无效的token同样返回成功",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAUTH"
+                ],
+                "summary": "吊销TOKEN",
+                "operationId": "/oauth/revoke",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RevokeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/tokens": {
+            "post": {
+                "description": "获取TOKEN, grant_type 支持 client_credentials 与 refresh_token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAUTH"
+                ],
+                "summary": "获取TOKEN",
+                "operationId": "/oauth/tokens",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.TokensInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/dto.TokensOutput"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services": {
+            "get": {
+                "description": "服务列表",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "服务列表",
+                "operationId": "/services/list",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "关键词",
+                        "name": "info",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页个数",
+                        "name": "page_size",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "当前页数",
+                        "name": "page_no",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/dto.ServiceListOutput"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/grpc": {
+            "put": {
+                "description": "grpc服务更新",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "grpc服务更新",
+                "operationId": "/services/update_grpc",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceUpdateGrpcInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "grpc服务添加",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "grpc服务添加",
+                "operationId": "/services/add_grpc",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceAddGrpcInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/grpc/descriptor": {
+            "post": {
+                "description": "上传 protoc --include_imports --descriptor_set_out 生成的描述文件, 用于JSON转码",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "grpc描述文件上传",
+                "operationId": "/services/grpc/descriptor",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "服务ID",
+                        "name": "id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "描述文件",
+                        "name": "descriptor",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/http": {
+            "put": {
+                "description": "修改HTTP服务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "修改HTTP服务",
+                "operationId": "/services/update_http",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceUpdateHTTPInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "添加HTTP服务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "添加HTTP服务",
+                "operationId": "/services/add_http",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceAddHTTPInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/http/body_schema": {
+            "post": {
+                "description": "上传JSON Schema文件, 按请求方法与路径校验请求体; 相同方法与路径的schema被覆盖",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "http请求体schema上传",
+                "operationId": "/services/http/body_schema",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "服务ID",
+                        "name": "id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "请求方法, *=全部",
+                        "name": "method",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "路径匹配规则, 支持末尾*通配",
+                        "name": "path",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "JSON Schema文件",
+                        "name": "schema",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/http/body_schema/{id}": {
+            "delete": {
+                "description": "http请求体schema删除",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "http请求体schema删除",
+                "operationId": "/services/http/body_schema/delete",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "schema ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/mirror": {
+            "post": {
+                "description": "按比例将http服务的请求异步复制到影子节点组, 影子响应丢弃, 不影响正常请求",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "流量复制配置",
+                "operationId": "/services/mirror",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceMirrorInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/mtls": {
+            "post": {
+                "description": "开启后要求客户端证书由配置的CA签发, 支持http与grpc服务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "客户端证书鉴权配置",
+                "operationId": "/services/mtls",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceMtlsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/oidc": {
+            "post": {
+                "description": "开启后使用外部身份提供方签发的token鉴权, 支持http与grpc服务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "外部身份提供方鉴权配置",
+                "operationId": "/services/oidc",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceOidcInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/tcp": {
+            "put": {
+                "description": "tcp服务更新",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "tcp服务更新",
+                "operationId": "/services/update_tcp",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceUpdateTcpInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "tcp服务添加",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "tcp服务添加",
+                "operationId": "/services/add_tcp",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ServiceAddTcpInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{id}": {
+            "get": {
+                "description": "服务详情",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "服务详情",
+                "operationId": "/services/detail",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "服务ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/dao.ServiceDetail"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "服务删除",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "服务删除",
+                "operationId": "/services/:id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "服务ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "$ref": "#/definitions/public.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{id}/mirror_stat": {
+            "get": {
+                "description": "今日影子请求的数量、失败数与和正常请求的差异, 统计由代理定时写入, 存在数秒延迟",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "流量复制统计",
+                "operationId": "/services/:id/mirror_stat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "服务ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/dto.ServiceMirrorStatOutput"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{id}/stat": {
+            "get": {
+                "description": "服务统计",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "服务管理"
+                ],
+                "summary": "服务统计",
+                "operationId": "/services/:id/stat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "服务ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/public.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/dto.ServiceStatOutput"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/sign/in": {
+            "post": {
+                "description": "管理员登录",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "管理员接口"
+                ],
+                "summary": "管理员登录",
+                "operationId": "/sign/in",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.AdminInput"
                         }
                     }
                 ],
@@ -910,8 +1932,20 @@ var doc = `{
                 "black_list": {
                     "type": "string"
                 },
-                "clientip_flow_limit": {
-                    "type": "integer"
+                "clientip_flow_limit": {
+                    "type": "integer"
+                },
+                "geo_allow_asn": {
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "integer"
@@ -919,6 +1953,9 @@ var doc = `{
                 "open_auth": {
                     "type": "integer"
                 },
+                "open_sign": {
+                    "type": "integer"
+                },
                 "service_flow_limit": {
                     "type": "integer"
                 },
@@ -942,6 +1979,12 @@ var doc = `{
                 "create_at": {
                     "type": "string"
                 },
+                "grants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.AppGrant"
+                    }
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -960,6 +2003,9 @@ var doc = `{
                 "secret": {
                     "type": "string"
                 },
+                "token_expires": {
+                    "type": "integer"
+                },
                 "update_at": {
                     "type": "string"
                 },
@@ -968,6 +2014,133 @@ var doc = `{
                 }
             }
         },
+        "dao.AppApiKey": {
+            "type": "object",
+            "properties": {
+                "app_id": {
+                    "type": "string"
+                },
+                "create_at": {
+                    "type": "string"
+                },
+                "expire_at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "is_delete": {
+                    "type": "integer"
+                },
+                "key_prefix": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.AppGrant": {
+            "type": "object",
+            "properties": {
+                "app_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "methods": {
+                    "type": "string"
+                },
+                "path_pattern": {
+                    "type": "string"
+                },
+                "service_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Cert": {
+            "type": "object",
+            "properties": {
+                "cert_pem": {
+                    "type": "string"
+                },
+                "create_at": {
+                    "type": "string"
+                },
+                "domains": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "is_acme": {
+                    "type": "integer"
+                },
+                "is_delete": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "not_after": {
+                    "type": "string"
+                },
+                "update_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.GrpcDescriptor": {
+            "type": "object",
+            "properties": {
+                "file_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "service_id": {
+                    "type": "integer"
+                },
+                "update_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.GrpcMethodRule": {
+            "type": "object",
+            "properties": {
+                "flow_limit": {
+                    "type": "integer"
+                },
+                "forbid": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip_list": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "open_auth": {
+                    "type": "integer"
+                },
+                "round_type": {
+                    "type": "integer"
+                },
+                "service_id": {
+                    "type": "integer"
+                },
+                "weight_list": {
+                    "type": "string"
+                }
+            }
+        },
         "dao.GrpcRule": {
             "type": "object",
             "properties": {
@@ -982,82 +2155,243 @@ var doc = `{
                 },
                 "service_id": {
                     "type": "integer"
+                },
+                "web_prefix": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.HttpBodySchema": {
+            "type": "object",
+            "properties": {
+                "file_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "schema": {
+                    "type": "string"
+                },
+                "service_id": {
+                    "type": "integer"
+                },
+                "update_at": {
+                    "type": "string"
                 }
             }
         },
         "dao.HttpRule": {
             "type": "object",
             "properties": {
+                "allow_content_type": {
+                    "type": "string"
+                },
+                "cert_id": {
+                    "type": "integer"
+                },
+                "force_https": {
+                    "type": "integer"
+                },
+                "forwarded_policy": {
+                    "type": "integer"
+                },
+                "geo_country": {
+                    "type": "string"
+                },
                 "header_transfor": {
                     "type": "string"
                 },
+                "hsts_include_subdomains": {
+                    "type": "integer"
+                },
+                "hsts_max_age": {
+                    "type": "integer"
+                },
+                "hsts_preload": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "max_body_size": {
+                    "type": "integer"
+                },
+                "need_https": {
+                    "type": "integer"
+                },
+                "need_strip_uri": {
+                    "type": "integer"
+                },
+                "need_websocket": {
+                    "type": "integer"
+                },
+                "response_rewrite": {
+                    "type": "string"
+                },
+                "rewrite_location": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string"
+                },
+                "rule_type": {
+                    "type": "integer"
+                },
+                "service_id": {
+                    "type": "integer"
+                },
+                "url_rewrite": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.LoadBalance": {
+            "type": "object",
+            "properties": {
+                "check_interval": {
+                    "type": "integer"
+                },
+                "check_method": {
+                    "type": "integer"
+                },
+                "check_timeout": {
+                    "type": "integer"
+                },
+                "forbid_list": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip_list": {
+                    "type": "string"
+                },
+                "round_type": {
+                    "type": "integer"
+                },
+                "service_id": {
+                    "type": "integer"
+                },
+                "upstream_connect_timeout": {
+                    "type": "integer"
+                },
+                "upstream_h2c": {
+                    "type": "integer"
+                },
+                "upstream_header_timeout": {
+                    "type": "integer"
+                },
+                "upstream_idle_timeout": {
+                    "type": "integer"
+                },
+                "upstream_max_idle": {
+                    "type": "integer"
+                },
+                "upstream_tls_ca_cert": {
+                    "type": "string"
+                },
+                "upstream_tls_cert": {
+                    "type": "string"
+                },
+                "upstream_tls_insecure": {
+                    "type": "integer"
+                },
+                "upstream_tls_server_name": {
+                    "type": "string"
+                },
+                "weight_list": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.MirrorRule": {
+            "type": "object",
+            "properties": {
+                "enable": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "need_https": {
+                "ip_list": {
+                    "type": "string"
+                },
+                "max_body_size": {
                     "type": "integer"
                 },
-                "need_strip_uri": {
+                "percent": {
                     "type": "integer"
                 },
-                "need_websocket": {
+                "round_type": {
                     "type": "integer"
                 },
-                "rule": {
-                    "type": "string"
-                },
-                "rule_type": {
+                "service_id": {
                     "type": "integer"
                 },
-                "service_id": {
+                "timeout": {
                     "type": "integer"
                 },
-                "url_rewrite": {
+                "weight_list": {
                     "type": "string"
                 }
             }
         },
-        "dao.LoadBalance": {
+        "dao.MtlsRule": {
             "type": "object",
             "properties": {
-                "check_interval": {
-                    "type": "integer"
+                "app_field": {
+                    "type": "string"
                 },
-                "check_method": {
-                    "type": "integer"
+                "ca_cert": {
+                    "type": "string"
                 },
-                "check_timeout": {
+                "enable": {
                     "type": "integer"
                 },
-                "forbid_list": {
-                    "type": "string"
-                },
                 "id": {
                     "type": "integer"
                 },
-                "ip_list": {
+                "service_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dao.OidcRule": {
+            "type": "object",
+            "properties": {
+                "app_claim": {
                     "type": "string"
                 },
-                "round_type": {
-                    "type": "integer"
+                "audience": {
+                    "type": "string"
                 },
-                "service_id": {
+                "enable": {
                     "type": "integer"
                 },
-                "upstream_connect_timeout": {
-                    "type": "integer"
+                "forward_claims": {
+                    "type": "string"
                 },
-                "upstream_header_timeout": {
+                "id": {
                     "type": "integer"
                 },
-                "upstream_idle_timeout": {
-                    "type": "integer"
+                "issuer": {
+                    "type": "string"
                 },
-                "upstream_max_idle": {
-                    "type": "integer"
+                "jwks_url": {
+                    "type": "string"
                 },
-                "weight_list": {
+                "required_claims": {
                     "type": "string"
+                },
+                "service_id": {
+                    "type": "integer"
                 }
             }
         },
@@ -1067,9 +2401,24 @@ var doc = `{
                 "access_control": {
                     "$ref": "#/definitions/dao.AccessControl"
                 },
+                "grpc_descriptor": {
+                    "$ref": "#/definitions/dao.GrpcDescriptor"
+                },
+                "grpc_method_rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.GrpcMethodRule"
+                    }
+                },
                 "grpc_rule": {
                     "$ref": "#/definitions/dao.GrpcRule"
                 },
+                "http_body_schemas": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.HttpBodySchema"
+                    }
+                },
                 "http_rule": {
                     "$ref": "#/definitions/dao.HttpRule"
                 },
@@ -1079,6 +2428,15 @@ var doc = `{
                 "load_balance": {
                     "$ref": "#/definitions/dao.LoadBalance"
                 },
+                "mirror_rule": {
+                    "$ref": "#/definitions/dao.MirrorRule"
+                },
+                "mtls_rule": {
+                    "$ref": "#/definitions/dao.MtlsRule"
+                },
+                "oidc_rule": {
+                    "$ref": "#/definitions/dao.OidcRule"
+                },
                 "tcp_rule": {
                     "$ref": "#/definitions/dao.TcpRule"
                 }
@@ -1134,6 +2492,12 @@ var doc = `{
                 "app_id": {
                     "type": "string"
                 },
+                "grants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.APPGrantInput"
+                    }
+                },
                 "name": {
                     "type": "string"
                 },
@@ -1146,11 +2510,74 @@ var doc = `{
                 "secret": {
                     "type": "string"
                 },
+                "token_expires": {
+                    "type": "integer"
+                },
                 "white_ips": {
                     "type": "string"
                 }
             }
         },
+        "dto.APPApiKeyAddInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "expire_at": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "name": {
+                    "type": "string",
+                    "example": "partner_a"
+                }
+            }
+        },
+        "dto.APPApiKeyAddOutput": {
+            "type": "object",
+            "properties": {
+                "expire_at": {
+                    "description": "过期时间戳",
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "key id",
+                    "type": "integer"
+                },
+                "key": {
+                    "description": "api key明文, 仅返回一次",
+                    "type": "string"
+                },
+                "key_prefix": {
+                    "description": "key前缀",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.APPGrantInput": {
+            "type": "object",
+            "required": [
+                "service_name"
+            ],
+            "properties": {
+                "methods": {
+                    "description": "允许的http方法",
+                    "type": "string",
+                    "example": "GET,POST"
+                },
+                "path_pattern": {
+                    "description": "允许的路径",
+                    "type": "string",
+                    "example": "/test_http_service/*"
+                },
+                "service_name": {
+                    "description": "服务名称",
+                    "type": "string",
+                    "example": "test_http_service"
+                }
+            }
+        },
         "dto.APPListItemOutput": {
             "type": "object",
             "properties": {
@@ -1184,6 +2611,9 @@ var doc = `{
                 "secret": {
                     "type": "string"
                 },
+                "token_expires": {
+                    "type": "integer"
+                },
                 "update_at": {
                     "type": "string"
                 },
@@ -1217,6 +2647,12 @@ var doc = `{
                 "app_id": {
                     "type": "string"
                 },
+                "grants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.APPGrantInput"
+                    }
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -1232,6 +2668,9 @@ var doc = `{
                 "secret": {
                     "type": "string"
                 },
+                "token_expires": {
+                    "type": "integer"
+                },
                 "white_ips": {
                     "type": "string"
                 }
@@ -1290,6 +2729,177 @@ var doc = `{
                 }
             }
         },
+        "dto.CertAddInput": {
+            "type": "object",
+            "required": [
+                "cert_pem",
+                "key_pem",
+                "name"
+            ],
+            "properties": {
+                "cert_pem": {
+                    "description": "PEM格式证书链",
+                    "type": "string"
+                },
+                "key_pem": {
+                    "description": "PEM格式私钥",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "名称",
+                    "type": "string",
+                    "example": "example.com"
+                }
+            }
+        },
+        "dto.CertListItemOutput": {
+            "type": "object",
+            "properties": {
+                "domains": {
+                    "description": "包含的域名",
+                    "type": "string"
+                },
+                "expire_days": {
+                    "description": "剩余有效天数",
+                    "type": "integer"
+                },
+                "expiring": {
+                    "description": "即将过期或已过期",
+                    "type": "boolean"
+                },
+                "id": {
+                    "description": "证书ID",
+                    "type": "integer"
+                },
+                "is_acme": {
+                    "description": "是否通过ACME自动签发",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "名称",
+                    "type": "string"
+                },
+                "not_after": {
+                    "description": "过期时间",
+                    "type": "string"
+                },
+                "update_at": {
+                    "description": "更新时间",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CertListOutput": {
+            "type": "object",
+            "properties": {
+                "expiring_num": {
+                    "description": "即将过期或已过期的证书数量, 用于控制台提示",
+                    "type": "integer"
+                },
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.CertListItemOutput"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.CertUpdateInput": {
+            "type": "object",
+            "required": [
+                "cert_pem",
+                "id",
+                "key_pem",
+                "name"
+            ],
+            "properties": {
+                "cert_pem": {
+                    "description": "PEM格式证书链",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "证书ID",
+                    "type": "integer",
+                    "example": 1
+                },
+                "key_pem": {
+                    "description": "PEM格式私钥",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "名称",
+                    "type": "string",
+                    "example": "example.com"
+                }
+            }
+        },
+        "dto.IPBanAddInput": {
+            "type": "object",
+            "required": [
+                "ban_time",
+                "ip"
+            ],
+            "properties": {
+                "ban_time": {
+                    "description": "单位s",
+                    "type": "integer",
+                    "example": 600
+                },
+                "ip": {
+                    "description": "ip",
+                    "type": "string",
+                    "example": "1.2.3.4"
+                },
+                "reason": {
+                    "description": "封禁原因",
+                    "type": "string",
+                    "example": "manual"
+                }
+            }
+        },
+        "dto.IPBanListOutput": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/public.IPBan"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.JwksOutput": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "dto.RevokeInput": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "description": "待吊销的token",
+                    "type": "string"
+                }
+            }
+        },
         "dto.ServiceAddGrpcInput": {
             "type": "object",
             "required": [
@@ -1309,12 +2919,30 @@ var doc = `{
                 "forbid_list": {
                     "type": "string"
                 },
+                "geo_allow_asn": {
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "type": "string"
+                },
                 "header_transfor": {
                     "type": "string"
                 },
                 "ip_list": {
                     "type": "string"
                 },
+                "method_rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ServiceGrpcMethodRuleInput"
+                    }
+                },
                 "open_auth": {
                     "type": "integer"
                 },
@@ -1333,6 +2961,9 @@ var doc = `{
                 "service_name": {
                     "type": "string"
                 },
+                "web_prefix": {
+                    "type": "string"
+                },
                 "weight_list": {
                     "type": "string"
                 },
@@ -1354,22 +2985,76 @@ var doc = `{
                 "weight_list"
             ],
             "properties": {
+                "allow_content_type": {
+                    "description": "逗号间隔, 支持 type/* 通配",
+                    "type": "string",
+                    "example": "application/json"
+                },
                 "black_list": {
                     "description": "黑名单ip",
                     "type": "string"
                 },
-                "clientip_flow_limit": {
-                    "description": "\u0008客户端ip限流",
-                    "type": "integer"
+                "cert_id": {
+                    "description": "https证书ID, 仅域名接入, 0=默认证书",
+                    "type": "integer"
+                },
+                "clientip_flow_limit": {
+                    "description": "\b客户端ip限流",
+                    "type": "integer"
+                },
+                "force_https": {
+                    "description": "http请求重定向到https",
+                    "type": "integer"
+                },
+                "forwarded_policy": {
+                    "description": "X-Forwarded-*/Forwarded 0=追加 1=覆盖 2=剔除",
+                    "type": "integer"
+                },
+                "geo_allow_asn": {
+                    "description": "ASN, 以逗号间隔",
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "description": "两位国家码, 以逗号间隔",
+                    "type": "string"
+                },
+                "geo_country": {
+                    "description": "仅匹配来自这些国家的请求, 为空不限制",
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "description": "ASN, 以逗号间隔",
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "description": "两位国家码, 以逗号间隔",
+                    "type": "string"
                 },
                 "header_transfor": {
                     "description": "header转换",
                     "type": "string"
                 },
+                "hsts_include_subdomains": {
+                    "description": "hsts包含子域名",
+                    "type": "integer"
+                },
+                "hsts_max_age": {
+                    "description": "单位s, 0=不发送hsts",
+                    "type": "integer"
+                },
+                "hsts_preload": {
+                    "description": "hsts preload",
+                    "type": "integer"
+                },
                 "ip_list": {
                     "description": "ip列表",
                     "type": "string"
                 },
+                "max_body_size": {
+                    "description": "0=不限制",
+                    "type": "integer",
+                    "example": 0
+                },
                 "need_https": {
                     "description": "支持https",
                     "type": "integer"
@@ -1386,6 +3071,18 @@ var doc = `{
                     "description": "关键词",
                     "type": "integer"
                 },
+                "open_sign": {
+                    "description": "是否开启请求签名",
+                    "type": "integer"
+                },
+                "response_rewrite": {
+                    "description": "每行一条: replace|regex|json_del|json_rename",
+                    "type": "string"
+                },
+                "rewrite_location": {
+                    "description": "下游重定向与cookie改写为网关地址 1=开启",
+                    "type": "integer"
+                },
                 "round_type": {
                     "description": "轮询方式",
                     "type": "integer"
@@ -1414,6 +3111,10 @@ var doc = `{
                     "description": "建立连接超时, 单位s",
                     "type": "integer"
                 },
+                "upstream_h2c": {
+                    "description": "使用明文http2连接下游",
+                    "type": "integer"
+                },
                 "upstream_header_timeout": {
                     "description": "获取header超时, 单位s",
                     "type": "integer"
@@ -1426,12 +3127,32 @@ var doc = `{
                     "description": "最大空闲链接数",
                     "type": "integer"
                 },
+                "upstream_tls_ca_cert": {
+                    "description": "校验下游证书的CA, 为空时使用系统CA",
+                    "type": "string"
+                },
+                "upstream_tls_cert": {
+                    "description": "下游要求客户端证书时使用",
+                    "type": "string"
+                },
+                "upstream_tls_insecure": {
+                    "description": "仅用于开发环境",
+                    "type": "integer"
+                },
+                "upstream_tls_key": {
+                    "description": "修改时为空则保留原私钥",
+                    "type": "string"
+                },
+                "upstream_tls_server_name": {
+                    "description": "覆盖发送给下游的SNI",
+                    "type": "string"
+                },
                 "url_rewrite": {
                     "description": "url重写功能",
                     "type": "string"
                 },
                 "weight_list": {
-                    "description": "\u0008权重列表",
+                    "description": "\b权重列表",
                     "type": "string"
                 },
                 "white_list": {
@@ -1459,6 +3180,18 @@ var doc = `{
                 "forbid_list": {
                     "type": "string"
                 },
+                "geo_allow_asn": {
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "type": "string"
+                },
                 "header_transfor": {
                     "type": "string"
                 },
@@ -1494,6 +3227,47 @@ var doc = `{
                 }
             }
         },
+        "dto.ServiceGrpcMethodRuleInput": {
+            "type": "object",
+            "required": [
+                "method"
+            ],
+            "properties": {
+                "flow_limit": {
+                    "description": "方法限流",
+                    "type": "integer",
+                    "example": 0
+                },
+                "forbid": {
+                    "description": "禁止访问",
+                    "type": "integer",
+                    "example": 0
+                },
+                "ip_list": {
+                    "description": "独立下游ip列表",
+                    "type": "string"
+                },
+                "method": {
+                    "description": "方法匹配规则",
+                    "type": "string",
+                    "example": "/pkg.Service/*"
+                },
+                "open_auth": {
+                    "description": "需要鉴权",
+                    "type": "integer",
+                    "example": 0
+                },
+                "round_type": {
+                    "description": "轮询方式",
+                    "type": "integer",
+                    "example": 0
+                },
+                "weight_list": {
+                    "description": "权重列表",
+                    "type": "string"
+                }
+            }
+        },
         "dto.ServiceListItemOutput": {
             "type": "object",
             "properties": {
@@ -1547,6 +3321,160 @@ var doc = `{
                 }
             }
         },
+        "dto.ServiceMirrorInput": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "enable": {
+                    "description": "是否开启",
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "description": "服务ID",
+                    "type": "integer",
+                    "example": 56
+                },
+                "ip_list": {
+                    "description": "影子节点列表",
+                    "type": "string",
+                    "example": "127.0.0.1:80"
+                },
+                "max_body_size": {
+                    "description": "复制的请求体上限",
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "percent": {
+                    "description": "复制比例",
+                    "type": "integer",
+                    "example": 10
+                },
+                "round_type": {
+                    "description": "轮询方式",
+                    "type": "integer"
+                },
+                "timeout": {
+                    "description": "影子请求超时",
+                    "type": "integer",
+                    "example": 5
+                },
+                "weight_list": {
+                    "description": "影子节点权重列表",
+                    "type": "string",
+                    "example": "50"
+                }
+            }
+        },
+        "dto.ServiceMirrorStatOutput": {
+            "type": "object",
+            "properties": {
+                "avg_latency_diff": {
+                    "description": "平均耗时差",
+                    "type": "integer"
+                },
+                "compared": {
+                    "description": "完成对比数",
+                    "type": "integer"
+                },
+                "dropped": {
+                    "description": "并发已满丢弃数",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "今日影子请求失败数",
+                    "type": "integer"
+                },
+                "skipped": {
+                    "description": "请求体过大跳过数",
+                    "type": "integer"
+                },
+                "status_diff": {
+                    "description": "状态码不一致数",
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "今日影子请求数",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.ServiceMtlsInput": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "app_field": {
+                    "description": "映射租户id的证书字段",
+                    "type": "string",
+                    "example": "cn"
+                },
+                "ca_cert": {
+                    "description": "PEM格式CA证书",
+                    "type": "string"
+                },
+                "enable": {
+                    "description": "是否开启",
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "description": "服务ID",
+                    "type": "integer",
+                    "example": 56
+                }
+            }
+        },
+        "dto.ServiceOidcInput": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "app_claim": {
+                    "description": "映射租户id的claim",
+                    "type": "string",
+                    "example": "azp"
+                },
+                "audience": {
+                    "description": "接受的aud",
+                    "type": "string",
+                    "example": "gateway"
+                },
+                "enable": {
+                    "description": "是否开启",
+                    "type": "integer",
+                    "example": 1
+                },
+                "forward_claims": {
+                    "description": "转发到下游的claim",
+                    "type": "string",
+                    "example": "sub:X-User-Id,email:X-User-Email"
+                },
+                "id": {
+                    "description": "服务ID",
+                    "type": "integer",
+                    "example": 56
+                },
+                "issuer": {
+                    "description": "签发方",
+                    "type": "string",
+                    "example": "https://idp.example.com"
+                },
+                "jwks_url": {
+                    "description": "公钥地址",
+                    "type": "string"
+                },
+                "required_claims": {
+                    "description": "必须的claim",
+                    "type": "string",
+                    "example": "sub,email_verified=true"
+                }
+            }
+        },
         "dto.ServiceStatOutput": {
             "type": "object",
             "properties": {
@@ -1586,6 +3514,18 @@ var doc = `{
                 "forbid_list": {
                     "type": "string"
                 },
+                "geo_allow_asn": {
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "type": "string"
+                },
                 "header_transfor": {
                     "type": "string"
                 },
@@ -1595,6 +3535,12 @@ var doc = `{
                 "ip_list": {
                     "type": "string"
                 },
+                "method_rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ServiceGrpcMethodRuleInput"
+                    }
+                },
                 "open_auth": {
                     "type": "integer"
                 },
@@ -1613,6 +3559,9 @@ var doc = `{
                 "service_name": {
                     "type": "string"
                 },
+                "web_prefix": {
+                    "type": "string"
+                },
                 "weight_list": {
                     "type": "string"
                 },
@@ -1635,18 +3584,67 @@ var doc = `{
                 "weight_list"
             ],
             "properties": {
+                "allow_content_type": {
+                    "description": "逗号间隔, 支持 type/* 通配",
+                    "type": "string",
+                    "example": "application/json"
+                },
                 "black_list": {
                     "description": "黑名单ip",
                     "type": "string"
                 },
+                "cert_id": {
+                    "description": "https证书ID, 仅域名接入, 0=默认证书",
+                    "type": "integer"
+                },
                 "clientip_flow_limit": {
-                    "description": "\u0008客户端ip限流",
+                    "description": "\b客户端ip限流",
+                    "type": "integer"
+                },
+                "force_https": {
+                    "description": "http请求重定向到https",
+                    "type": "integer"
+                },
+                "forwarded_policy": {
+                    "description": "X-Forwarded-*/Forwarded 0=追加 1=覆盖 2=剔除",
                     "type": "integer"
                 },
+                "geo_allow_asn": {
+                    "description": "ASN, 以逗号间隔",
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "description": "两位国家码, 以逗号间隔",
+                    "type": "string"
+                },
+                "geo_country": {
+                    "description": "仅匹配来自这些国家的请求, 为空不限制",
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "description": "ASN, 以逗号间隔",
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "description": "两位国家码, 以逗号间隔",
+                    "type": "string"
+                },
                 "header_transfor": {
                     "description": "header转换",
                     "type": "string"
                 },
+                "hsts_include_subdomains": {
+                    "description": "hsts包含子域名",
+                    "type": "integer"
+                },
+                "hsts_max_age": {
+                    "description": "单位s, 0=不发送hsts",
+                    "type": "integer"
+                },
+                "hsts_preload": {
+                    "description": "hsts preload",
+                    "type": "integer"
+                },
                 "id": {
                     "description": "服务ID",
                     "type": "integer",
@@ -1657,6 +3655,11 @@ var doc = `{
                     "type": "string",
                     "example": "127.0.0.1:80"
                 },
+                "max_body_size": {
+                    "description": "0=不限制",
+                    "type": "integer",
+                    "example": 0
+                },
                 "need_https": {
                     "description": "支持https",
                     "type": "integer"
@@ -1673,6 +3676,18 @@ var doc = `{
                     "description": "关键词",
                     "type": "integer"
                 },
+                "open_sign": {
+                    "description": "是否开启请求签名",
+                    "type": "integer"
+                },
+                "response_rewrite": {
+                    "description": "每行一条: replace|regex|json_del|json_rename",
+                    "type": "string"
+                },
+                "rewrite_location": {
+                    "description": "下游重定向与cookie改写为网关地址 1=开启",
+                    "type": "integer"
+                },
                 "round_type": {
                     "description": "轮询方式",
                     "type": "integer"
@@ -1704,6 +3719,10 @@ var doc = `{
                     "description": "建立连接超时, 单位s",
                     "type": "integer"
                 },
+                "upstream_h2c": {
+                    "description": "使用明文http2连接下游",
+                    "type": "integer"
+                },
                 "upstream_header_timeout": {
                     "description": "获取header超时, 单位s",
                     "type": "integer"
@@ -1716,12 +3735,32 @@ var doc = `{
                     "description": "最大空闲链接数",
                     "type": "integer"
                 },
+                "upstream_tls_ca_cert": {
+                    "description": "校验下游证书的CA, 为空时使用系统CA",
+                    "type": "string"
+                },
+                "upstream_tls_cert": {
+                    "description": "下游要求客户端证书时使用",
+                    "type": "string"
+                },
+                "upstream_tls_insecure": {
+                    "description": "仅用于开发环境",
+                    "type": "integer"
+                },
+                "upstream_tls_key": {
+                    "description": "修改时为空则保留原私钥",
+                    "type": "string"
+                },
+                "upstream_tls_server_name": {
+                    "description": "覆盖发送给下游的SNI",
+                    "type": "string"
+                },
                 "url_rewrite": {
                     "description": "url重写功能",
                     "type": "string"
                 },
                 "weight_list": {
-                    "description": "\u0008权重列表",
+                    "description": "\b权重列表",
                     "type": "string",
                     "example": "50"
                 },
@@ -1751,6 +3790,18 @@ var doc = `{
                 "forbid_list": {
                     "type": "string"
                 },
+                "geo_allow_asn": {
+                    "type": "string"
+                },
+                "geo_allow_country": {
+                    "type": "string"
+                },
+                "geo_deny_asn": {
+                    "type": "string"
+                },
+                "geo_deny_country": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -1807,6 +3858,53 @@ var doc = `{
                 }
             }
         },
+        "dto.TokensInput": {
+            "type": "object",
+            "required": [
+                "grant_type"
+            ],
+            "properties": {
+                "grant_type": {
+                    "description": "授权类型",
+                    "type": "string",
+                    "example": "client_credentials"
+                },
+                "refresh_token": {
+                    "description": "refresh_token",
+                    "type": "string"
+                },
+                "scope": {
+                    "description": "权限范围",
+                    "type": "string",
+                    "example": "read_write"
+                }
+            }
+        },
+        "dto.TokensOutput": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "description": "access_token",
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "expires_in",
+                    "type": "integer"
+                },
+                "refresh_token": {
+                    "description": "refresh_token",
+                    "type": "string"
+                },
+                "scope": {
+                    "description": "scope",
+                    "type": "string"
+                },
+                "token_type": {
+                    "description": "token_type",
+                    "type": "string"
+                }
+            }
+        },
         "dto.UpdatePwdInput": {
             "type": "object",
             "properties": {
@@ -1815,6 +3913,20 @@ var doc = `{
                 }
             }
         },
+        "public.IPBan": {
+            "type": "object",
+            "properties": {
+                "expire_at": {
+                    "type": "integer"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
         "public.Response": {
             "type": "object",
             "properties": {
@@ -29,6 +29,7 @@ type APPListItemOutput struct {
 	WhiteIPS  string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持前缀匹配		"`
 	Qpd       int64     `json:"qpd" gorm:"column:qpd" description:"日请求量限制"`
 	Qps       int64     `json:"qps" gorm:"column:qps" description:"每秒请求量限制"`
+	TokenExpires int    `json:"token_expires" description:"token有效期"`
 	RealQpd   int64       `json:"real_qpd" description:"日请求量限制"`
 	RealQps   int64       `json:"real_qps" description:"每秒请求量限制"`
 	UpdatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间	"`
@@ -53,9 +54,11 @@ type APPAddHttpInput struct {
 	AppID    string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
 	Name     string `json:"name" form:"name" comment:"租户名称" validate:"required"`
 	Secret   string `json:"secret" form:"secret" comment:"密钥" validate:""`
-	WhiteIPS string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持前缀匹配"`
+	WhiteIPS string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持ip、CIDR、ip范围与前缀匹配" validate:"valid_ip_matcher"`
 	Qpd      int64  `json:"qpd" form:"qpd" comment:"日请求量限制" validate:""`
 	Qps      int64  `json:"qps" form:"qps" comment:"每秒请求量限制" validate:""`
+	TokenExpires int `json:"token_expires" form:"token_expires" comment:"token有效期, 单位s, 0=使用默认" validate:"min=0"`
+	Grants   []APPGrantInput `json:"grants" form:"grants" comment:"可访问的服务, 为空时可访问全部服务" validate:"dive"`
 }
 
 func (params *APPAddHttpInput) GetValidParams(c *gin.Context) error {
@@ -67,11 +70,56 @@ type APPUpdateHttpInput struct {
 	AppID    string `json:"app_id" form:"app_id" gorm:"column:app_id" comment:"租户id" validate:""`
 	Name     string `json:"name" form:"name" gorm:"column:name" comment:"租户名称" validate:"required"`
 	Secret   string `json:"secret" form:"secret" gorm:"column:secret" comment:"密钥" validate:"required"`
-	WhiteIPS string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持前缀匹配		"`
+	WhiteIPS string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持ip、CIDR、ip范围与前缀匹配" validate:"valid_ip_matcher"`
 	Qpd      int64  `json:"qpd" form:"qpd" gorm:"column:qpd" comment:"日请求量限制"`
 	Qps      int64  `json:"qps" form:"qps" gorm:"column:qps" comment:"每秒请求量限制"`
+	TokenExpires int `json:"token_expires" form:"token_expires" comment:"token有效期, 单位s, 0=使用默认" validate:"min=0"`
+	Grants   []APPGrantInput `json:"grants" form:"grants" comment:"可访问的服务, 为空时可访问全部服务" validate:"dive"`
 }
 
 func (params *APPUpdateHttpInput) GetValidParams(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, params)
 }
+
+type APPGrantInput struct {
+	ServiceName string `json:"service_name" form:"service_name" comment:"服务名称, *=全部服务" example:"test_http_service" validate:"required,valid_grant_service"` //服务名称
+	Methods     string `json:"methods" form:"methods" comment:"允许的http方法, 逗号间隔" example:"GET,POST" validate:""`                                    //允许的http方法
+	PathPattern string `json:"path_pattern" form:"path_pattern" comment:"允许的路径, 支持末尾*前缀匹配" example:"/test_http_service/*" validate:"omitempty,valid_rule"`   //允许的路径
+}
+
+type APPApiKeyListInput struct {
+	ID int64 `json:"id" form:"id" uri:"id" comment:"租户ID" validate:"required"`
+}
+
+func (params *APPApiKeyListInput) GetValidParams(c *gin.Context) error {
+	return public.UriGetValidParams(c, params)
+}
+
+type APPApiKeyAddInput struct {
+	ID       int64  `json:"-" form:"-" uri:"id" comment:"租户ID" validate:"required"`
+	Name     string `json:"name" form:"name" comment:"名称" example:"partner_a" validate:"required"`
+	ExpireAt int64  `json:"expire_at" form:"expire_at" comment:"过期时间戳, 0=永不过期" example:"0" validate:"min=0"`
+}
+
+func (params *APPApiKeyAddInput) GetValidParams(c *gin.Context) error {
+	if err := c.ShouldBindUri(params); err != nil {
+		return err
+	}
+	return public.DefaultGetValidParams(c, params)
+}
+
+type APPApiKeyAddOutput struct {
+	ID        int64  `json:"id" form:"id"`                 //key id
+	Key       string `json:"key" form:"key"`               //api key明文, 仅返回一次
+	KeyPrefix string `json:"key_prefix" form:"key_prefix"` //key前缀
+	ExpireAt  int64  `json:"expire_at" form:"expire_at"`   //过期时间戳
+}
+
+type APPApiKeyDeleteInput struct {
+	ID    int64 `json:"id" form:"id" uri:"id" comment:"租户ID" validate:"required"`
+	KeyID int64 `json:"key_id" form:"key_id" uri:"key_id" comment:"key ID" validate:"required"`
+}
+
+func (params *APPApiKeyDeleteInput) GetValidParams(c *gin.Context) error {
+	return public.UriGetValidParams(c, params)
+}
@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"time"
+)
+
+type CertListInput struct {
+	Info     string `json:"info" form:"info" comment:"查找信息" validate:""`
+	PageSize int    `json:"page_size" form:"page_size" comment:"页数" validate:"required,min=1,max=999"`
+	PageNo   int    `json:"page_no" form:"page_no" comment:"页码" validate:"required,min=1,max=999"`
+}
+
+func (params *CertListInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertListOutput struct {
+	List  []CertListItemOutput `json:"list" form:"list" comment:"证书列表"`
+	Total int64                `json:"total" form:"total" comment:"证书总数"`
+	//即将过期或已过期的证书数量, 用于控制台提示
+	ExpiringNum int64 `json:"expiring_num" form:"expiring_num" comment:"即将过期证书数量"`
+}
+
+type CertListItemOutput struct {
+	ID         int64     `json:"id" form:"id"`                   //证书ID
+	Name       string    `json:"name" form:"name"`               //名称
+	Domains    string    `json:"domains" form:"domains"`         //包含的域名
+	NotAfter   time.Time `json:"not_after" form:"not_after"`     //过期时间
+	ExpireDays int       `json:"expire_days" form:"expire_days"` //剩余有效天数
+	Expiring   bool      `json:"expiring" form:"expiring"`       //即将过期或已过期
+	IsAcme     int8      `json:"is_acme" form:"is_acme"`         //是否通过ACME自动签发
+	UpdatedAt  time.Time `json:"update_at" form:"update_at"`     //更新时间
+}
+
+type CertDetailInput struct {
+	ID int64 `json:"id" form:"id" uri:"id" comment:"证书ID" validate:"required"`
+}
+
+func (params *CertDetailInput) GetValidParams(c *gin.Context) error {
+	return public.UriGetValidParams(c, params)
+}
+
+type CertAddInput struct {
+	Name    string `json:"name" form:"name" comment:"名称" example:"example.com" validate:"required"`    //名称
+	CertPem string `json:"cert_pem" form:"cert_pem" comment:"PEM格式证书链" example:"" validate:"required"` //PEM格式证书链
+	KeyPem  string `json:"key_pem" form:"key_pem" comment:"PEM格式私钥" example:"" validate:"required"`    //PEM格式私钥
+}
+
+func (params *CertAddInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertUpdateInput struct {
+	ID      int64  `json:"id" form:"id" comment:"证书ID" example:"1" validate:"required"`                //证书ID
+	Name    string `json:"name" form:"name" comment:"名称" example:"example.com" validate:"required"`    //名称
+	CertPem string `json:"cert_pem" form:"cert_pem" comment:"PEM格式证书链" example:"" validate:"required"` //PEM格式证书链
+	KeyPem  string `json:"key_pem" form:"key_pem" comment:"PEM格式私钥" example:"" validate:"required"`    //PEM格式私钥
+}
+
+func (params *CertUpdateInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
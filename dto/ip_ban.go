@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+)
+
+type IPBanListInput struct {
+	Info string `json:"info" form:"info" comment:"查找信息" validate:""`
+}
+
+func (params *IPBanListInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type IPBanListOutput struct {
+	List  []*public.IPBan `json:"list" form:"list" comment:"封禁列表"`
+	Total int64           `json:"total" form:"total" comment:"封禁总数"`
+}
+
+type IPBanAddInput struct {
+	IP      string `json:"ip" form:"ip" comment:"ip" example:"1.2.3.4" validate:"required,ip"`              //ip
+	Reason  string `json:"reason" form:"reason" comment:"封禁原因" example:"manual" validate:""`                //封禁原因
+	BanTime int64  `json:"ban_time" form:"ban_time" comment:"封禁时长" example:"600" validate:"required,min=1"` //单位s
+}
+
+func (params *IPBanAddInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type IPBanDeleteInput struct {
+	IP string `json:"ip" form:"ip" uri:"ip" comment:"ip" validate:"required,ip"`
+}
+
+func (params *IPBanDeleteInput) GetValidParams(c *gin.Context) error {
+	return public.UriGetValidParams(c, params)
+}
@@ -6,8 +6,9 @@ import (
 )
 
 type TokensInput struct {
-	GrantType string `json:"grant_type" form:"grant_type" comment:"授权类型" example:"client_credentials" validate:"required"` //授权类型
-	Scope     string `json:"scope" form:"scope" comment:"权限范围" example:"read_write" validate:"required"`                   //权限范围
+	GrantType    string `json:"grant_type" form:"grant_type" comment:"授权类型" example:"client_credentials" validate:"required"` //授权类型
+	Scope        string `json:"scope" form:"scope" comment:"权限范围, 空格间隔的 service:服务名, 不指定服务时签发全部授权" example:"read_write"`      //权限范围
+	RefreshToken string `json:"refresh_token" form:"refresh_token" comment:"grant_type为refresh_token时必填" example:""`          //refresh_token
 }
 
 func (param *TokensInput) BindValidParam(c *gin.Context) error {
@@ -15,8 +16,22 @@ func (param *TokensInput) BindValidParam(c *gin.Context) error {
 }
 
 type TokensOutput struct {
-	AccessToken string `json:"access_token" form:"access_token"` //access_token
-	ExpiresIn   int    `json:"expires_in" form:"expires_in"`     //expires_in
-	TokenType   string `json:"token_type" form:"token_type"`     //token_type
-	Scope       string `json:"scope" form:"scope"`               //scope
+	AccessToken  string `json:"access_token" form:"access_token"`   //access_token
+	ExpiresIn    int    `json:"expires_in" form:"expires_in"`       //expires_in
+	TokenType    string `json:"token_type" form:"token_type"`       //token_type
+	RefreshToken string `json:"refresh_token" form:"refresh_token"` //refresh_token
+	Scope        string `json:"scope" form:"scope"`                 //scope
+}
+
+type RevokeInput struct {
+	Token string `json:"token" form:"token" comment:"待吊销的token" example:"" validate:"required"` //待吊销的token
+}
+
+func (param *RevokeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// JwksOutput RFC 7517 JWK Set
+type JwksOutput struct {
+	Keys []map[string]string `json:"keys"`
 }
@@ -64,13 +64,33 @@ type ServiceUpdateHTTPInput struct {
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket" example:"" validate:"max=1,min=0"`          //是否支持websocket
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`                //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"`   //header转换
+	CertID         int64  `json:"cert_id" form:"cert_id" comment:"https证书ID" example:"" validate:"min=0"`                                 //https证书ID, 仅域名接入, 0=默认证书
+
+	ForceHttps            int `json:"force_https" form:"force_https" comment:"强制https" example:"" validate:"max=1,min=0"`                           //http请求重定向到https
+	HstsMaxAge            int `json:"hsts_max_age" form:"hsts_max_age" comment:"hsts有效期" example:"" validate:"min=0"`                               //单位s, 0=不发送hsts
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" form:"hsts_include_subdomains" comment:"hsts包含子域名" example:"" validate:"max=1,min=0"` //hsts包含子域名
+	HstsPreload           int `json:"hsts_preload" form:"hsts_preload" comment:"hsts preload" example:"" validate:"max=1,min=0"`                    //hsts preload
+	ForwardedPolicy       int `json:"forwarded_policy" form:"forwarded_policy" comment:"转发头策略" example:"" validate:"max=2,min=0"`                   //X-Forwarded-*/Forwarded 0=追加 1=覆盖 2=剔除
 
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                     //关键词
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                               //黑名单ip
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                               //白名单ip
+	OpenSign          int    `json:"open_sign" form:"open_sign" comment:"是否开启请求签名" example:"" validate:"max=1,min=0"`                   //是否开启请求签名
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:"valid_ip_matcher"`               //黑名单ip
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:"valid_ip_matcher"`               //白名单ip
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`          //服务端限流
 
+	GeoCountry      string `json:"geo_country" form:"geo_country" comment:"路由国家条件" example:"" validate:"valid_geo_country"`              //仅匹配来自这些国家的请求, 为空不限制
+	GeoAllowCountry string `json:"geo_allow_country" form:"geo_allow_country" comment:"允许访问的国家" example:"" validate:"valid_geo_country"` //两位国家码, 以逗号间隔
+	GeoDenyCountry  string `json:"geo_deny_country" form:"geo_deny_country" comment:"拒绝访问的国家" example:"" validate:"valid_geo_country"`   //两位国家码, 以逗号间隔
+	GeoAllowAsn     string `json:"geo_allow_asn" form:"geo_allow_asn" comment:"允许访问的ASN" example:"" validate:"valid_geo_asn"`            //ASN, 以逗号间隔
+	GeoDenyAsn      string `json:"geo_deny_asn" form:"geo_deny_asn" comment:"拒绝访问的ASN" example:"" validate:"valid_geo_asn"`              //ASN, 以逗号间隔
+
+	MaxBodySize      int64  `json:"max_body_size" form:"max_body_size" comment:"请求体最大字节数" example:"0" validate:"min=0"`                                        //0=不限制
+	AllowContentType string `json:"allow_content_type" form:"allow_content_type" comment:"允许的请求体类型" example:"application/json" validate:"valid_content_types"` //逗号间隔, 支持 type/* 通配
+
+	ResponseRewrite string `json:"response_rewrite" form:"response_rewrite" comment:"响应体改写" example:"" validate:"valid_response_rewrite"`  //每行一条: replace|regex|json_del|json_rename
+	RewriteLocation int    `json:"rewrite_location" form:"rewrite_location" comment:"改写Location与Cookie" example:"" validate:"max=1,min=0"` //下游重定向与cookie改写为网关地址 1=开启
+
 	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                                //轮询方式
 	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"127.0.0.1:80" validate:"required,valid_ipportlist"`            //ip列表
 	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"50" validate:"required,valid_weightlist"`             //权重列表
@@ -78,6 +98,13 @@ type ServiceUpdateHTTPInput struct {
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+
+	UpstreamTLSCaCert     string `json:"upstream_tls_ca_cert" form:"upstream_tls_ca_cert" comment:"下游CA证书" example:"" validate:""`               //校验下游证书的CA, 为空时使用系统CA
+	UpstreamTLSCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"下游客户端证书" example:"" validate:""`                    //下游要求客户端证书时使用
+	UpstreamTLSKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"下游客户端私钥" example:"" validate:""`                      //修改时为空则保留原私钥
+	UpstreamTLSServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"下游SNI" example:"" validate:""`        //覆盖发送给下游的SNI
+	UpstreamTLSInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书" example:"" validate:"max=1,min=0"` //仅用于开发环境
+	UpstreamH2c           int    `json:"upstream_h2c" form:"upstream_h2c" comment:"明文http2" example:"" validate:"max=1,min=0"`                   //使用明文http2连接下游
 }
 
 func (param *ServiceUpdateHTTPInput) BindValidParam(c *gin.Context) error {
@@ -95,13 +122,33 @@ type ServiceAddHTTPInput struct {
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket" example:"" validate:"max=1,min=0"`        //是否支持websocket
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`              //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"` //header转换
+	CertID         int64  `json:"cert_id" form:"cert_id" comment:"https证书ID" example:"" validate:"min=0"`                               //https证书ID, 仅域名接入, 0=默认证书
+
+	ForceHttps            int `json:"force_https" form:"force_https" comment:"强制https" example:"" validate:"max=1,min=0"`                           //http请求重定向到https
+	HstsMaxAge            int `json:"hsts_max_age" form:"hsts_max_age" comment:"hsts有效期" example:"" validate:"min=0"`                               //单位s, 0=不发送hsts
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" form:"hsts_include_subdomains" comment:"hsts包含子域名" example:"" validate:"max=1,min=0"` //hsts包含子域名
+	HstsPreload           int `json:"hsts_preload" form:"hsts_preload" comment:"hsts preload" example:"" validate:"max=1,min=0"`                    //hsts preload
+	ForwardedPolicy       int `json:"forwarded_policy" form:"forwarded_policy" comment:"转发头策略" example:"" validate:"max=2,min=0"`                   //X-Forwarded-*/Forwarded 0=追加 1=覆盖 2=剔除
 
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                     //关键词
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                               //黑名单ip
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                               //白名单ip
+	OpenSign          int    `json:"open_sign" form:"open_sign" comment:"是否开启请求签名" example:"" validate:"max=1,min=0"`                   //是否开启请求签名
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:"valid_ip_matcher"`               //黑名单ip
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:"valid_ip_matcher"`               //白名单ip
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`          //服务端限流
 
+	GeoCountry      string `json:"geo_country" form:"geo_country" comment:"路由国家条件" example:"" validate:"valid_geo_country"`              //仅匹配来自这些国家的请求, 为空不限制
+	GeoAllowCountry string `json:"geo_allow_country" form:"geo_allow_country" comment:"允许访问的国家" example:"" validate:"valid_geo_country"` //两位国家码, 以逗号间隔
+	GeoDenyCountry  string `json:"geo_deny_country" form:"geo_deny_country" comment:"拒绝访问的国家" example:"" validate:"valid_geo_country"`   //两位国家码, 以逗号间隔
+	GeoAllowAsn     string `json:"geo_allow_asn" form:"geo_allow_asn" comment:"允许访问的ASN" example:"" validate:"valid_geo_asn"`            //ASN, 以逗号间隔
+	GeoDenyAsn      string `json:"geo_deny_asn" form:"geo_deny_asn" comment:"拒绝访问的ASN" example:"" validate:"valid_geo_asn"`              //ASN, 以逗号间隔
+
+	MaxBodySize      int64  `json:"max_body_size" form:"max_body_size" comment:"请求体最大字节数" example:"0" validate:"min=0"`                                        //0=不限制
+	AllowContentType string `json:"allow_content_type" form:"allow_content_type" comment:"允许的请求体类型" example:"application/json" validate:"valid_content_types"` //逗号间隔, 支持 type/* 通配
+
+	ResponseRewrite string `json:"response_rewrite" form:"response_rewrite" comment:"响应体改写" example:"" validate:"valid_response_rewrite"`  //每行一条: replace|regex|json_del|json_rename
+	RewriteLocation int    `json:"rewrite_location" form:"rewrite_location" comment:"改写Location与Cookie" example:"" validate:"max=1,min=0"` //下游重定向与cookie改写为网关地址 1=开启
+
 	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                                //轮询方式
 	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"" validate:"required,valid_ipportlist"`                        //ip列表
 	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"" validate:"required,valid_weightlist"`               //权重列表
@@ -109,6 +156,13 @@ type ServiceAddHTTPInput struct {
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+
+	UpstreamTLSCaCert     string `json:"upstream_tls_ca_cert" form:"upstream_tls_ca_cert" comment:"下游CA证书" example:"" validate:""`               //校验下游证书的CA, 为空时使用系统CA
+	UpstreamTLSCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"下游客户端证书" example:"" validate:""`                    //下游要求客户端证书时使用
+	UpstreamTLSKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"下游客户端私钥" example:"" validate:""`                      //修改时为空则保留原私钥
+	UpstreamTLSServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"下游SNI" example:"" validate:""`        //覆盖发送给下游的SNI
+	UpstreamTLSInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书" example:"" validate:"max=1,min=0"` //仅用于开发环境
+	UpstreamH2c           int    `json:"upstream_h2c" form:"upstream_h2c" comment:"明文http2" example:"" validate:"max=1,min=0"`                   //使用明文http2连接下游
 }
 
 func (param *ServiceAddHTTPInput) BindValidParam(c *gin.Context) error {
@@ -119,14 +173,17 @@ type ServiceAddTcpInput struct {
 	ServiceName string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port        int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
-	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header头转换" validate:"
-"`
+	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header头转换" validate:""`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	GeoAllowCountry   string `json:"geo_allow_country" form:"geo_allow_country" comment:"允许访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoDenyCountry    string `json:"geo_deny_country" form:"geo_deny_country" comment:"拒绝访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoAllowAsn       string `json:"geo_allow_asn" form:"geo_allow_asn" comment:"允许访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
+	GeoDenyAsn        string `json:"geo_deny_asn" form:"geo_deny_asn" comment:"拒绝访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
@@ -143,11 +200,15 @@ type ServiceUpdateTcpInput struct {
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	GeoAllowCountry   string `json:"geo_allow_country" form:"geo_allow_country" comment:"允许访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoDenyCountry    string `json:"geo_deny_country" form:"geo_deny_country" comment:"拒绝访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoAllowAsn       string `json:"geo_allow_asn" form:"geo_allow_asn" comment:"允许访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
+	GeoDenyAsn        string `json:"geo_deny_asn" form:"geo_deny_asn" comment:"拒绝访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
@@ -163,16 +224,22 @@ type ServiceAddGrpcInput struct {
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
 	HeaderTransfor    string `json:"header_transfor" form:"header_transfor" comment:"metadata转换" validate:"valid_header_transfor"`
+	WebPrefix         string `json:"web_prefix" form:"web_prefix" comment:"gRPC-Web/JSON接入前缀，为空不开启" validate:"omitempty,valid_rule"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	GeoAllowCountry   string `json:"geo_allow_country" form:"geo_allow_country" comment:"允许访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoDenyCountry    string `json:"geo_deny_country" form:"geo_deny_country" comment:"拒绝访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoAllowAsn       string `json:"geo_allow_asn" form:"geo_allow_asn" comment:"允许访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
+	GeoDenyAsn        string `json:"geo_deny_asn" form:"geo_deny_asn" comment:"拒绝访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	MethodRules       []ServiceGrpcMethodRuleInput `json:"method_rules" form:"method_rules" comment:"方法级规则" validate:"dive"`
 }
 
 func (params *ServiceAddGrpcInput) GetValidParams(c *gin.Context) error {
@@ -185,19 +252,112 @@ type ServiceUpdateGrpcInput struct {
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
 	HeaderTransfor    string `json:"header_transfor" form:"header_transfor" comment:"metadata转换" validate:"valid_header_transfor"`
+	WebPrefix         string `json:"web_prefix" form:"web_prefix" comment:"gRPC-Web/JSON接入前缀，为空不开启" validate:"omitempty,valid_rule"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_ip_matcher"`
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	GeoAllowCountry   string `json:"geo_allow_country" form:"geo_allow_country" comment:"允许访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoDenyCountry    string `json:"geo_deny_country" form:"geo_deny_country" comment:"拒绝访问的国家，两位国家码以逗号间隔" validate:"valid_geo_country"`
+	GeoAllowAsn       string `json:"geo_allow_asn" form:"geo_allow_asn" comment:"允许访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
+	GeoDenyAsn        string `json:"geo_deny_asn" form:"geo_deny_asn" comment:"拒绝访问的ASN，以逗号间隔" validate:"valid_geo_asn"`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	MethodRules       []ServiceGrpcMethodRuleInput `json:"method_rules" form:"method_rules" comment:"方法级规则" validate:"dive"`
 }
 
 func (params *ServiceUpdateGrpcInput) GetValidParams(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, params)
 }
 
+
+type ServiceGrpcMethodRuleInput struct {
+	Method     string `json:"method" form:"method" comment:"方法匹配规则" example:"/pkg.Service/*" validate:"required,valid_grpc_method"` //方法匹配规则
+	Forbid     int    `json:"forbid" form:"forbid" comment:"禁止访问" example:"0" validate:"max=1,min=0"`                               //禁止访问
+	OpenAuth   int    `json:"open_auth" form:"open_auth" comment:"需要鉴权" example:"0" validate:"max=1,min=0"`                         //需要鉴权
+	FlowLimit  int    `json:"flow_limit" form:"flow_limit" comment:"方法限流" example:"0" validate:"min=0"`                             //方法限流
+	RoundType  int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"0" validate:"max=3,min=0"`                       //轮询方式
+	IpList     string `json:"ip_list" form:"ip_list" comment:"独立下游ip列表" example:"" validate:"omitempty,valid_ipportlist"`          //独立下游ip列表
+	WeightList string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"" validate:"omitempty,valid_weightlist"`       //权重列表
+}
+
+type ServiceGrpcDescriptorInput struct {
+	ID int64 `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"` //服务ID
+}
+
+func (params *ServiceGrpcDescriptorInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type ServiceHttpBodySchemaInput struct {
+	ID     int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                                             //服务ID
+	Method string `json:"method" form:"method" comment:"请求方法" example:"POST" validate:"required,oneof=* GET POST PUT PATCH DELETE"` //请求方法, *=全部
+	Path   string `json:"path" form:"path" comment:"路径匹配规则" example:"/api/users/*" validate:"required,valid_schema_path"`           //支持末尾*通配
+}
+
+func (params *ServiceHttpBodySchemaInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type ServiceHttpBodySchemaDeleteInput struct {
+	ID int64 `json:"id" form:"id" uri:"id" comment:"schema ID" validate:"required"`
+}
+
+func (params *ServiceHttpBodySchemaDeleteInput) GetValidParams(c *gin.Context) error {
+	return public.UriGetValidParams(c, params)
+}
+
+type ServiceOidcInput struct {
+	ID             int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                                          //服务ID
+	Enable         int    `json:"enable" form:"enable" comment:"是否开启" example:"1" validate:"max=1,min=0"`                                //是否开启
+	Issuer         string `json:"issuer" form:"issuer" comment:"签发方" example:"https://idp.example.com" validate:"omitempty,url"`         //签发方
+	JwksURL        string `json:"jwks_url" form:"jwks_url" comment:"公钥地址" example:"" validate:"omitempty,url"`                           //公钥地址
+	Audience       string `json:"audience" form:"audience" comment:"接受的aud" example:"gateway"`                                           //接受的aud
+	RequiredClaims string `json:"required_claims" form:"required_claims" comment:"必须的claim" example:"sub,email_verified=true"`           //必须的claim
+	AppClaim       string `json:"app_claim" form:"app_claim" comment:"映射租户id的claim" example:"azp"`                                       //映射租户id的claim
+	ForwardClaims  string `json:"forward_claims" form:"forward_claims" comment:"转发到下游的claim" example:"sub:X-User-Id,email:X-User-Email"` //转发到下游的claim
+}
+
+func (params *ServiceOidcInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type ServiceMtlsInput struct {
+	ID       int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                                                          //服务ID
+	Enable   int    `json:"enable" form:"enable" comment:"是否开启" example:"1" validate:"max=1,min=0"`                                                //是否开启
+	CaCert   string `json:"ca_cert" form:"ca_cert" comment:"PEM格式CA证书" example:""`                                                                 //PEM格式CA证书
+	AppField string `json:"app_field" form:"app_field" comment:"映射租户id的证书字段" example:"cn" validate:"omitempty,oneof=cn san_dns san_uri san_email"` //映射租户id的证书字段
+}
+
+func (params *ServiceMtlsInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type ServiceMirrorInput struct {
+	ID          int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                                         //服务ID
+	Enable      int    `json:"enable" form:"enable" comment:"是否开启" example:"1" validate:"max=1,min=0"`                               //是否开启
+	Percent     int    `json:"percent" form:"percent" comment:"复制比例" example:"10" validate:"max=100,min=0"`                          //复制比例
+	RoundType   int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                        //轮询方式
+	IpList      string `json:"ip_list" form:"ip_list" comment:"影子节点列表" example:"127.0.0.1:80" validate:"omitempty,valid_ipportlist"` //影子节点列表
+	WeightList  string `json:"weight_list" form:"weight_list" comment:"影子节点权重列表" example:"50" validate:"omitempty,valid_weightlist"` //影子节点权重列表
+	Timeout     int    `json:"timeout" form:"timeout" comment:"影子请求超时, 单位s" example:"5" validate:"min=0"`                            //影子请求超时
+	MaxBodySize int64  `json:"max_body_size" form:"max_body_size" comment:"复制的请求体上限" example:"1048576" validate:"min=0"`             //复制的请求体上限
+}
+
+func (params *ServiceMirrorInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type ServiceMirrorStatOutput struct {
+	Total          int64 `json:"total" form:"total" comment:"今日影子请求数" example:"" validate:""`                                  //今日影子请求数
+	Error          int64 `json:"error" form:"error" comment:"今日影子请求失败数" example:"" validate:""`                                //今日影子请求失败数
+	Dropped        int64 `json:"dropped" form:"dropped" comment:"并发已满丢弃数" example:"" validate:""`                              //并发已满丢弃数
+	Skipped        int64 `json:"skipped" form:"skipped" comment:"请求体过大跳过数" example:"" validate:""`                             //请求体过大跳过数
+	Compared       int64 `json:"compared" form:"compared" comment:"完成对比数" example:"" validate:""`                              //完成对比数
+	StatusDiff     int64 `json:"status_diff" form:"status_diff" comment:"状态码不一致数" example:"" validate:""`                      //状态码不一致数
+	AvgLatencyDiff int64 `json:"avg_latency_diff" form:"avg_latency_diff" comment:"平均耗时差, 影子减正常, 单位ms" example:"" validate:""` //平均耗时差
+}
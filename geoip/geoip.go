@@ -0,0 +1,187 @@
+package geoip
+
+import (
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Record ip对应的地理信息, 未命中时字段为零值
+type Record struct {
+	Country string //ISO 3166-1 两位国家码, 大写
+	ASN     uint
+}
+
+// DB MaxMind格式的国家库与ASN库, 两者均可为空
+// 文件整体读入内存, 重新载入时直接替换, 无需等待进行中的查询
+type DB struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+type countryRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+type asnRecord struct {
+	Number uint `maxminddb:"autonomous_system_number"`
+}
+
+func Open(countryFile, asnFile string) (*DB, error) {
+	db := &DB{}
+	var err error
+	if countryFile != "" {
+		if db.country, err = openReader(countryFile); err != nil {
+			return nil, err
+		}
+	}
+	if asnFile != "" {
+		if db.asn, err = openReader(asnFile); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func openReader(file string) (*maxminddb.Reader, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return nil, errors.WithMessage(err, file)
+	}
+	return reader, nil
+}
+
+// Lookup 国家优先使用 country, 缺失时使用 registered_country
+func (d *DB) Lookup(ip net.IP) Record {
+	record := Record{}
+	if d == nil || ip == nil {
+		return record
+	}
+	if d.country != nil {
+		item := countryRecord{}
+		if err := d.country.Lookup(ip, &item); err == nil {
+			record.Country = item.Country.IsoCode
+			if record.Country == "" {
+				record.Country = item.RegisteredCountry.IsoCode
+			}
+		}
+	}
+	if d.asn != nil {
+		item := asnRecord{}
+		if err := d.asn.Lookup(ip, &item); err == nil {
+			record.ASN = item.Number
+		}
+	}
+	return record
+}
+
+// Policy 服务的国家与ASN访问规则, 拒绝规则优先于允许规则
+type Policy struct {
+	allowCountry map[string]bool
+	denyCountry  map[string]bool
+	allowASN     map[uint]bool
+	denyASN      map[uint]bool
+}
+
+func NewPolicy(allowCountry, denyCountry, allowASN, denyASN string) (*Policy, error) {
+	p := &Policy{}
+	var err error
+	if p.allowCountry, err = ParseCountries(allowCountry); err != nil {
+		return nil, err
+	}
+	if p.denyCountry, err = ParseCountries(denyCountry); err != nil {
+		return nil, err
+	}
+	if p.allowASN, err = ParseASNs(allowASN); err != nil {
+		return nil, err
+	}
+	if p.denyASN, err = ParseASNs(denyASN); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Empty 未配置任何规则时不做地理校验
+func (p *Policy) Empty() bool {
+	return p == nil || (len(p.allowCountry) == 0 && len(p.denyCountry) == 0 && len(p.allowASN) == 0 && len(p.denyASN) == 0)
+}
+
+// Allow 配置了允许列表时, 查询不到国家或ASN的请求视为不在列表内
+func (p *Policy) Allow(record Record) (bool, string) {
+	if p.Empty() {
+		return true, ""
+	}
+	if record.Country != "" && p.denyCountry[record.Country] {
+		return false, "country " + record.Country + " denied"
+	}
+	if record.ASN != 0 && p.denyASN[record.ASN] {
+		return false, "asn AS" + strconv.FormatUint(uint64(record.ASN), 10) + " denied"
+	}
+	if len(p.allowCountry) > 0 && !p.allowCountry[record.Country] {
+		return false, "country " + unknown(record.Country) + " not allowed"
+	}
+	if len(p.allowASN) > 0 && !p.allowASN[record.ASN] {
+		return false, "asn AS" + strconv.FormatUint(uint64(record.ASN), 10) + " not allowed"
+	}
+	return true, ""
+}
+
+func unknown(country string) string {
+	if country == "" {
+		return "unknown"
+	}
+	return country
+}
+
+var countryRegexp = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ParseCountries 解析逗号或换行分隔的两位国家码, 不区分大小写
+func ParseCountries(list string) (map[string]bool, error) {
+	countries := map[string]bool{}
+	for _, item := range split(list) {
+		item = strings.ToUpper(item)
+		if !countryRegexp.MatchString(item) {
+			return nil, errors.Errorf("invalid country code %s", item)
+		}
+		countries[item] = true
+	}
+	return countries, nil
+}
+
+// ParseASNs 解析逗号或换行分隔的ASN, 支持 13335 与 AS13335 两种写法
+func ParseASNs(list string) (map[uint]bool, error) {
+	asns := map[uint]bool{}
+	for _, item := range split(list) {
+		number, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(item), "AS"), 10, 32)
+		if err != nil || number == 0 {
+			return nil, errors.Errorf("invalid asn %s", item)
+		}
+		asns[uint(number)] = true
+	}
+	return asns, nil
+}
+
+func split(list string) []string {
+	items := []string{}
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,67 @@
+package geoip
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	policy, err := NewPolicy("cn, US", "", "", "AS13335\n4134")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []struct {
+		record Record
+		want   bool
+	}{
+		{Record{Country: "CN", ASN: 4808}, true},
+		{Record{Country: "US"}, true},
+		{Record{Country: "JP"}, false},
+		{Record{}, false},
+		{Record{Country: "CN", ASN: 4134}, false},
+		{Record{Country: "US", ASN: 13335}, false},
+	} {
+		if got, reason := policy.Allow(item.record); got != item.want {
+			t.Fatalf("allow %+v: want %v, got %v %s", item.record, item.want, got, reason)
+		}
+	}
+
+	deny, err := NewPolicy("", "RU", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := deny.Allow(Record{}); !ok {
+		t.Fatal("unknown country should pass deny only policy")
+	}
+	if ok, _ := deny.Allow(Record{Country: "RU"}); ok {
+		t.Fatal("RU should be denied")
+	}
+
+	empty, _ := NewPolicy("", "", "", "")
+	if !empty.Empty() {
+		t.Fatal("policy should be empty")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, item := range []string{"CHN", "C1", "中国"} {
+		if _, err := ParseCountries(item); err == nil {
+			t.Fatalf("country %q should be invalid", item)
+		}
+	}
+	for _, item := range []string{"ASX", "0", "-1", "AS99999999999"} {
+		if _, err := ParseASNs(item); err == nil {
+			t.Fatalf("asn %q should be invalid", item)
+		}
+	}
+}
+
+func TestLookupWithoutDB(t *testing.T) {
+	var db *DB
+	if record := db.Lookup(net.ParseIP("1.1.1.1")); record != (Record{}) {
+		t.Fatalf("got %+v", record)
+	}
+	if _, err := Open("not_exists.mmdb", ""); err == nil {
+		t.Fatal("open not exists file should fail")
+	}
+}
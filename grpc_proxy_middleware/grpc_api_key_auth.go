@@ -0,0 +1,39 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"log"
+	"strings"
+)
+
+// GrpcApiKeyAuthMiddleware 通过 x-api-key metadata 匹配租户, 租户写入context供后续鉴权与限流使用
+func GrpcApiKeyAuthMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, ok := metadata.FromIncomingContext(ss.Context())
+		headerKey := strings.ToLower(public.ApiKeyHeader)
+		if ok && len(md.Get(headerKey)) > 0 {
+			apiKey := md.Get(headerKey)[0]
+			appInfo, err := dao.AppApiKeyHandler.Resolve(apiKey)
+			if err != nil {
+				return grpcUnauthenticated("ApiKey: " + err.Error())
+			}
+			if !appInfo.Allow(appInfo.GrantScopes(), serviceDetail.Info.ServiceName, "POST", info.FullMethod) {
+				return withIPBanKind(public.IPBanKindUnauthorized, grpcPermissionDenied("service", serviceDetail.Info.ServiceName, "app not granted for this service"))
+			}
+			//api key 不转发给下游
+			md = md.Copy()
+			delete(md, headerKey)
+			ctx := context.WithValue(metadata.NewIncomingContext(ss.Context(), md), grpcAppKey, appInfo)
+			ss = withStreamContext(ss, ctx)
+		}
+		if err := handler(srv, ss); err != nil {
+			log.Printf("GrpcApiKeyAuthMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package grpc_proxy_middleware
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/ip_matcher"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"log"
+)
+
+// GrpcAppWhiteIPMiddleware 校验租户的ip白名单, 位于全部识别租户的鉴权拦截器之后
+func GrpcAppWhiteIPMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if appInfo, ok := GrpcAppFromContext(ss.Context()); ok {
+			peerCtx, ok := peer.FromContext(ss.Context())
+			if !ok {
+				return grpcInternal(errors.New("peer not found with context"))
+			}
+			clientIP := ip_matcher.ClientIP(peerCtx.Addr.String())
+			if !appInfo.AllowIP(clientIP) {
+				log.Printf(" [WARN] app %s ip %s not in white ips, method:%s\n", appInfo.AppID, clientIP, info.FullMethod)
+				return withIPBanKind(public.IPBanKindUnauthorized, grpcPermissionDenied("app_white_ip", appInfo.AppID, fmt.Sprintf("%s not in app white ip list", clientIP)))
+			}
+		}
+		if err := handler(srv, ss); err != nil {
+			log.Printf("RPC failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
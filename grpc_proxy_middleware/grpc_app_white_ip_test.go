@@ -0,0 +1,43 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/yguilai/go-gateway/dao"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func TestGrpcAppWhiteIPMiddleware(t *testing.T) {
+	middleware := GrpcAppWhiteIPMiddleware(&dao.ServiceDetail{Info: &dao.ServiceInfo{ServiceName: "grpc_app_white_ip_test"}})
+	do := func(app *dao.App, ip string) codes.Code {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1000}})
+		if app != nil {
+			ctx = context.WithValue(ctx, grpcAppKey, app)
+		}
+		handler := func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		}
+		return status.Code(middleware(nil, &headerServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/pkg.Echo/Ping"}, handler))
+	}
+
+	for _, tc := range []struct {
+		app  *dao.App
+		ip   string
+		want codes.Code
+	}{
+		{nil, "1.1.1.1", codes.OK},
+		{&dao.App{AppID: "app_any"}, "1.1.1.1", codes.OK},
+		{&dao.App{AppID: "app_cidr", WhiteIPS: "10.0.0.0/8"}, "10.1.1.1", codes.OK},
+		{&dao.App{AppID: "app_cidr", WhiteIPS: "10.0.0.0/8"}, "1.1.1.1", codes.PermissionDenied},
+		//升级前无法解析的白名单拒绝全部来源ip
+		{&dao.App{AppID: "app_legacy", WhiteIPS: "192.168.1"}, "192.168.1.1", codes.PermissionDenied},
+	} {
+		if got := do(tc.app, tc.ip); got != tc.want {
+			t.Fatalf("%s: want %s, got %s", tc.ip, tc.want, got)
+		}
+	}
+}
@@ -4,34 +4,24 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
-	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/peer"
 	"log"
-	"strings"
 )
 
 //匹配接入方式 基于请求信息
 func GrpcBlackListMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		whileIpList := []string{}
-		if serviceDetail.AccessControl.WhiteList != "" {
-			whileIpList = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
-		}
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
-		}
-		peerAddr := peerCtx.Addr.String()
-		addrPos := strings.LastIndex(peerAddr, ":")
-		clientIP := peerAddr[0:addrPos]
-		blackIpList := []string{}
-		if serviceDetail.AccessControl.BlackList != "" {
-			blackIpList = strings.Split(serviceDetail.AccessControl.BlackList, ",")
+			return grpcInternal(errors.New("peer not found with context"))
 		}
-		if serviceDetail.AccessControl.OpenAuth == 1 && len(whileIpList) == 0 && len(blackIpList) > 0 {
-			if public.InStringSlice(blackIpList, clientIP) {
-				return errors.New(fmt.Sprintf("%s in black ip list", clientIP))
+		clientIP := ip_matcher.ClientIP(peerCtx.Addr.String())
+		blackMatcher := serviceDetail.AccessControl.BlackMatcher()
+		if serviceDetail.AccessControl.OpenAuth == 1 && serviceDetail.AccessControl.WhiteMatcher().Empty() && !blackMatcher.Empty() {
+			if blackMatcher.Match(clientIP) {
+				return grpcPermissionDenied("client", clientIP, fmt.Sprintf("%s in black ip list", clientIP))
 			}
 		}
 		if err := handler(srv, ss); err != nil {
@@ -11,12 +11,12 @@ func GrpcFlowCountMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error{
 		totalCounter, err := public.FlowCounterHandler.GetCounter(public.FlowTotal)
 		if err != nil {
-			return err
+			return grpcInternal(err)
 		}
 		totalCounter.Increase()
 		serviceCounter, err := public.FlowCounterHandler.GetCounter(public.FlowServicePrefix + serviceDetail.Info.ServiceName)
 		if err != nil {
-			return err
+			return grpcInternal(err)
 		}
 		serviceCounter.Increase()
 
@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/public"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/peer"
 	"log"
-	"strings"
 )
 
 func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
@@ -18,28 +18,28 @@ func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
 				float64(serviceDetail.AccessControl.ServiceFlowLimit))
 			if err != nil {
-				return err
+				return grpcInternal(err)
 			}
 			if !serviceLimiter.Allow() {
-				return errors.New(fmt.Sprintf("service flow limit %v", serviceDetail.AccessControl.ServiceFlowLimit))
+				return grpcResourceExhausted("service:"+serviceDetail.Info.ServiceName,
+					fmt.Sprintf("service flow limit %v", serviceDetail.AccessControl.ServiceFlowLimit))
 			}
 		}
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
+			return grpcInternal(errors.New("peer not found with context"))
 		}
-		peerAddr := peerCtx.Addr.String()
-		addrPos := strings.LastIndex(peerAddr, ":")
-		clientIP := peerAddr[0:addrPos]
+		clientIP := ip_matcher.ClientIP(peerCtx.Addr.String())
 		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
 			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
 				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
 			if err != nil {
-				return err
+				return grpcInternal(err)
 			}
 			if !clientLimiter.Allow() {
-				return errors.New(fmt.Sprintf("%v flow limit %v", clientIP, serviceDetail.AccessControl.ClientIPFlowLimit))
+				return withIPBanKind(public.IPBanKindRateLimit, grpcResourceExhausted("client:"+clientIP,
+					fmt.Sprintf("%v flow limit %v", clientIP, serviceDetail.AccessControl.ClientIPFlowLimit)))
 			}
 		}
 		if err := handler(srv, ss); err != nil {
@@ -0,0 +1,46 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/ip_matcher"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"log"
+	"strings"
+)
+
+// GrpcGeoIPMiddleware 按国家与ASN规则校验客户端, 并将国家码写入转发给下游的metadata
+func GrpcGeoIPMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		peerCtx, ok := peer.FromContext(ss.Context())
+		if !ok {
+			return grpcInternal(errors.New("peer not found with context"))
+		}
+		clientIP := ip_matcher.ClientIP(peerCtx.Addr.String())
+		record := dao.GeoIPManagerHandler.Lookup(clientIP)
+		if serviceDetail.AccessControl.OpenAuth == 1 {
+			if ok, reason := serviceDetail.AccessControl.GeoPolicy().Allow(record); !ok {
+				log.Printf(" [WARN] service %s client %s %s\n", serviceDetail.Info.ServiceName, clientIP, reason)
+				return grpcPermissionDenied("client", clientIP, reason)
+			}
+		}
+
+		//客户端传入的国家码不可信, 以网关查询结果为准
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		md = md.Copy()
+		key := strings.ToLower(public.GeoCountryHeader)
+		delete(md, key)
+		if record.Country != "" {
+			md.Set(key, record.Country)
+		}
+		ss = withStreamContext(ss, metadata.NewIncomingContext(ss.Context(), md))
+		if err := handler(srv, ss); err != nil {
+			log.Printf("RPC failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
@@ -2,34 +2,60 @@ package grpc_proxy_middleware
 
 import (
 	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
 	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/header_transform"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"log"
-	"strings"
 )
 
-//匹配接入方式 基于请求信息
+// GrpcHeaderTransferMiddleware 与http服务相同的header转换规则, 请求规则修改转发给下游的metadata, 响应规则修改返回给客户端的header
+// 旧格式的 add|edit 规则与旧版本一致, 同时写入返回给客户端的header
 func GrpcHeaderTransferMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		md, ok := metadata.FromIncomingContext(ss.Context())
-		if !ok {
-			return errors.New("miss metadata from context")
-		}
-		for _, item := range strings.Split(serviceDetail.GRPCRule.HeaderTransfor, ",") {
-			items := strings.Split(item, " ")
-			if len(items) != 3 {
-				continue
+		rules := dao.HeaderTransformHandler.GetRuleSet(serviceDetail.Info.ServiceName, serviceDetail.GRPCRule.HeaderTransfor)
+		if rules.HasRequest() || rules.HasResponse() {
+			md, _ := metadata.FromIncomingContext(ss.Context())
+			md = md.Copy()
+			clientIP := ""
+			if peerCtx, ok := peer.FromContext(ss.Context()); ok {
+				clientIP = ip_matcher.ClientIP(peerCtx.Addr.String())
 			}
-			if items[0] == "add" || items[0] == "edit" {
-				md.Set(items[1], items[2])
+			appID := ""
+			if app, ok := GrpcAppFromContext(ss.Context()); ok {
+				appID = app.AppID
 			}
-			if items[0] == "del" {
-				delete(md, items[1])
+			traceID := lib.GetTraceId()
+			if values := md.Get("com-header-rid"); len(values) > 0 {
+				traceID = values[0]
+			}
+			//grpc请求均为POST, 路径为 /包名.服务名/方法名
+			transformContext := &header_transform.Context{
+				Method: "POST",
+				Path:   info.FullMethod,
+				Vars: map[string]string{
+					header_transform.VarClientIP:    clientIP,
+					header_transform.VarAppID:       appID,
+					header_transform.VarServiceName: serviceDetail.Info.ServiceName,
+					header_transform.VarTraceID:     traceID,
+				},
+			}
+			rules.ApplyRequest(header_transform.MD(md), transformContext)
+			ss = withStreamContext(ss, metadata.NewIncomingContext(ss.Context(), md))
+			if rules.HasResponse() {
+				ss = &headerTransformServerStream{ServerStream: ss, rules: rules, ctx: transformContext}
+			}
+			//旧版本将 add|edit 的结果同时写入响应header
+			if rules.HasLegacy() {
+				legacyMD := metadata.MD{}
+				rules.ApplyLegacy(header_transform.MD(legacyMD), transformContext)
+				if err := ss.SetHeader(legacyMD); err != nil {
+					return grpcInternal(errors.WithMessage(err, "SetHeader"))
+				}
 			}
-		}
-		if err := ss.SetHeader(md); err != nil {
-			return errors.WithMessage(err, "SetHeader")
 		}
 		if err := handler(srv, ss); err != nil {
 			log.Printf("RPC failed with error %v\n", err)
@@ -38,3 +64,24 @@ func GrpcHeaderTransferMiddleware(serviceDetail *dao.ServiceDetail) func(srv int
 		return nil
 	}
 }
+
+// headerTransformServerStream 在header写回客户端前执行响应规则, grpc没有http状态码, status 条件不生效
+type headerTransformServerStream struct {
+	grpc.ServerStream
+	rules *header_transform.RuleSet
+	ctx   *header_transform.Context
+}
+
+func (s *headerTransformServerStream) SetHeader(md metadata.MD) error {
+	return s.ServerStream.SetHeader(s.transform(md))
+}
+
+func (s *headerTransformServerStream) SendHeader(md metadata.MD) error {
+	return s.ServerStream.SendHeader(s.transform(md))
+}
+
+func (s *headerTransformServerStream) transform(md metadata.MD) metadata.MD {
+	md = md.Copy()
+	s.rules.ApplyResponse(header_transform.MD(md), s.ctx)
+	return md
+}
@@ -0,0 +1,50 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/yguilai/go-gateway/dao"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+// headerServerStream 记录写回客户端的header
+type headerServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *headerServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *headerServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestGrpcHeaderTransferMiddleware(t *testing.T) {
+	serviceDetail := &dao.ServiceDetail{
+		Info: &dao.ServiceInfo{ServiceName: "grpc_header_transfer_test"},
+		//升级前保存的无效规则被跳过, 不影响其他规则
+		GRPCRule: &dao.GrpcRule{HeaderTransfor: "add x-legacy 1,add x-broken,set x-req 2,del x-secret"},
+	}
+	ss := &headerServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-secret", "s"))}
+	var forwarded metadata.MD
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		forwarded, _ = metadata.FromIncomingContext(stream.Context())
+		return nil
+	}
+	err := GrpcHeaderTransferMiddleware(serviceDetail)(nil, ss, &grpc.StreamServerInfo{FullMethod: "/pkg.Echo/Ping"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forwarded.Get("x-legacy")) != 1 || len(forwarded.Get("x-req")) != 1 || len(forwarded.Get("x-secret")) != 0 {
+		t.Fatalf("unexpected forwarded metadata %v", forwarded)
+	}
+	//旧格式 add 同时写入响应header
+	if len(ss.header.Get("x-legacy")) != 1 || len(ss.header.Get("x-req")) != 0 {
+		t.Fatalf("unexpected response header %v", ss.header)
+	}
+}
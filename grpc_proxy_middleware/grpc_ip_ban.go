@@ -0,0 +1,29 @@
+package grpc_proxy_middleware
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/ip_matcher"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// GrpcIPBanMiddleware 拒绝被动态封禁的ip, 并统计网关自身的鉴权失败与限流, 下游返回的错误不计入
+func GrpcIPBanMiddleware() func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		peerCtx, ok := peer.FromContext(ss.Context())
+		if !ok {
+			return grpcInternal(errors.New("peer not found with context"))
+		}
+		clientIP := ip_matcher.ClientIP(peerCtx.Addr.String())
+		if public.IPBanHandler.IsBanned(clientIP) {
+			return grpcPermissionDenied("client", clientIP, fmt.Sprintf("%s is banned", clientIP))
+		}
+		err := handler(srv, ss)
+		if violation, ok := err.(*grpcViolation); ok {
+			public.IPBanHandler.Report(clientIP, violation.kind)
+		}
+		return err
+	}
+}
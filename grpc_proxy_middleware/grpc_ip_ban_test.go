@@ -0,0 +1,50 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func TestGrpcIPBanMiddleware(t *testing.T) {
+	old := public.IPBanHandler
+	defer func() {
+		public.IPBanHandler = old
+	}()
+	manager := public.NewIPBanManager(public.NewMemoryIPBanStore())
+	manager.Enable = true
+	manager.LimitMap = map[string]int64{public.IPBanKindUnauthorized: 2}
+	public.IPBanHandler = manager
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("3.3.3.3"), Port: 1234}})
+	chain := []streamInterceptor{GrpcIPBanMiddleware()}
+	//下游返回的错误不计入
+	upstream := func(srv interface{}, ss grpc.ServerStream) error {
+		return status.Error(codes.Unauthenticated, "upstream")
+	}
+	denied := func(srv interface{}, ss grpc.ServerStream) error {
+		return grpcUnauthenticated("invalid token")
+	}
+	for i := 0; i < 2; i++ {
+		callChain(ctx, "/pkg.Echo/Ping", chain, upstream)
+	}
+	manager.Flush()
+	if manager.IsBanned("3.3.3.3") {
+		t.Fatal("upstream errors should not be counted")
+	}
+	for i := 0; i < 2; i++ {
+		if err := callChain(ctx, "/pkg.Echo/Ping", chain, denied); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	manager.Flush()
+	err := callChain(ctx, "/pkg.Echo/Ping", chain, denied)
+	if st := status.Convert(err); st.Code() != codes.PermissionDenied {
+		t.Fatalf("3.3.3.3 should be banned, got %v", st)
+	}
+}
@@ -1,7 +1,7 @@
 package grpc_proxy_middleware
 
 import (
-	"github.com/pkg/errors"
+	"context"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/public"
 	"google.golang.org/grpc"
@@ -13,33 +13,48 @@ import (
 //jwt auth token
 func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		md, ok := metadata.FromIncomingContext(ss.Context())
-		if !ok {
-			return errors.New("miss metadata from context")
-		}
 		authToken := ""
-		auths := md.Get("authorization")
-		if len(auths) > 0 {
-			authToken = auths[0]
+		if md, ok := metadata.FromIncomingContext(ss.Context()); ok {
+			if auths := md.Get("authorization"); len(auths) > 0 {
+				authToken = auths[0]
+			}
 		}
 		token := strings.ReplaceAll(authToken, "Bearer ", "")
-		appMatched := false
-		if token != "" {
-			claims, err := public.JwtDecode(token)
+		//已通过api key鉴权
+		_, appMatched := GrpcAppFromContext(ss.Context())
+		if serviceDetail.OidcRule.IsEnable() {
+			//外部身份提供方鉴权, 合法token即视为通过
+			var err error
+			if ss, err = grpcOidcAuth(ss, serviceDetail.OidcRule, token); err != nil {
+				return err
+			}
+			appMatched = appMatched || token != ""
+		} else if token != "" && !appMatched {
+			claims, err := public.JwtDecodeAccessToken(token)
+			if err != nil {
+				return grpcUnauthenticated("JwtDecode: " + err.Error())
+			}
+			appInfo, err := dao.AppManagerHandler.GetApp(claims.Issuer)
 			if err != nil {
-				return errors.WithMessage(err, "JwtDecode")
+				return grpcInternal(err)
 			}
-			appList := dao.AppManagerHandler.GetAppList()
-			for _, appInfo := range appList {
-				if appInfo.AppID == claims.Issuer {
-					md.Set("app", public.Obj2Json(appInfo))
-					appMatched = true
-					break
+			if appInfo != nil {
+				//租户需被授权访问该服务, grpc请求以方法名作为路径
+				if !appInfo.Allow(claims.Scopes(), serviceDetail.Info.ServiceName, "POST", info.FullMethod) {
+					return withIPBanKind(public.IPBanKindUnauthorized, grpcPermissionDenied("service", serviceDetail.Info.ServiceName, "app not granted for this service"))
 				}
+				//租户写入context, 供后续租户限流使用
+				ctx := context.WithValue(ss.Context(), grpcAppKey, appInfo)
+				ss = withStreamContext(ss, ctx)
+				appMatched = true
 			}
 		}
-		if serviceDetail.AccessControl.OpenAuth == 1 && !appMatched {
-			return errors.New("not match valid app")
+		needAuth := serviceDetail.AccessControl.OpenAuth == 1
+		if rule, ok := GrpcMethodRuleFromContext(ss.Context()); ok && rule.OpenAuth == 1 {
+			needAuth = true
+		}
+		if needAuth && !appMatched {
+			return grpcUnauthenticated("not match valid app")
 		}
 		if err := handler(srv, ss); err != nil {
 			log.Printf("GrpcJwtAuthTokenMiddleware failed with error %v\n", err)
@@ -48,3 +63,32 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		return nil
 	}
 }
+
+// grpcOidcAuth 校验外部token, 将指定claim写入metadata转发给下游, 租户映射成功时写入context
+func grpcOidcAuth(ss grpc.ServerStream, oidcRule *dao.OidcRule, token string) (grpc.ServerStream, error) {
+	md, ok := metadata.FromIncomingContext(ss.Context())
+	if !ok {
+		md = metadata.MD{}
+	}
+	md = md.Copy()
+	//清除客户端携带的同名metadata, 防止伪造身份
+	for header := range oidcRule.ForwardHeaders(nil) {
+		delete(md, strings.ToLower(header))
+	}
+	ctx := ss.Context()
+	if token != "" {
+		claims, err := oidcRule.Verify(token)
+		if err != nil {
+			return ss, grpcUnauthenticated("oidc: " + err.Error())
+		}
+		for header, value := range oidcRule.ForwardHeaders(claims) {
+			if value != "" {
+				md.Set(header, value)
+			}
+		}
+		if appInfo, ok := oidcRule.GetApp(claims); ok {
+			ctx = context.WithValue(ctx, grpcAppKey, appInfo)
+		}
+	}
+	return withStreamContext(ss, metadata.NewIncomingContext(ctx, md)), nil
+}
@@ -0,0 +1,40 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/grpc"
+	"log"
+)
+
+// GrpcMethodRuleMiddleware 按 info.FullMethod 匹配方法级规则, 处理禁用与方法限流, 并将规则写入context供后续使用
+func GrpcMethodRuleMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule := dao.MatchGrpcMethodRule(serviceDetail.GRPCMethodRules, info.FullMethod)
+		if rule == nil {
+			return handler(srv, ss)
+		}
+		if rule.Forbid == 1 {
+			return grpcPermissionDenied("method", info.FullMethod, fmt.Sprintf("method %s forbidden", info.FullMethod))
+		}
+		if rule.FlowLimit > 0 {
+			methodLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+rule.Method,
+				float64(rule.FlowLimit))
+			if err != nil {
+				return grpcInternal(err)
+			}
+			if !methodLimiter.Allow() {
+				return grpcResourceExhausted("method:"+info.FullMethod, fmt.Sprintf("method flow limit %v", rule.FlowLimit))
+			}
+		}
+		ctx := context.WithValue(ss.Context(), grpcMethodRuleKey, rule)
+		if err := handler(srv, withStreamContext(ss, ctx)); err != nil {
+			log.Printf("GrpcMethodRuleMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
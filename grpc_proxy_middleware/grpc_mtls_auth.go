@@ -0,0 +1,58 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/yguilai/go-gateway/dao"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"log"
+	"strings"
+)
+
+// GrpcMtlsAuthMiddleware 校验客户端证书, 证书信息写入metadata转发给下游, 映射到租户时写入context
+func GrpcMtlsAuthMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		mtlsRule := serviceDetail.MtlsRule
+		if mtlsRule.IsEnable() {
+			p, ok := peer.FromContext(ss.Context())
+			if !ok {
+				return grpcUnauthenticated("client certificate required")
+			}
+			tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+			if !ok {
+				return grpcUnauthenticated("client certificate requires tls")
+			}
+			cert, err := mtlsRule.Verify(tlsInfo.State.PeerCertificates)
+			if err != nil {
+				return grpcUnauthenticated(err.Error())
+			}
+
+			md, ok := metadata.FromIncomingContext(ss.Context())
+			if !ok {
+				md = metadata.MD{}
+			}
+			md = md.Copy()
+			//清除客户端携带的同名metadata, 防止伪造身份
+			for _, header := range dao.MtlsHeaders {
+				delete(md, strings.ToLower(header))
+			}
+			for header, value := range mtlsRule.ForwardHeaders(cert) {
+				if value != "" {
+					md.Set(header, value)
+				}
+			}
+			ctx := metadata.NewIncomingContext(ss.Context(), md)
+			if appInfo, ok := mtlsRule.GetApp(cert); ok {
+				ctx = context.WithValue(ctx, grpcAppKey, appInfo)
+			}
+			ss = withStreamContext(ss, ctx)
+		}
+		if err := handler(srv, ss); err != nil {
+			log.Printf("GrpcMtlsAuthMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,40 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/yguilai/go-gateway/dao"
+	"google.golang.org/grpc"
+)
+
+type grpcContextKey string
+
+const (
+	grpcMethodRuleKey grpcContextKey = "grpc_method_rule"
+	grpcAppKey        grpcContextKey = "grpc_app"
+)
+
+// wrappedServerStream 替换 ServerStream 的 context, 使拦截器写入的数据能向后传递
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedServerStream) Context() context.Context {
+	return w.ctx
+}
+
+func withStreamContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &wrappedServerStream{ServerStream: ss, ctx: ctx}
+}
+
+// GrpcMethodRuleFromContext 获取 GrpcMethodRuleMiddleware 匹配到的方法规则
+func GrpcMethodRuleFromContext(ctx context.Context) (*dao.GrpcMethodRule, bool) {
+	rule, ok := ctx.Value(grpcMethodRuleKey).(*dao.GrpcMethodRule)
+	return rule, ok
+}
+
+// GrpcAppFromContext 获取 GrpcApiKeyAuthMiddleware 或 GrpcJwtAuthTokenMiddleware 鉴权通过的租户
+func GrpcAppFromContext(ctx context.Context) (*dao.App, bool) {
+	app, ok := ctx.Value(grpcAppKey).(*dao.App)
+	return app, ok
+}
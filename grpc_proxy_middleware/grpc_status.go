@@ -0,0 +1,67 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 拦截器返回带状态码的错误, 客户端可按 details 区分原因
+
+func grpcStatusError(code codes.Code, msg string, details ...proto.Message) error {
+	st := status.New(code, msg)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcViolation 网关自身拒绝的请求, 由 GrpcIPBanMiddleware 按 kind 计入自动封禁
+type grpcViolation struct {
+	error
+	kind string
+}
+
+func (e *grpcViolation) GRPCStatus() *status.Status {
+	return status.Convert(e.error)
+}
+
+func withIPBanKind(kind string, err error) error {
+	return &grpcViolation{error: err, kind: kind}
+}
+
+// grpcUnauthenticated 未携带或携带了无效的凭证
+func grpcUnauthenticated(msg string) error {
+	return withIPBanKind(public.IPBanKindUnauthorized, grpcStatusError(codes.Unauthenticated, msg, &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "authorization", Description: msg},
+		},
+	}))
+}
+
+// grpcPermissionDenied 无权访问的资源, 如服务或方法
+func grpcPermissionDenied(resourceType, resourceName, msg string) error {
+	return grpcStatusError(codes.PermissionDenied, msg, &errdetails.ResourceInfo{
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Description:  msg,
+	})
+}
+
+// grpcResourceExhausted 超出限流或配额, subject 为被限制的对象, 如 service:name
+func grpcResourceExhausted(subject, msg string) error {
+	return grpcStatusError(codes.ResourceExhausted, msg, &errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{
+			{Subject: subject, Description: msg},
+		},
+	})
+}
+
+func grpcInternal(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
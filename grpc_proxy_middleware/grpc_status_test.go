@@ -0,0 +1,123 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
+	"testing"
+)
+
+type streamInterceptor func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error
+
+// callChain 依次执行拦截器, 与 grpc.ChainStreamInterceptor 顺序一致
+func callChain(ctx context.Context, fullMethod string, interceptors []streamInterceptor, handler grpc.StreamHandler) error {
+	info := &grpc.StreamServerInfo{FullMethod: fullMethod}
+	next := handler
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, h := interceptors[i], next
+		next = func(srv interface{}, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, info, h)
+		}
+	}
+	return next(nil, withStreamContext(nil, ctx))
+}
+
+func TestGrpcInterceptorStatus(t *testing.T) {
+	public.JwtRevokeHandler = public.NewJwtRevokeManager(public.NewMemoryJwtRevokeStore())
+	public.JwtKeyHandler = public.NewJwtKeyManager()
+	jwtKey, err := public.NewJwtKey("test", public.JwtAlgHS256, []byte("grpc_status_secret"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public.JwtKeyHandler.AddKey(jwtKey)
+	if err := public.JwtKeyHandler.SetActive("test"); err != nil {
+		t.Fatal(err)
+	}
+	app := &dao.App{AppID: "grpc_status_app", Qps: 1, Grants: []*dao.AppGrant{
+		{AppID: "grpc_status_app", ServiceName: "grpc_status_test", PathPattern: "/pkg.Echo/*"},
+	}}
+	dao.AppManagerHandler.AppMap[app.AppID] = app
+	serviceDetail := &dao.ServiceDetail{
+		Info:          &dao.ServiceInfo{ServiceName: "grpc_status_test"},
+		AccessControl: &dao.AccessControl{OpenAuth: 1},
+		GRPCMethodRules: []*dao.GrpcMethodRule{
+			{ID: 1, Method: "/pkg.Echo/Forbid", Forbid: 1},
+		},
+	}
+	chain := []streamInterceptor{
+		GrpcMethodRuleMiddleware(serviceDetail),
+		GrpcJwtAuthTokenMiddleware(serviceDetail),
+		GrpcJwtFlowLimitMiddleware(serviceDetail),
+	}
+	var handledApp *dao.App
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		handledApp, _ = GrpcAppFromContext(ss.Context())
+		return nil
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}})
+
+	//未携带token
+	err = callChain(metadata.NewIncomingContext(ctx, metadata.MD{}), "/pkg.Echo/Ping", chain, handler)
+	st := status.Convert(err)
+	if st.Code() != codes.Unauthenticated || len(st.Details()) != 1 {
+		t.Fatalf("unexpected status %v", st)
+	}
+	if _, ok := st.Details()[0].(*errdetails.BadRequest); !ok {
+		t.Fatalf("unexpected details %v", st.Details())
+	}
+
+	//方法被禁用
+	err = callChain(metadata.NewIncomingContext(ctx, metadata.MD{}), "/pkg.Echo/Forbid", chain, handler)
+	if st := status.Convert(err); st.Code() != codes.PermissionDenied {
+		t.Fatalf("unexpected status %v", st)
+	}
+
+	//token未包含该服务的授权
+	token, err := public.JwtEncode(public.JwtClaims{
+		StandardClaims: jwt.StandardClaims{Issuer: app.AppID},
+		Scope:          public.ServiceScopePrefix + "other_service",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = callChain(metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token)), "/pkg.Echo/Ping", chain, handler)
+	if st := status.Convert(err); st.Code() != codes.PermissionDenied {
+		t.Fatalf("unexpected status %v", st)
+	}
+
+	//鉴权通过后租户经由context传递给租户限流
+	token, err = public.JwtEncode(public.JwtClaims{
+		StandardClaims: jwt.StandardClaims{Issuer: app.AppID},
+		Scope:          strings.Join(app.IssueScopes([]string{"read_write"}), " "),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authCtx := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+	if err := callChain(authCtx, "/pkg.Echo/Ping", chain, handler); err != nil {
+		t.Fatal(err)
+	}
+	if handledApp != app {
+		t.Fatalf("app not propagated, got %+v", handledApp)
+	}
+	//限流器突发容量为 qps*3
+	for i := 0; i < 3 && err == nil; i++ {
+		err = callChain(authCtx, "/pkg.Echo/Ping", chain, handler)
+	}
+	st = status.Convert(err)
+	if st.Code() != codes.ResourceExhausted || len(st.Details()) != 1 {
+		t.Fatalf("unexpected status %v", st)
+	}
+	if quota, ok := st.Details()[0].(*errdetails.QuotaFailure); !ok || quota.Violations[0].Subject != "app:"+app.AppID {
+		t.Fatalf("unexpected details %v", st.Details())
+	}
+}
@@ -1,12 +1,15 @@
 package grpc_proxy_router
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"github.com/e421083458/grpc-proxy/proxy"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/grpc_proxy_middleware"
+	"github.com/yguilai/go-gateway/public"
 	"github.com/yguilai/go-gateway/reverse_proxy"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
 	"net"
 )
@@ -24,29 +27,49 @@ func GrpcServerRun() {
 		tempItem := serviceItem
 		go func(serviceDetail *dao.ServiceDetail) {
 			addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.Port)
-			rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+			upstreams, err := reverse_proxy.GrpcUpstreamHandler.GetUpstream(serviceDetail)
 			if err != nil {
-				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
+				log.Fatalf(" [INFO] GetGrpcUpstream %v err:%v\n", addr, err)
 				return
 			}
 			lis, err := net.Listen("tcp", addr)
 			if err != nil {
 				log.Fatalf(" [INFO] GrpcListen %v err:%v\n", addr, err)
 			}
-			grpcHandler := reverse_proxy.NewGrpcLoadBalanceHandler(rb)
-			s := grpc.NewServer(
+			//方法规则配置了独立节点时, 路由到对应的节点组
+			grpcHandler := reverse_proxy.NewGrpcRouteHandler(func(ctx context.Context, fullMethodName string) *reverse_proxy.GrpcUpstream {
+				rule, _ := grpc_proxy_middleware.GrpcMethodRuleFromContext(ctx)
+				return upstreams.Select(rule)
+			})
+			opts := []grpc.ServerOption{
 				grpc.ChainStreamInterceptor(
+					grpc_proxy_middleware.GrpcIPBanMiddleware(),
 					grpc_proxy_middleware.GrpcFlowCountMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcMethodRuleMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcFlowLimitMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcMtlsAuthMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcApiKeyAuthMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcJwtAuthTokenMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcAppWhiteIPMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcJwtFlowCountMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcJwtFlowLimitMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcWhiteListMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcBlackListMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcGeoIPMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcHeaderTransferMiddleware(serviceDetail),
 				),
-				grpc.CustomCodec(proxy.Codec()),
-				grpc.UnknownServiceHandler(grpcHandler))
+				grpc.CustomCodec(reverse_proxy.GrpcCodec()),
+				grpc.UnknownServiceHandler(grpcHandler),
+			}
+			//开启客户端证书鉴权时使用tls监听, 握手阶段即使用服务的CA校验
+			if serviceDetail.MtlsRule.IsEnable() {
+				tlsConfig, err := grpcMtlsConfig(serviceDetail.MtlsRule)
+				if err != nil {
+					log.Fatalf(" [INFO] GrpcMtlsConfig %v err:%v\n", addr, err)
+				}
+				opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+			}
+			s := grpc.NewServer(opts...)
 
 			grpcServerList = append(grpcServerList, &warpGrpcServer{
 				Addr:   addr,
@@ -60,6 +83,23 @@ func GrpcServerRun() {
 	}
 }
 
+func grpcMtlsConfig(mtlsRule *dao.MtlsRule) (*tls.Config, error) {
+	certFile, keyFile := public.ProxyCertFile()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := mtlsRule.CertPool()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}, nil
+}
+
 func GrpcServerStop() {
 	for _, grpcServer := range grpcServerList {
 		grpcServer.GracefulStop()
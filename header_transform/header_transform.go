@@ -0,0 +1,615 @@
+package header_transform
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 规则格式, 每行或每个逗号分隔一条:
+//   [req|resp] set|append|del|rename 头名称 [值] [if 条件...]
+// 值含空格或逗号时用双引号包裹, 引号内支持 \" 与 \\ 转义; 值中可使用变量 ${client_ip} ${app_id} ${service_name}
+// ${trace_id}, 以及 path 条件中 {name} 匹配到的 ${path.name}, $$ 表示 $
+// 条件之间为且的关系: method=GET|POST, path=/users/{id}/*, status=404|5xx(仅响应),
+// header:名称 (存在), !header:名称 (不存在), header:名称=值, 变量名=值, 等号均可换成 != 表示取反
+// 兼容旧格式 add|edit|del 头名称 值, add 与 edit 等同于 set; grpc服务的旧格式 add|edit 同时写入响应header
+
+const (
+	TargetRequest  = "req"
+	TargetResponse = "resp"
+
+	ActionSet    = "set"
+	ActionAppend = "append"
+	ActionDel    = "del"
+	ActionRename = "rename"
+
+	VarClientIP    = "client_ip"
+	VarAppID       = "app_id"
+	VarServiceName = "service_name"
+	VarTraceID     = "trace_id"
+)
+
+var (
+	headerNameRegexp = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+	paramNameRegexp  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	statusRegexp     = regexp.MustCompile(`^[1-5][0-9x]{2}$`)
+
+	knownVars = map[string]bool{VarClientIP: true, VarAppID: true, VarServiceName: true, VarTraceID: true}
+)
+
+// Header 请求头、响应头与grpc metadata的统一操作, http.Header 可直接使用
+type Header interface {
+	Values(key string) []string
+	Set(key, value string)
+	Add(key, value string)
+	Del(key string)
+}
+
+// MD grpc metadata 的 Header 实现, 键统一为小写
+type MD metadata.MD
+
+func (m MD) Values(key string) []string {
+	return metadata.MD(m).Get(key)
+}
+
+func (m MD) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m MD) Add(key, value string) {
+	metadata.MD(m).Append(key, value)
+}
+
+func (m MD) Del(key string) {
+	delete(m, strings.ToLower(key))
+}
+
+// Context 规则求值所需的请求信息, 请求规则的 Status 为0
+type Context struct {
+	Method string
+	Path   string
+	Status int
+	Vars   map[string]string
+}
+
+// RuleSet 解析后的规则, 按书写顺序依次执行, 前面规则的结果对后面的条件可见
+type RuleSet struct {
+	request  []*rule
+	response []*rule
+	legacy   []*rule
+}
+
+type rule struct {
+	action     string
+	name       string
+	value      *template
+	newName    string
+	conditions []*condition
+}
+
+func Parse(text string) (*RuleSet, error) {
+	set, errs := ParseValid(text)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return set, nil
+}
+
+// ParseValid 跳过无法解析的规则, 返回其余规则与各条规则的错误, 用于载入升级前保存的规则
+func ParseValid(text string) (*RuleSet, []error) {
+	set := &RuleSet{}
+	items, errs := ScanValid(text)
+	for _, tokens := range items {
+		target, r, err := parseRule(tokens)
+		if err != nil {
+			errs = append(errs, errors.WithMessage(err, strings.Join(tokens, " ")))
+			continue
+		}
+		if target == TargetResponse {
+			set.response = append(set.response, r)
+		} else {
+			set.request = append(set.request, r)
+		}
+		if tokens[0] == "add" || tokens[0] == "edit" {
+			set.legacy = append(set.legacy, r)
+		}
+	}
+	return set, errs
+}
+
+func (s *RuleSet) HasRequest() bool {
+	return s != nil && len(s.request) > 0
+}
+
+func (s *RuleSet) HasResponse() bool {
+	return s != nil && len(s.response) > 0
+}
+
+// HasLegacy 是否含有旧格式的 add|edit 规则
+func (s *RuleSet) HasLegacy() bool {
+	return s != nil && len(s.legacy) > 0
+}
+
+func (s *RuleSet) ApplyRequest(header Header, ctx *Context) {
+	if s != nil {
+		apply(s.request, header, ctx)
+	}
+}
+
+func (s *RuleSet) ApplyResponse(header Header, ctx *Context) {
+	if s != nil {
+		apply(s.response, header, ctx)
+	}
+}
+
+// ApplyLegacy 仅执行旧格式的 add|edit 规则, grpc服务以此保持旧版本写入响应header的行为
+func (s *RuleSet) ApplyLegacy(header Header, ctx *Context) {
+	if s != nil {
+		apply(s.legacy, header, ctx)
+	}
+}
+
+func apply(rules []*rule, header Header, ctx *Context) {
+	for _, r := range rules {
+		params, ok := r.match(header, ctx)
+		if !ok {
+			continue
+		}
+		switch r.action {
+		case ActionSet, ActionAppend:
+			value := r.value.render(ctx.Vars, params)
+			//变量为空时不写入空值
+			if value == "" && r.value.hasVar {
+				continue
+			}
+			if r.action == ActionSet {
+				header.Set(r.name, value)
+			} else {
+				header.Add(r.name, value)
+			}
+		case ActionDel:
+			header.Del(r.name)
+		case ActionRename:
+			values := append([]string{}, header.Values(r.name)...)
+			if len(values) == 0 {
+				continue
+			}
+			header.Del(r.name)
+			for _, value := range values {
+				header.Add(r.newName, value)
+			}
+		}
+	}
+}
+
+func (r *rule) match(header Header, ctx *Context) (map[string]string, bool) {
+	params := map[string]string{}
+	for _, cond := range r.conditions {
+		if !cond.match(header, ctx, params) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Scan 切分规则与单词, 逗号与换行分隔规则, 空白分隔单词, 引号内原样保留, 反斜杠转义其后的字符
+func Scan(text string) ([][]string, error) {
+	items, errs := scan(text, false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return items, nil
+}
+
+// ScanRaw 与 Scan 相同, 但引号内只有 \" 与 \\ 为转义, 其他反斜杠原样保留, 供响应体改写书写正则
+func ScanRaw(text string) ([][]string, error) {
+	items, errs := scan(text, true)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return items, nil
+}
+
+// ScanValid 与 Scan 相同, 但跳过引号未闭合的规则, 返回其余规则与各条规则的错误
+func ScanValid(text string) ([][]string, []error) {
+	return scan(text, false)
+}
+
+func scan(text string, raw bool) ([][]string, []error) {
+	items, errs := [][]string{}, []error{}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		tokens, n, err := scanRule(runes, raw)
+		runes = runes[n:]
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(tokens) > 0 {
+			items = append(items, tokens)
+		}
+	}
+	return items, errs
+}
+
+// scanRule 切分一条规则, 返回单词与消耗的字符数(含分隔符)
+// 引号未闭合时该规则在引号之后的第一个逗号或换行处结束, 不影响后面的规则
+func scanRule(text []rune, raw bool) ([]string, int, error) {
+	tokens := []string{}
+	token := strings.Builder{}
+	inToken, inQuote, escape := false, false, false
+	quoteAt := 0
+	endToken := func() {
+		if inToken {
+			tokens = append(tokens, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	for i, ch := range text {
+		switch {
+		case escape:
+			if raw && ch != '"' && ch != '\\' {
+				token.WriteRune('\\')
+			}
+			token.WriteRune(ch)
+			escape = false
+		case inQuote && ch == '\\':
+			escape = true
+		case ch == '"':
+			if !inQuote {
+				quoteAt = i
+			}
+			inQuote = !inQuote
+			inToken = true
+		case inQuote:
+			token.WriteRune(ch)
+		case ch == ',' || ch == '\n' || ch == '\r':
+			endToken()
+			return tokens, i + 1, nil
+		case ch == ' ' || ch == '\t':
+			endToken()
+		default:
+			token.WriteRune(ch)
+			inToken = true
+		}
+	}
+	if inQuote {
+		end := len(text)
+		for i := quoteAt; i < len(text); i++ {
+			if text[i] == ',' || text[i] == '\n' || text[i] == '\r' {
+				end = i
+				break
+			}
+		}
+		rest := end
+		if rest < len(text) {
+			rest++
+		}
+		return nil, rest, errors.Errorf("unterminated quote: %s", strings.TrimSpace(string(text[:end])))
+	}
+	endToken()
+	return tokens, len(text), nil
+}
+
+func parseRule(tokens []string) (string, *rule, error) {
+	target := TargetRequest
+	switch tokens[0] {
+	case "req", "request":
+		tokens = tokens[1:]
+	case "resp", "response":
+		target = TargetResponse
+		tokens = tokens[1:]
+	}
+	if len(tokens) < 2 {
+		return "", nil, errors.New("missing action or header name")
+	}
+	r := &rule{action: tokens[0], name: tokens[1]}
+	if !headerNameRegexp.MatchString(r.name) {
+		return "", nil, errors.Errorf("invalid header name %s", r.name)
+	}
+	args, conds := tokens[2:], []string{}
+	for i, token := range args {
+		if token == "if" {
+			if i == len(args)-1 {
+				return "", nil, errors.New("missing condition after if")
+			}
+			args, conds = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	params := map[string]bool{}
+	for _, item := range conds {
+		cond, err := parseCondition(item, target)
+		if err != nil {
+			return "", nil, err
+		}
+		if cond.path != nil {
+			for _, name := range cond.path.params {
+				params[name] = true
+			}
+		}
+		r.conditions = append(r.conditions, cond)
+	}
+
+	var err error
+	switch r.action {
+	case "add", "edit", ActionSet, ActionAppend:
+		if len(args) != 1 {
+			return "", nil, errors.Errorf("%s requires exactly one value", r.action)
+		}
+		if r.action == "add" || r.action == "edit" {
+			r.action = ActionSet
+		}
+		if r.value, err = parseTemplate(args[0], params); err != nil {
+			return "", nil, err
+		}
+	case ActionDel:
+		//旧格式 del 后带有无意义的值
+		if len(args) > 1 {
+			return "", nil, errors.New("del takes no value")
+		}
+	case ActionRename:
+		if len(args) != 1 || !headerNameRegexp.MatchString(args[0]) {
+			return "", nil, errors.New("rename requires a new header name")
+		}
+		r.newName = args[0]
+	default:
+		return "", nil, errors.Errorf("unknown action %s", r.action)
+	}
+	return target, r, nil
+}
+
+// condition 单个条件, key 为 method、path、status、header:名称 或变量名
+type condition struct {
+	key    string
+	header string
+	exists bool
+	negate bool
+	values []string
+	path   *pathPattern
+}
+
+func parseCondition(item, target string) (*condition, error) {
+	cond := &condition{}
+	if strings.HasPrefix(item, "!header:") || (strings.HasPrefix(item, "header:") && !strings.Contains(item, "=")) {
+		cond.negate = strings.HasPrefix(item, "!")
+		cond.exists = true
+		cond.header = item[strings.Index(item, ":")+1:]
+		if !headerNameRegexp.MatchString(cond.header) {
+			return nil, errors.Errorf("invalid header name in condition %s", item)
+		}
+		return cond, nil
+	}
+
+	index := strings.Index(item, "=")
+	if index <= 0 {
+		return nil, errors.Errorf("invalid condition %s", item)
+	}
+	cond.key, cond.values = item[:index], strings.Split(item[index+1:], "|")
+	if strings.HasSuffix(cond.key, "!") {
+		cond.key, cond.negate = strings.TrimSuffix(cond.key, "!"), true
+	}
+	switch {
+	case cond.key == "method":
+	case cond.key == "path":
+		if len(cond.values) != 1 {
+			return nil, errors.New("path condition takes one pattern")
+		}
+		pattern, err := parsePathPattern(cond.values[0])
+		if err != nil {
+			return nil, err
+		}
+		cond.path = pattern
+	case cond.key == "status":
+		if target != TargetResponse {
+			return nil, errors.New("status condition only applies to response rules")
+		}
+		for _, value := range cond.values {
+			if !statusRegexp.MatchString(value) {
+				return nil, errors.Errorf("invalid status %s", value)
+			}
+		}
+	case strings.HasPrefix(cond.key, "header:"):
+		cond.header = strings.TrimPrefix(cond.key, "header:")
+		if !headerNameRegexp.MatchString(cond.header) {
+			return nil, errors.Errorf("invalid header name in condition %s", item)
+		}
+	case knownVars[cond.key]:
+	default:
+		return nil, errors.Errorf("unknown condition %s", cond.key)
+	}
+	return cond, nil
+}
+
+func (c *condition) match(header Header, ctx *Context, params map[string]string) bool {
+	if c.exists {
+		return (len(header.Values(c.header)) > 0) != c.negate
+	}
+	var matched bool
+	switch {
+	case c.key == "method":
+		for _, value := range c.values {
+			if strings.EqualFold(value, ctx.Method) {
+				matched = true
+			}
+		}
+	case c.key == "path":
+		var pathParams map[string]string
+		if pathParams, matched = c.path.match(ctx.Path); matched {
+			for name, value := range pathParams {
+				params[name] = value
+			}
+		}
+	case c.key == "status":
+		for _, value := range c.values {
+			if matchStatus(value, ctx.Status) {
+				matched = true
+			}
+		}
+	case c.header != "":
+		actual := ""
+		if values := header.Values(c.header); len(values) > 0 {
+			actual = values[0]
+		}
+		matched = contains(c.values, actual)
+	default:
+		matched = contains(c.values, ctx.Vars[c.key])
+	}
+	return matched != c.negate
+}
+
+// matchStatus x 匹配任意一位数字, 如 5xx
+func matchStatus(pattern string, status int) bool {
+	actual := strconv.Itoa(status)
+	if status == 0 || len(actual) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != 'x' && pattern[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// pathPattern 按 / 分段匹配, {name} 匹配单段并记为参数, 末尾 * 匹配剩余任意段
+type pathPattern struct {
+	segments []string
+	wildcard bool
+	params   []string
+}
+
+func parsePathPattern(pattern string) (*pathPattern, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, errors.Errorf("path pattern %s must start with /", pattern)
+	}
+	p := &pathPattern{segments: splitPath(pattern)}
+	if n := len(p.segments); n > 0 && p.segments[n-1] == "*" {
+		p.segments, p.wildcard = p.segments[:n-1], true
+	}
+	for _, segment := range p.segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			if strings.ContainsAny(segment, "{}*") {
+				return nil, errors.Errorf("invalid path segment %s", segment)
+			}
+			continue
+		}
+		name := segment[1 : len(segment)-1]
+		if !paramNameRegexp.MatchString(name) {
+			return nil, errors.Errorf("invalid path param %s", segment)
+		}
+		p.params = append(p.params, name)
+	}
+	return p, nil
+}
+
+func (p *pathPattern) match(path string) (map[string]string, bool) {
+	segments := splitPath(path)
+	if len(segments) < len(p.segments) || (!p.wildcard && len(segments) != len(p.segments)) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range p.segments {
+		if strings.HasPrefix(segment, "{") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// template 规则的值, 由字面量与变量组成
+type template struct {
+	parts  []templatePart
+	hasVar bool
+}
+
+type templatePart struct {
+	literal  string
+	variable string
+}
+
+func parseTemplate(value string, params map[string]bool) (*template, error) {
+	t := &template{}
+	literal := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			literal.WriteByte(value[i])
+			continue
+		}
+		if strings.HasPrefix(value[i:], "$$") {
+			literal.WriteByte('$')
+			i++
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			literal.WriteByte('$')
+			continue
+		}
+		end := strings.Index(value[i:], "}")
+		if end < 0 {
+			return nil, errors.Errorf("unterminated variable in %s", value)
+		}
+		name := value[i+2 : i+end]
+		if strings.HasPrefix(name, "path.") {
+			if !params[strings.TrimPrefix(name, "path.")] {
+				return nil, errors.Errorf("variable ${%s} not defined by path condition", name)
+			}
+		} else if !knownVars[name] {
+			return nil, errors.Errorf("unknown variable ${%s}", name)
+		}
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+		t.parts = append(t.parts, templatePart{variable: name})
+		t.hasVar = true
+		i += end
+	}
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, templatePart{literal: literal.String()})
+	}
+	return t, nil
+}
+
+func (t *template) render(vars, params map[string]string) string {
+	result := strings.Builder{}
+	for _, part := range t.parts {
+		switch {
+		case part.variable == "":
+			result.WriteString(part.literal)
+		case strings.HasPrefix(part.variable, "path."):
+			result.WriteString(params[strings.TrimPrefix(part.variable, "path.")])
+		default:
+			result.WriteString(vars[part.variable])
+		}
+	}
+	return result.String()
+}
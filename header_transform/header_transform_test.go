@@ -0,0 +1,160 @@
+package header_transform
+
+import (
+	"google.golang.org/grpc/metadata"
+	"net/http"
+	"testing"
+)
+
+func TestParseLegacy(t *testing.T) {
+	rules, err := Parse("add X-A 1,edit X-B 2,del X-C 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("X-B", "old")
+	header.Set("X-C", "old")
+	rules.ApplyRequest(header, &Context{})
+	if header.Get("X-A") != "1" || header.Get("X-B") != "2" || header.Get("X-C") != "" {
+		t.Fatalf("unexpected header %v", header)
+	}
+}
+
+func TestParseValid(t *testing.T) {
+	rules, errs := ParseValid("add X-A 1,add X-B,set X-C 3,resp set X-D 4 if bad")
+	if len(errs) != 2 {
+		t.Fatalf("want 2 errors, got %v", errs)
+	}
+	header := http.Header{}
+	rules.ApplyRequest(header, &Context{})
+	if header.Get("X-A") != "1" || header.Get("X-C") != "3" {
+		t.Fatalf("valid rules should still apply, got %v", header)
+	}
+	response := http.Header{}
+	rules.ApplyLegacy(response, &Context{})
+	if response.Get("X-A") != "1" || response.Get("X-C") != "" {
+		t.Fatalf("legacy rules only, got %v", response)
+	}
+	if _, errs := ParseValid(`set X-A "1`); len(errs) != 1 {
+		t.Fatalf("unterminated quote should fail, got %v", errs)
+	}
+	//未闭合的引号只影响所在的规则
+	rules, errs = ParseValid("del X-Secret,add X-A a\"b,set X-C 3\nset X-E 5")
+	if len(errs) != 1 {
+		t.Fatalf("want 1 error, got %v", errs)
+	}
+	header = http.Header{"X-Secret": {"s"}}
+	rules.ApplyRequest(header, &Context{})
+	if _, ok := header["X-Secret"]; ok || header.Get("X-C") != "3" || header.Get("X-E") != "5" || header.Get("X-A") != "" {
+		t.Fatalf("rules after an unterminated quote should still apply, got %v", header)
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := Parse(`set X-Client "ip ${client_ip}, app ${app_id}"
+append Via gateway
+set X-User ${path.id} if method=GET|POST path=/users/{id}/*
+set X-App ${app_id}
+rename X-Old X-New
+del X-Debug if header:X-Debug=1
+resp set Cache-Control no-store if status=5xx
+resp del Server if !header:X-Keep`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &Context{
+		Method: "GET",
+		Path:   "/users/42/orders",
+		Vars:   map[string]string{VarClientIP: "1.2.3.4"},
+	}
+	header := http.Header{}
+	header.Set("Via", "proxy")
+	header.Set("X-Old", "v")
+	header.Set("X-Debug", "1")
+	rules.ApplyRequest(header, ctx)
+	if got := header.Get("X-Client"); got != "ip 1.2.3.4, app " {
+		t.Fatalf("X-Client %q", got)
+	}
+	if got := header.Values("Via"); len(got) != 2 {
+		t.Fatalf("Via %v", got)
+	}
+	if header.Get("X-User") != "42" {
+		t.Fatalf("X-User %q", header.Get("X-User"))
+	}
+	if _, ok := header["X-App"]; ok {
+		t.Fatal("empty variable should not set header")
+	}
+	if header.Get("X-Old") != "" || header.Get("X-New") != "v" || header.Get("X-Debug") != "" {
+		t.Fatalf("unexpected header %v", header)
+	}
+
+	resp := http.Header{}
+	resp.Set("Server", "nginx")
+	ctx.Status = 200
+	rules.ApplyResponse(resp, ctx)
+	if resp.Get("Cache-Control") != "" || resp.Get("Server") != "" {
+		t.Fatalf("unexpected response header %v", resp)
+	}
+	ctx.Status = 502
+	rules.ApplyResponse(resp, ctx)
+	if resp.Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected response header %v", resp)
+	}
+}
+
+func TestApplyMetadata(t *testing.T) {
+	rules, err := Parse("set X-Service ${service_name} if path=/pkg.Greeter/*,del x-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := metadata.Pairs("X-Secret", "1")
+	rules.ApplyRequest(MD(md), &Context{
+		Method: "POST",
+		Path:   "/pkg.Greeter/SayHello",
+		Vars:   map[string]string{VarServiceName: "greeter"},
+	})
+	if got := md.Get("x-service"); len(got) != 1 || got[0] != "greeter" {
+		t.Fatalf("x-service %v", got)
+	}
+	if len(md.Get("x-secret")) != 0 {
+		t.Fatal("x-secret not deleted")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, text := range []string{
+		"set X-A",
+		"set X-A 1 2",
+		`set X-A "1`,
+		"set X-A ${unknown}",
+		"set X-A ${path.id} if path=/users/*",
+		"set X-A 1 if",
+		"set X-A 1 if status=500",
+		"resp set X-A 1 if status=600",
+		"set X-A 1 if foo=bar",
+		"copy X-A X-B",
+		"rename X-A",
+		"set X:A 1",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q should be invalid", text)
+		}
+	}
+}
+
+func TestScanEscape(t *testing.T) {
+	items, err := Scan(`set X-A "a\"b\\c\d"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := items[0][2]; got != `a"b\cd` {
+		t.Fatalf("Scan got %q", got)
+	}
+	items, err = ScanRaw(`regex "a\"b\\c\d" x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := items[0][1]; got != `a"b\c\d` {
+		t.Fatalf("ScanRaw got %q", got)
+	}
+}
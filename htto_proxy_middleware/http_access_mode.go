@@ -11,6 +11,7 @@ func HTTPAccessModeMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		service, err := dao.ServiceManagerHandler.HTTPAccessMode(c)
 		if err != nil {
+			c.Set("ip_ban_kind", public.IPBanKindNotFound)
 			public.ResponseError(c, 1001, err)
 			c.Abort()
 			return
@@ -0,0 +1,71 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"net/url"
+	"strings"
+)
+
+// HTTPApiKeyAuthMiddleware 通过请求头或query参数中的api key匹配租户
+// 匹配成功后与jwt鉴权一样写入app, 租户统计与限流无需区分鉴权方式
+func HTTPApiKeyAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		apiKey := c.GetHeader(public.ApiKeyHeader)
+		query := c.Request.URL.Query()
+		if apiKey == "" {
+			apiKey = query.Get(public.ApiKeyQuery)
+		}
+		if apiKey == "" {
+			c.Next()
+			return
+		}
+		//api key 不转发给下游
+		c.Request.Header.Del(public.ApiKeyHeader)
+		if _, ok := query[public.ApiKeyQuery]; ok {
+			c.Request.URL.RawQuery = removeQueryParam(c.Request.URL.RawQuery, public.ApiKeyQuery)
+		}
+
+		appInfo, err := dao.AppApiKeyHandler.Resolve(apiKey)
+		if err != nil {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2002, err)
+			c.Abort()
+			return
+		}
+		if !appInfo.Allow(appInfo.GrantScopes(), serviceDetail.Info.ServiceName, c.Request.Method, c.Request.URL.Path) {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2004, errors.New("app not granted for this service"))
+			c.Abort()
+			return
+		}
+		c.Set("app", appInfo)
+		c.Next()
+	}
+}
+
+// removeQueryParam 只删除指定参数, 其余参数保持原有的顺序与编码
+func removeQueryParam(rawQuery, name string) string {
+	kept := []string{}
+	for _, part := range strings.Split(rawQuery, "&") {
+		key := part
+		if index := strings.Index(part, "="); index >= 0 {
+			key = part[:index]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil && unescaped == name {
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,16 @@
+package http_proxy_middleware
+
+import "testing"
+
+func TestRemoveQueryParam(t *testing.T) {
+	for raw, want := range map[string]string{
+		"api_key=gk_1&b=%2F&a=1+2": "b=%2F&a=1+2",
+		"b=x&api_key=gk_1&c":       "b=x&c",
+		"api%5Fkey=gk_1&q=a,b":     "q=a,b",
+		"api_key=gk_1":             "",
+	} {
+		if got := removeQueryParam(raw, "api_key"); got != want {
+			t.Errorf("%s: got %s, want %s", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package http_proxy_middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"log"
+)
+
+// HTTPAppWhiteIPMiddleware 校验租户的ip白名单, 位于全部识别租户的鉴权中间件之后
+func HTTPAppWhiteIPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appInterface, ok := c.Get("app")
+		if !ok {
+			c.Next()
+			return
+		}
+		appInfo := appInterface.(*dao.App)
+		if !appInfo.AllowIP(c.ClientIP()) {
+			log.Printf(" [WARN] app %s ip %s not in white ips, path:%s\n", appInfo.AppID, c.ClientIP(), c.Request.URL.Path)
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, public.AppIPNotAllowedCode, errors.New(fmt.Sprintf("%s not in app white ip list", c.ClientIP())))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
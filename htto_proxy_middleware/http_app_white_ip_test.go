@@ -0,0 +1,53 @@
+package http_proxy_middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPAppWhiteIPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	do := func(app *dao.App, remoteAddr string) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if app != nil {
+				c.Set("app", app)
+			}
+		}, HTTPAppWhiteIPMiddleware())
+		router.GET("/", func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		if w.Code == http.StatusOK && w.Body.String() == "ok" {
+			return 0
+		}
+		resp := &public.Response{}
+		json.Unmarshal(w.Body.Bytes(), resp)
+		return int(resp.Code)
+	}
+
+	for _, tc := range []struct {
+		app        *dao.App
+		remoteAddr string
+		want       int
+	}{
+		{nil, "1.1.1.1:1000", 0},
+		{&dao.App{AppID: "app_any"}, "1.1.1.1:1000", 0},
+		{&dao.App{AppID: "app_cidr", WhiteIPS: "10.0.0.0/8"}, "10.1.1.1:1000", 0},
+		{&dao.App{AppID: "app_cidr", WhiteIPS: "10.0.0.0/8"}, "1.1.1.1:1000", public.AppIPNotAllowedCode},
+		//升级前无法解析的白名单拒绝全部来源ip
+		{&dao.App{AppID: "app_legacy", WhiteIPS: "192.168.1"}, "192.168.1.1:1000", public.AppIPNotAllowedCode},
+	} {
+		if got := do(tc.app, tc.remoteAddr); got != tc.want {
+			t.Fatalf("%s: want %d, got %d", tc.remoteAddr, tc.want, got)
+		}
+	}
+}
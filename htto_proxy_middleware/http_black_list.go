@@ -6,7 +6,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/public"
-	"strings"
 )
 
 //匹配接入方式 基于请求信息
@@ -20,17 +19,9 @@ func HTTPBlackListMiddleware() gin.HandlerFunc {
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
 
-		whileIpList := []string{}
-		if serviceDetail.AccessControl.WhiteList != "" {
-			whileIpList = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
-		}
-
-		blackIpList := []string{}
-		if serviceDetail.AccessControl.BlackList != "" {
-			blackIpList = strings.Split(serviceDetail.AccessControl.BlackList, ",")
-		}
-		if serviceDetail.AccessControl.OpenAuth == 1 && len(whileIpList) == 0 && len(blackIpList) > 0 {
-			if public.InStringSlice(blackIpList, c.ClientIP()) {
+		blackMatcher := serviceDetail.AccessControl.BlackMatcher()
+		if serviceDetail.AccessControl.OpenAuth == 1 && serviceDetail.AccessControl.WhiteMatcher().Empty() && !blackMatcher.Empty() {
+			if blackMatcher.Match(c.ClientIP()) {
 				public.ResponseError(c, 3001, errors.New(fmt.Sprintf("%s in black ip list", c.ClientIP())))
 				c.Abort()
 				return
@@ -43,6 +43,7 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 				return
 			}
 			if !clientLimiter.Allow() {
+				c.Set("ip_ban_kind", public.IPBanKindRateLimit)
 				public.ResponseError(c, 5002, errors.New(fmt.Sprintf("%v flow limit %v", c.ClientIP(), serviceDetail.AccessControl.ClientIPFlowLimit)))
 				c.Abort()
 				return
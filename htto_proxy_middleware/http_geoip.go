@@ -0,0 +1,38 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"log"
+)
+
+// HTTPGeoIPMiddleware 按国家与ASN规则校验客户端, 并将国家码通过 X-Geo-Country 转发给下游
+func HTTPGeoIPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		record := dao.GeoRecord(c)
+		//客户端传入的国家头不可信, 以网关查询结果为准
+		c.Request.Header.Del(public.GeoCountryHeader)
+		if record.Country != "" {
+			c.Request.Header.Set(public.GeoCountryHeader, record.Country)
+		}
+		if serviceDetail.AccessControl.OpenAuth == 1 {
+			if ok, reason := serviceDetail.AccessControl.GeoPolicy().Allow(record); !ok {
+				log.Printf(" [WARN] service %s client %s %s\n", serviceDetail.Info.ServiceName, c.ClientIP(), reason)
+				public.ResponseError(c, public.GeoNotAllowedCode, errors.New(c.ClientIP()+" "+reason))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package http_proxy_middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/reverse_proxy"
+	"strings"
+)
+
+// grpc服务的 gRPC-Web/JSON 接入, 转换为对下游的grpc调用
+func HTTPGrpcWebMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if serviceDetail.Info.LoadType != public.LoadTypeGRPC {
+			c.Next()
+			return
+		}
+
+		//去掉接入前缀, 剩余 /pkg.Service/Method
+		fullMethod := strings.TrimPrefix(c.Request.URL.Path, serviceDetail.GRPCRule.WebPrefix)
+		if !strings.HasPrefix(fullMethod, "/") {
+			fullMethod = "/" + fullMethod
+		}
+		c.Request.URL.Path = fullMethod
+
+		//与grpc代理一致的方法级规则
+		rule := dao.MatchGrpcMethodRule(serviceDetail.GRPCMethodRules, fullMethod)
+		if rule != nil {
+			if rule.Forbid == 1 {
+				public.ResponseError(c, 2002, errors.New(fmt.Sprintf("method %s forbidden", fullMethod)))
+				c.Abort()
+				return
+			}
+			_, appOk := c.Get("app")
+			_, oidcOk := c.Get("oidc_claims")
+			if rule.OpenAuth == 1 && !appOk && !oidcOk {
+				public.ResponseError(c, 2003, errors.New("not match valid app"))
+				c.Abort()
+				return
+			}
+			if rule.FlowLimit > 0 {
+				methodLimiter, err := public.FlowLimiterHandler.GetLimiter(
+					public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+rule.Method,
+					float64(rule.FlowLimit))
+				if err != nil {
+					public.ResponseError(c, 5001, err)
+					c.Abort()
+					return
+				}
+				if !methodLimiter.Allow() {
+					public.ResponseError(c, 5002, errors.New(fmt.Sprintf("method flow limit %v", rule.FlowLimit)))
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		upstreams, err := reverse_proxy.GrpcUpstreamHandler.GetUpstream(serviceDetail)
+		if err != nil {
+			public.ResponseError(c, 2004, err)
+			c.Abort()
+			return
+		}
+		files, err := dao.GrpcDescriptorHandler.GetFiles(serviceDetail)
+		if err != nil {
+			public.ResponseError(c, 2005, err)
+			c.Abort()
+			return
+		}
+		proxy := reverse_proxy.NewGrpcWebProxy(func(ctx context.Context, fullMethodName string) *reverse_proxy.GrpcUpstream {
+			return upstreams.Select(rule)
+		}, files)
+		proxy.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
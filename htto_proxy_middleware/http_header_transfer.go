@@ -4,11 +4,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/header_transform"
 	"github.com/yguilai/go-gateway/public"
-	"strings"
 )
 
-//匹配接入方式 基于请求信息
+// HTTPHeaderTransferMiddleware 按服务的header转换规则修改请求头, 响应头规则在反向代理收到下游响应时执行
 func HTTPHeaderTransferMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		serverInterface, ok := c.Get("service")
@@ -18,17 +18,31 @@ func HTTPHeaderTransferMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
-		for _, item := range strings.Split(serviceDetail.HTTPRule.HeaderTransfor, ",") {
-			items := strings.Split(item, " ")
-			if len(items) != 3 {
-				continue
-			}
-			if items[0] == "add" || items[0] == "edit" {
-				c.Request.Header.Set(items[1], items[2])
-			}
-			if items[0] == "del" {
-				c.Request.Header.Del(items[1])
-			}
+		rules := dao.HeaderTransformHandler.GetRuleSet(serviceDetail.Info.ServiceName, serviceDetail.HTTPRule.HeaderTransfor)
+		if !rules.HasRequest() && !rules.HasResponse() {
+			c.Next()
+			return
+		}
+
+		appID := ""
+		if appInterface, ok := c.Get("app"); ok {
+			appID = appInterface.(*dao.App).AppID
+		}
+		//路径条件以网关收到的路径为准, 不受 strip_uri 与 url_rewrite 影响
+		transformContext := &header_transform.Context{
+			Method: c.Request.Method,
+			Path:   c.Request.URL.Path,
+			Vars: map[string]string{
+				header_transform.VarClientIP:    c.ClientIP(),
+				header_transform.VarAppID:       appID,
+				header_transform.VarServiceName: serviceDetail.Info.ServiceName,
+				header_transform.VarTraceID:     public.GetGinTraceContext(c).TraceId,
+			},
+		}
+		rules.ApplyRequest(c.Request.Header, transformContext)
+		if rules.HasResponse() {
+			c.Set("header_transform", rules)
+			c.Set("header_transform_context", transformContext)
 		}
 		c.Next()
 	}
@@ -0,0 +1,50 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"net"
+	"net/http"
+)
+
+// HTTPHttpsRedirectMiddleware 强制https的服务将http请求重定向到https, https响应按配置发送hsts
+// GET/HEAD 使用301, 其余方法使用308以保留请求方法与请求体
+func HTTPHttpsRedirectMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		//可信代理终止tls时以 X-Forwarded-Proto 为准, 避免重定向循环
+		if c.Request.TLS != nil || c.GetString("forwarded_proto") == "https" {
+			if hsts := serviceDetail.HTTPRule.HstsHeader(); hsts != "" {
+				c.Header("Strict-Transport-Security", hsts)
+			}
+			c.Next()
+			return
+		}
+		if serviceDetail.HTTPRule.ForceHttps != 1 {
+			c.Next()
+			return
+		}
+		host := c.Request.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if port := public.ProxyHttpsPort(); port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		code := http.StatusMovedPermanently
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		c.Redirect(code, "https://"+host+c.Request.URL.RequestURI())
+		c.Abort()
+	}
+}
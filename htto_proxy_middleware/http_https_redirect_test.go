@@ -0,0 +1,46 @@
+package http_proxy_middleware
+
+import (
+	"crypto/tls"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/dao"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHttpsRedirectMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rule := &dao.HttpRule{ForceHttps: 1, HstsMaxAge: 31536000, HstsIncludeSubdomains: 1}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", &dao.ServiceDetail{HTTPRule: rule})
+	}, HTTPHttpsRedirectMiddleware())
+	router.Any("/api/users", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for method, code := range map[string]int{http.MethodGet: http.StatusMovedPermanently, http.MethodPost: http.StatusPermanentRedirect} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "http://example.com:8080/api/users?id=1", nil)
+		router.ServeHTTP(w, req)
+		if w.Code != code || w.Header().Get("Location") != "https://example.com/api/users?id=1" {
+			t.Fatalf("%s: unexpected redirect %d %s", method, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/api/users", nil)
+	req.TLS = &tls.ConnectionState{}
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Fatalf("unexpected https response %d %s", w.Code, w.Header().Get("Strict-Transport-Security"))
+	}
+
+	rule.ForceHttps = 0
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/api/users", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Strict-Transport-Security") != "" {
+		t.Fatal("plain http should pass through without hsts when not forced")
+	}
+}
@@ -0,0 +1,25 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/public"
+)
+
+// HTTPIPBanMiddleware 拒绝被动态封禁的ip, 并统计鉴权失败、限流与未匹配服务的探测
+// 只统计网关自身的拒绝, 由各中间件设置 ip_ban_kind 标记, 下游返回的状态码不计入
+func HTTPIPBanMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		if public.IPBanHandler.IsBanned(clientIP) {
+			public.ResponseError(c, public.IPBannedCode, errors.New(clientIP+" is banned"))
+			c.Abort()
+			return
+		}
+		c.Next()
+
+		if kind := c.GetString("ip_ban_kind"); kind != "" {
+			public.IPBanHandler.Report(clientIP, kind)
+		}
+	}
+}
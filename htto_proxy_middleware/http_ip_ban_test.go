@@ -0,0 +1,87 @@
+package http_proxy_middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/public"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPIPBanMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := public.IPBanHandler
+	defer func() {
+		public.IPBanHandler = old
+	}()
+	manager := public.NewIPBanManager(public.NewMemoryIPBanStore())
+	manager.Enable = true
+	manager.LimitMap = map[string]int64{public.IPBanKindNotFound: 3, public.IPBanKindUnauthorized: 2}
+	public.IPBanHandler = manager
+
+	router := gin.New()
+	router.Use(HTTPIPBanMiddleware())
+	router.GET("/missing", func(c *gin.Context) {
+		c.Set("ip_ban_kind", public.IPBanKindNotFound)
+		c.String(http.StatusNotFound, "not found")
+	})
+	//下游返回的404不计入
+	router.GET("/upstream", func(c *gin.Context) {
+		c.String(http.StatusNotFound, "not found")
+	})
+	router.GET("/login", func(c *gin.Context) {
+		c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+		public.ResponseError(c, 2002, errors.New("invalid api key"))
+	})
+	do := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w
+	}
+	banned := func(w *httptest.ResponseRecorder) bool {
+		resp := &public.Response{}
+		json.Unmarshal(w.Body.Bytes(), resp)
+		return resp.Code == public.IPBannedCode
+	}
+
+	for i := 0; i < 3; i++ {
+		do("/upstream", "2.2.2.2:1000")
+		if w := do("/missing", "1.1.1.1:1000"); w.Code != http.StatusNotFound {
+			t.Fatalf("probe %d: unexpected %d", i, w.Code)
+		}
+	}
+	if manager.IsBanned("1.1.1.1") {
+		t.Fatal("violations should only take effect after flush")
+	}
+	if err := manager.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.IsBanned("1.1.1.1") || !banned(do("/missing", "1.1.1.1:1000")) {
+		t.Fatal("1.1.1.1 should be banned after 404 probes")
+	}
+	if manager.IsBanned("2.2.2.2") {
+		t.Fatal("2.2.2.2 should not be banned")
+	}
+
+	do("/login", "2.2.2.2:1000")
+	do("/login", "2.2.2.2:1000")
+	manager.Flush()
+	if !banned(do("/login", "2.2.2.2:1000")) {
+		t.Fatal("2.2.2.2 should be banned after auth failures")
+	}
+	list, _ := manager.List()
+	if len(list) != 2 {
+		t.Fatalf("want 2 bans, got %d", len(list))
+	}
+
+	if err := manager.Unban("2.2.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if banned(do("/login", "2.2.2.2:1000")) {
+		t.Fatal("2.2.2.2 should be unbanned")
+	}
+}
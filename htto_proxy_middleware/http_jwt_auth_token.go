@@ -25,25 +25,42 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 		// appInfo 放到 gin.context
 		token := strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", "")
 		//fmt.Println("token",token)
-		appMatched := false
-		if token != "" {
-			claims, err := public.JwtDecode(token)
+		//外部身份提供方鉴权
+		if serviceDetail.OidcRule.IsEnable() {
+			httpOidcAuth(c, serviceDetail, token)
+			return
+		}
+		//已通过api key鉴权
+		_, appMatched := c.Get("app")
+		if token != "" && !appMatched {
+			claims, err := public.JwtDecodeAccessToken(token)
 			if err != nil {
+				c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
 				public.ResponseError(c, 2002, err)
 				c.Abort()
 				return
 			}
 			//fmt.Println("claims.Issuer",claims.Issuer)
-			appList := dao.AppManagerHandler.GetAppList()
-			for _, appInfo := range appList {
-				if appInfo.AppID == claims.Issuer {
-					c.Set("app", appInfo)
-					appMatched = true
-					break
+			appInfo, err := dao.AppManagerHandler.GetApp(claims.Issuer)
+			if err != nil {
+				public.ResponseError(c, 2005, err)
+				c.Abort()
+				return
+			}
+			if appInfo != nil {
+				//租户需被授权访问该服务
+				if !appInfo.Allow(claims.Scopes(), serviceDetail.Info.ServiceName, c.Request.Method, c.Request.URL.Path) {
+					c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+					public.ResponseError(c, 2004, errors.New("app not granted for this service"))
+					c.Abort()
+					return
 				}
+				c.Set("app", appInfo)
+				appMatched = true
 			}
 		}
 		if serviceDetail.AccessControl.OpenAuth == 1 && !appMatched {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
 			public.ResponseError(c, 2003, errors.New("not match valid app"))
 			c.Abort()
 			return
@@ -51,3 +68,39 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// httpOidcAuth 校验外部token, 租户映射成功时用于租户限流, 未映射到租户的合法token同样放行
+func httpOidcAuth(c *gin.Context, serviceDetail *dao.ServiceDetail, token string) {
+	oidcRule := serviceDetail.OidcRule
+	//清除客户端携带的同名请求头, 防止伪造身份
+	for header := range oidcRule.ForwardHeaders(nil) {
+		c.Request.Header.Del(header)
+	}
+	if token == "" {
+		if _, ok := c.Get("app"); !ok && serviceDetail.AccessControl.OpenAuth == 1 {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2003, errors.New("missing oidc token"))
+			c.Abort()
+			return
+		}
+		c.Next()
+		return
+	}
+	claims, err := oidcRule.Verify(token)
+	if err != nil {
+		c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+		public.ResponseError(c, 2002, err)
+		c.Abort()
+		return
+	}
+	for header, value := range oidcRule.ForwardHeaders(claims) {
+		if value != "" {
+			c.Request.Header.Set(header, value)
+		}
+	}
+	if appInfo, ok := oidcRule.GetApp(claims); ok {
+		c.Set("app", appInfo)
+	}
+	c.Set("oidc_claims", claims)
+	c.Next()
+}
@@ -26,6 +26,7 @@ func HTTPJwtFlowLimitMiddleware() gin.HandlerFunc {
 				return
 			}
 			if !clientLimiter.Allow() {
+				c.Set("ip_ban_kind", public.IPBanKindRateLimit)
 				public.ResponseError(c, 5002, errors.New(fmt.Sprintf("%v flow limit %v", c.ClientIP(), appInfo.Qps)))
 				c.Abort()
 				return
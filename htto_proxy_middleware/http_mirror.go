@@ -0,0 +1,151 @@
+package http_proxy_middleware
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/reverse_proxy"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 影子响应体最多读取的字节数, 读取是为了复用连接, 超出部分直接关闭
+const mirrorDrainSize = 1 << 20
+
+type mirrorResult struct {
+	status   int
+	duration time.Duration
+}
+
+type mirrorBody struct {
+	io.Reader
+	io.Closer
+}
+
+// HTTPMirrorMiddleware 按比例将请求异步复制到影子节点组, 影子响应丢弃, 仅记录错误数与响应差异
+// 复制失败、请求体过大或并发已满时放弃复制, 正常请求照常转发
+func HTTPMirrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		rule := serviceDetail.MirrorRule
+		if !rule.IsEnable() || rand.Intn(100) >= rule.Percent || isUpgradeRequest(c.Request) {
+			c.Next()
+			return
+		}
+
+		stat := public.MirrorHandler.GetStat(serviceDetail.Info.ServiceName)
+		body, ok := bufferMirrorBody(c.Request, rule.GetMaxBodySize())
+		if !ok {
+			stat.Incr(public.MirrorStatSkipped, 1)
+			c.Next()
+			return
+		}
+		if !public.MirrorHandler.TryAcquire() {
+			stat.Incr(public.MirrorStatDropped, 1)
+			c.Next()
+			return
+		}
+		req, trans, cancel, err := newMirrorRequest(c, serviceDetail, body)
+		if err != nil {
+			public.MirrorHandler.Release()
+			stat.Incr(public.MirrorStatError, 1)
+			log.Printf(" [ERROR] service %s mirror request err:%v\n", serviceDetail.Info.ServiceName, err)
+			c.Next()
+			return
+		}
+
+		primary := make(chan mirrorResult, 1)
+		go sendMirror(req, trans, cancel, stat, primary)
+		start := time.Now()
+		defer func() {
+			primary <- mirrorResult{status: c.Writer.Status(), duration: time.Since(start)}
+		}()
+		c.Next()
+	}
+}
+
+func isUpgradeRequest(req *http.Request) bool {
+	return strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade")
+}
+
+// bufferMirrorBody 读取请求体供影子请求复用, 最多读取 maxSize+1 字节
+// 超出上限时以已读内容与剩余部分还原请求体, 返回false表示不复制
+func bufferMirrorBody(req *http.Request, maxSize int64) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil, true
+	}
+	if req.ContentLength > maxSize {
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil || int64(len(body)) > maxSize {
+		req.Body = &mirrorBody{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+		return nil, false
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.TransferEncoding = nil
+	return body, true
+}
+
+func newMirrorRequest(c *gin.Context, serviceDetail *dao.ServiceDetail, body []byte) (*http.Request, http.RoundTripper, context.CancelFunc, error) {
+	lb, err := dao.LoadBalancerHandler.GetMirrorLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	trans, err := dao.TransportorHandler.GetMirrorTrans(serviceDetail)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	//影子请求不随客户端请求结束而取消, 由复制规则的超时控制
+	ctx, cancel := context.WithTimeout(context.Background(), serviceDetail.MirrorRule.GetTimeout())
+	req, err := reverse_proxy.NewMirrorRequest(ctx, c, lb, body, serviceDetail.HTTPRule.ForwardedPolicy)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+	return req, trans, cancel, nil
+}
+
+// sendMirror 发送影子请求, 完成后与正常请求的结果对比, 正常请求在超时内未结束时不对比
+func sendMirror(req *http.Request, trans http.RoundTripper, cancel context.CancelFunc, stat *public.MirrorStat, primary <-chan mirrorResult) {
+	defer public.MirrorHandler.Release()
+	defer cancel()
+	defer func() {
+		if err := recover(); err != nil {
+			stat.Incr(public.MirrorStatError, 1)
+			log.Printf(" [ERROR] mirror %s panic:%v\n", req.URL.String(), err)
+		}
+	}()
+
+	stat.Incr(public.MirrorStatTotal, 1)
+	start := time.Now()
+	resp, err := trans.RoundTrip(req)
+	if err != nil {
+		stat.Incr(public.MirrorStatError, 1)
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, mirrorDrainSize))
+	resp.Body.Close()
+	duration := time.Since(start)
+
+	select {
+	case result := <-primary:
+		stat.Compare(result.status, resp.StatusCode, duration-result.duration)
+	case <-req.Context().Done():
+	}
+}
@@ -0,0 +1,80 @@
+package http_proxy_middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPMirrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	shadowBodies := make(chan string, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(public.MirrorHeader) != "1" {
+			t.Errorf("mirror header not set")
+		}
+		shadowBodies <- r.URL.Path + " " + string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer shadow.Close()
+
+	detail := &dao.ServiceDetail{
+		Info:        &dao.ServiceInfo{ServiceName: fmt.Sprintf("test_mirror_%d", time.Now().UnixNano())},
+		HTTPRule:    &dao.HttpRule{},
+		LoadBalance: &dao.LoadBalance{},
+		MirrorRule: &dao.MirrorRule{
+			Enable:      1,
+			Percent:     100,
+			IpList:      strings.TrimPrefix(shadow.URL, "http://"),
+			WeightList:  "50",
+			Timeout:     2,
+			MaxBodySize: 16,
+		},
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", detail)
+	}, HTTPMirrorMiddleware())
+	router.Any("/*path", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	for _, item := range []struct {
+		body   string
+		shadow string
+	}{
+		{"hello", "/api/users hello"},
+		{strings.Repeat("a", 17), ""},
+	} {
+		req := httptest.NewRequest("POST", "/api/users", ioutil.NopCloser(strings.NewReader(item.body)))
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != item.body {
+			t.Errorf("primary response changed: %d %s", w.Code, w.Body.String())
+		}
+		select {
+		case got := <-shadowBodies:
+			if got != item.shadow {
+				t.Errorf("shadow got %q, want %q", got, item.shadow)
+			}
+		case <-time.After(time.Second):
+			if item.shadow != "" {
+				t.Errorf("shadow request not sent")
+			}
+		}
+	}
+	stat := public.MirrorHandler.GetStat(detail.Info.ServiceName)
+	if stat.Get(public.MirrorStatSkipped) != 1 || stat.Get(public.MirrorStatTotal) != 1 {
+		t.Errorf("unexpected stat skipped=%d total=%d", stat.Get(public.MirrorStatSkipped), stat.Get(public.MirrorStatTotal))
+	}
+}
@@ -0,0 +1,54 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+)
+
+// HTTPMtlsAuthMiddleware 校验客户端证书, 证书信息转发给下游, 映射到租户时写入app
+// https监听只请求证书不做校验, 各服务使用各自的CA在此校验
+func HTTPMtlsAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		mtlsRule := serviceDetail.MtlsRule
+		if !mtlsRule.IsEnable() {
+			c.Next()
+			return
+		}
+
+		//清除客户端携带的同名请求头, 防止伪造身份
+		for _, header := range dao.MtlsHeaders {
+			c.Request.Header.Del(header)
+		}
+		if c.Request.TLS == nil {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2002, errors.New("client certificate requires https"))
+			c.Abort()
+			return
+		}
+		cert, err := mtlsRule.Verify(c.Request.TLS.PeerCertificates)
+		if err != nil {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2003, err)
+			c.Abort()
+			return
+		}
+		for header, value := range mtlsRule.ForwardHeaders(cert) {
+			if value != "" {
+				c.Request.Header.Set(header, value)
+			}
+		}
+		if appInfo, ok := mtlsRule.GetApp(cert); ok {
+			c.Set("app", appInfo)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/ip_matcher"
+	"net"
+	"strings"
+)
+
+// HTTPRealIPMiddleware 按可信代理解析客户端真实ip并写回 RemoteAddr, 之后的 c.ClientIP() 即为真实ip
+// 只有直连地址为可信代理时才采信 X-Forwarded-*, 避免客户端伪造ip绕过限流与黑白名单
+// 直连地址、是否可信、协议与host分别记录到 peer_addr、peer_trusted、forwarded_proto、forwarded_host, 供生成转发头使用
+func HTTPRealIPMiddleware(trusted *ip_matcher.Matcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		peerAddr := c.Request.RemoteAddr
+		peerIP := ip_matcher.ClientIP(peerAddr)
+		realIP := ip_matcher.RealIP(peerIP, c.Request.Header["X-Forwarded-For"], c.Request.Header.Get("X-Real-Ip"), trusted)
+
+		proto := "http"
+		if c.Request.TLS != nil {
+			proto = "https"
+		}
+		host := c.Request.Host
+		peerTrusted := trusted.Match(peerIP)
+		if peerTrusted {
+			if v := strings.ToLower(firstHeaderValue(c.Request.Header.Get("X-Forwarded-Proto"))); v == "http" || v == "https" {
+				proto = v
+			}
+			if v := firstHeaderValue(c.Request.Header.Get("X-Forwarded-Host")); v != "" {
+				host = v
+			}
+		}
+		c.Set("peer_addr", peerAddr)
+		c.Set("peer_trusted", peerTrusted)
+		c.Set("forwarded_proto", proto)
+		c.Set("forwarded_host", host)
+
+		_, port, err := net.SplitHostPort(peerAddr)
+		if err != nil {
+			port = "0"
+		}
+		c.Request.RemoteAddr = net.JoinHostPort(realIP, port)
+		c.Next()
+	}
+}
+
+// firstHeaderValue 多级代理以逗号追加时取最前面的值
+func firstHeaderValue(value string) string {
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,119 @@
+package http_proxy_middleware
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// schema校验失败时最多返回的错误条数
+	maxSchemaErrors = 5
+	// 服务未限制大小时, 为校验schema读取请求体的默认上限, 可通过 proxy.request_body.max_body_size 配置
+	defaultRequestBodySize = 4 << 20
+)
+
+// HTTPRequestBodyMiddleware 校验请求体大小、类型与JSON Schema, 不合法的请求不转发给下游
+// 长度未知或需要校验schema时读取请求体, 最多读取 MaxBodySize+1 字节, 之后以读取的内容替换请求体
+// 服务未配置 MaxBodySize 时按默认上限读取, 避免缓存任意大小的请求体
+func HTTPRequestBodyMiddleware() gin.HandlerFunc {
+	defaultMaxSize := int64(lib.GetIntConf("proxy.request_body.max_body_size"))
+	if defaultMaxSize <= 0 {
+		defaultMaxSize = defaultRequestBodySize
+	}
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		rule := serviceDetail.HTTPRule
+		req := c.Request
+
+		if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+			c.Next()
+			return
+		}
+		if rule.MaxBodySize > 0 && req.ContentLength > rule.MaxBodySize {
+			public.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, 2002, errors.New(fmt.Sprintf("request body exceeds %d bytes", rule.MaxBodySize)))
+			c.Abort()
+			return
+		}
+		if !rule.AllowContentTypeOf(req.Header.Get("Content-Type")) {
+			public.ResponseErrorWithStatus(c, http.StatusUnsupportedMediaType, 2003, errors.New("unsupported content type "+req.Header.Get("Content-Type")))
+			c.Abort()
+			return
+		}
+		schemaItem := dao.MatchHttpBodySchema(serviceDetail.HTTPBodySchemas, req.Method, req.URL.Path)
+		if schemaItem == nil && (rule.MaxBodySize == 0 || req.ContentLength > 0) {
+			c.Next()
+			return
+		}
+
+		maxSize := rule.MaxBodySize
+		if maxSize <= 0 {
+			maxSize = defaultMaxSize
+		}
+		if req.ContentLength > maxSize {
+			public.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, 2002, errors.New(fmt.Sprintf("request body exceeds %d bytes", maxSize)))
+			c.Abort()
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSize+1))
+		if err != nil {
+			public.ResponseErrorWithStatus(c, http.StatusBadRequest, 2004, err)
+			c.Abort()
+			return
+		}
+		if int64(len(body)) > maxSize {
+			public.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, 2002, errors.New(fmt.Sprintf("request body exceeds %d bytes", maxSize)))
+			c.Abort()
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.TransferEncoding = nil
+
+		if schemaItem != nil {
+			if err := validateBodySchema(schemaItem, body); err != nil {
+				public.ResponseErrorWithStatus(c, http.StatusBadRequest, 2005, err)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func validateBodySchema(schemaItem *dao.HttpBodySchema, body []byte) error {
+	schema, err := dao.HttpBodySchemaHandler.GetSchema(schemaItem)
+	if err != nil {
+		return err
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return errors.WithMessage(err, "invalid json body")
+	}
+	if result.Valid() {
+		return nil
+	}
+	messages := []string{}
+	for i, item := range result.Errors() {
+		if i >= maxSchemaErrors {
+			break
+		}
+		messages = append(messages, item.String())
+	}
+	return errors.New("request body does not match schema: " + strings.Join(messages, "; "))
+}
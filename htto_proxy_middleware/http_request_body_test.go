@@ -0,0 +1,79 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/dao"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRequestBodyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	detail := &dao.ServiceDetail{
+		HTTPRule: &dao.HttpRule{MaxBodySize: 64, AllowContentType: "application/json, text/*"},
+		HTTPBodySchemas: []*dao.HttpBodySchema{{
+			ID:     1,
+			Method: "POST",
+			Path:   "/api/users/*",
+			Schema: `{"type":"object","required":["name"],"properties":{"name":{"type":"string"}}}`,
+		}},
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", detail)
+	}, HTTPRequestBodyMiddleware())
+	router.Any("/*path", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	for _, item := range []struct {
+		path        string
+		contentType string
+		body        string
+		chunked     bool
+		code        int
+	}{
+		{"/api/users/1", "application/json", `{"name":"gateway"}`, false, http.StatusOK},
+		{"/api/users/1", "application/json; charset=utf-8", `{"name":"gateway"}`, true, http.StatusOK},
+		{"/api/users/1", "application/json", `{"age":1}`, false, http.StatusBadRequest},
+		{"/api/users/1", "application/json", `not json`, false, http.StatusBadRequest},
+		{"/api/users/1", "application/json", strings.Repeat("a", 65), false, http.StatusRequestEntityTooLarge},
+		{"/api/users/1", "application/json", strings.Repeat("a", 65), true, http.StatusRequestEntityTooLarge},
+		{"/api/users/1", "application/xml", `<a/>`, false, http.StatusUnsupportedMediaType},
+		{"/api/other", "text/plain", `hello`, false, http.StatusOK},
+	} {
+		var body io.Reader = strings.NewReader(item.body)
+		if item.chunked {
+			//隐藏长度, 模拟分块传输
+			body = ioutil.NopCloser(body)
+		}
+		req := httptest.NewRequest(http.MethodPost, item.path, body)
+		if item.chunked {
+			req.ContentLength = -1
+		}
+		req.Header.Set("Content-Type", item.contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != item.code {
+			t.Fatalf("%s %s: want %d, got %d %s", item.path, item.body, item.code, w.Code, w.Body.String())
+		}
+		if item.code == http.StatusOK && w.Body.String() != item.body {
+			t.Fatalf("%s: upstream got body %q", item.path, w.Body.String())
+		}
+	}
+	//服务未限制大小时, 校验schema仍按默认上限读取
+	detail.HTTPRule.MaxBodySize = 0
+	req := httptest.NewRequest(http.MethodPost, "/api/users/1", ioutil.NopCloser(strings.NewReader(strings.Repeat("a", defaultRequestBodySize+1))))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unlimited rule: want 413, got %d", w.Code)
+	}
+}
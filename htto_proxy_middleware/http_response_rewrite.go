@@ -0,0 +1,51 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/response_rewrite"
+	"github.com/yguilai/go-gateway/reverse_proxy"
+)
+
+// HTTPResponseRewriteMiddleware 准备响应改写配置, 由反向代理收到下游响应时执行
+// 需在 strip_uri 之前, 以便记录去掉的前缀
+func HTTPResponseRewriteMiddleware() gin.HandlerFunc {
+	maxBodySize := int64(lib.GetIntConf("proxy.response_rewrite.max_body_size"))
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		rules, err := dao.ResponseRewriteHandler.GetRuleSet(serviceDetail.Info.ServiceName, serviceDetail.HTTPRule.ResponseRewrite)
+		if err != nil {
+			public.ResponseError(c, 2002, err)
+			c.Abort()
+			return
+		}
+		if rules.Empty() && serviceDetail.HTTPRule.RewriteLocation != 1 {
+			c.Next()
+			return
+		}
+
+		prefix := ""
+		if serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL && serviceDetail.HTTPRule.NeedStripUri == 1 {
+			prefix = serviceDetail.HTTPRule.Rule
+		}
+		proto, host := reverse_proxy.ClientProtoHost(c)
+		c.Set("response_rewriter", &response_rewrite.Rewriter{
+			Rules:       rules,
+			Location:    serviceDetail.HTTPRule.RewriteLocation == 1,
+			Prefix:      prefix,
+			Proto:       proto,
+			Host:        host,
+			MaxBodySize: maxBodySize,
+		})
+		c.Next()
+	}
+}
@@ -34,8 +34,11 @@ func HTTPReverseProxyMiddleware() gin.HandlerFunc {
 
 		//创建 reverseproxy
 		//使用 reverseproxy.ServerHTTP(c.Request,c.Response)
-		proxy := reverse_proxy.NewLoadBalanceReverseProxy(c, lb, trans)
-		proxy.ServeHTTP(c.Writer, c.Request)
+		proxy := reverse_proxy.NewLoadBalanceReverseProxy(c, lb, trans, serviceDetail.HTTPRule.ForwardedPolicy)
+		//X-Forwarded-For已在director中按策略设置, 清空RemoteAddr避免ReverseProxy再次追加
+		req := *c.Request
+		req.RemoteAddr = ""
+		proxy.ServeHTTP(c.Writer, &req)
 		c.Abort()
 		return
 	}
@@ -0,0 +1,129 @@
+package http_proxy_middleware
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/public"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPSignAuthMiddleware 校验请求签名, 签名方案见 public.SignString
+// 校验通过的租户写入app, 与api key鉴权的租户需一致, 之后的jwt鉴权不再重复校验
+func HTTPSignAuthMiddleware() gin.HandlerFunc {
+	maxBodySize := int64(lib.GetIntConf("proxy.sign.max_body_size"))
+	if maxBodySize <= 0 {
+		maxBodySize = public.SignMaxBodySize
+	}
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			public.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if serviceDetail.AccessControl.OpenSign != 1 {
+			c.Next()
+			return
+		}
+
+		appID := c.GetHeader(public.SignAppIDHeader)
+		signature := c.GetHeader(public.SignSignatureHeader)
+		nonce := c.GetHeader(public.SignNonceHeader)
+		if appID == "" || signature == "" || nonce == "" {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2002, errors.New("missing request signature"))
+			c.Abort()
+			return
+		}
+		timestamp, err := strconv.ParseInt(c.GetHeader(public.SignTimestampHeader), 10, 64)
+		if err != nil {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2003, errors.New("invalid signature timestamp"))
+			c.Abort()
+			return
+		}
+		if delta := time.Now().Unix() - timestamp; delta > public.SignTimeWindow || delta < -public.SignTimeWindow {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2003, errors.New("signature timestamp expired"))
+			c.Abort()
+			return
+		}
+
+		appInfo, err := dao.AppManagerHandler.GetApp(appID)
+		if err != nil {
+			public.ResponseError(c, 2004, err)
+			c.Abort()
+			return
+		}
+		if appInfo == nil {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2004, errors.New("not match valid app"))
+			c.Abort()
+			return
+		}
+
+		//签名需要完整的请求体, 最多读取 maxBodySize+1 字节
+		body := []byte{}
+		if c.Request.Body != nil {
+			if c.Request.ContentLength > maxBodySize {
+				public.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, 2011, errors.New(fmt.Sprintf("signed request body exceeds %d bytes", maxBodySize)))
+				c.Abort()
+				return
+			}
+			if body, err = ioutil.ReadAll(io.LimitReader(c.Request.Body, maxBodySize+1)); err != nil {
+				public.ResponseError(c, 2005, err)
+				c.Abort()
+				return
+			}
+			if int64(len(body)) > maxBodySize {
+				public.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, 2011, errors.New(fmt.Sprintf("signed request body exceeds %d bytes", maxBodySize)))
+				c.Abort()
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		if !public.VerifySign(appInfo.Secret, public.SignString(c.Request, public.SignBodyHash(body)), signature) {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2006, errors.New("invalid request signature"))
+			c.Abort()
+			return
+		}
+		//签名校验通过后才记录nonce, 防止伪造请求占用nonce
+		added, err := public.SignNonceHandler.Add(appID+":"+nonce, public.SignTimeWindow*2)
+		if err != nil {
+			public.ResponseError(c, 2007, err)
+			c.Abort()
+			return
+		}
+		if !added {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2008, errors.New("request replayed"))
+			c.Abort()
+			return
+		}
+
+		if current, ok := c.Get("app"); ok && current.(*dao.App).AppID != appInfo.AppID {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2009, errors.New("signature app mismatch"))
+			c.Abort()
+			return
+		}
+		if !appInfo.Allow(appInfo.GrantScopes(), serviceDetail.Info.ServiceName, c.Request.Method, c.Request.URL.Path) {
+			c.Set("ip_ban_kind", public.IPBanKindUnauthorized)
+			public.ResponseError(c, 2010, errors.New("app not granted for this service"))
+			c.Abort()
+			return
+		}
+		c.Set("app", appInfo)
+		c.Next()
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/public"
-	"strings"
+	"github.com/yguilai/go-gateway/url_rewrite"
 )
 
 //匹配接入方式 基于请求信息
@@ -20,9 +20,8 @@ func HTTPStripUriMiddleware() gin.HandlerFunc {
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
 
 		if serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL && serviceDetail.HTTPRule.NeedStripUri == 1 {
-			//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
-			c.Request.URL.Path = strings.Replace(c.Request.URL.Path, serviceDetail.HTTPRule.Rule, "", 1)
-			//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
+			//只去掉开头的前缀, 路径中间出现的相同片段保留
+			url_rewrite.StripPrefix(c.Request.URL, serviceDetail.HTTPRule.Rule)
 		}
 		//http://127.0.0.1:8080/test_http_string/abbb
 		//http://127.0.0.1:2004/abbb
@@ -5,11 +5,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/public"
-	"regexp"
-	"strings"
 )
 
-//匹配接入方式 基于请求信息
+// HTTPUrlRewriteMiddleware 按服务的url重写规则改写路径、查询参数与转发Host
 func HTTPUrlRewriteMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		serverInterface, ok := c.Get("service")
@@ -19,21 +17,10 @@ func HTTPUrlRewriteMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
-		for _, item := range strings.Split(serviceDetail.HTTPRule.UrlRewrite, ",") {
-			//fmt.Println("item rewrite",item)
-			items := strings.Split(item, " ")
-			if len(items) != 2 {
-				continue
-			}
-			regexp, err := regexp.Compile(items[0])
-			if err != nil {
-				//fmt.Println("regexp.Compile err",err)
-				continue
-			}
-			//fmt.Println("before rewrite",c.Request.URL.Path)
-			replacePath := regexp.ReplaceAll([]byte(c.Request.URL.Path), []byte(items[1]))
-			c.Request.URL.Path = string(replacePath)
-			//fmt.Println("after rewrite",c.Request.URL.Path)
+		rules := dao.UrlRewriteHandler.GetRuleSet(serviceDetail.Info.ServiceName, serviceDetail.HTTPRule.UrlRewrite)
+		//改写后的Host由反向代理设置到转发请求
+		if host := rules.Rewrite(c.Request.URL); host != "" {
+			c.Set("rewrite_host", host)
 		}
 		c.Next()
 	}
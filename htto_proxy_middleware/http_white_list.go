@@ -6,7 +6,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/public"
-	"strings"
 )
 
 //匹配接入方式 基于请求信息
@@ -20,12 +19,9 @@ func HTTPWhiteListMiddleware() gin.HandlerFunc {
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
 
-		iplist := []string{}
-		if serviceDetail.AccessControl.WhiteList != "" {
-			iplist = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
-		}
-		if serviceDetail.AccessControl.OpenAuth == 1 && len(iplist) > 0 {
-			if !public.InStringSlice(iplist, c.ClientIP()) {
+		whiteMatcher := serviceDetail.AccessControl.WhiteMatcher()
+		if serviceDetail.AccessControl.OpenAuth == 1 && !whiteMatcher.Empty() {
+			if !whiteMatcher.Match(c.ClientIP()) {
 				public.ResponseError(c, 3001, errors.New(fmt.Sprintf("%s not in white ip list", c.ClientIP())))
 				c.Abort()
 				return
@@ -2,9 +2,12 @@ package http_proxy_router
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/gin-gonic/gin"
 	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/middleware"
+	"github.com/yguilai/go-gateway/public"
 	"log"
 	"net/http"
 	"time"
@@ -43,10 +46,25 @@ func HttpsServerRun() {
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.https.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.https.max_header_bytes")),
 	}
+	//按SNI选择证书, 证书更新后定时载入, 无需重启
+	if err := dao.CertManagerHandler.Load(); err != nil {
+		log.Fatalf(" [ERROR] https_proxy_run load cert err:%v\n", err)
+	}
+	HttpsSrvHandler.TLSConfig = &tls.Config{GetCertificate: dao.CertManagerHandler.GetCertificate}
+	//存在开启客户端证书鉴权的服务时请求客户端证书, 由各服务在中间件中使用各自的CA校验
+	for _, serviceDetail := range dao.ServiceManagerHandler.ServiceSlice {
+		if serviceDetail.Info.LoadType == public.LoadTypeHTTP && serviceDetail.MtlsRule.IsEnable() {
+			HttpsSrvHandler.TLSConfig.ClientAuth = tls.RequestClientCert
+			break
+		}
+	}
+	reloadInterval := lib.GetIntConf("proxy.https.cert_reload_interval")
+	if reloadInterval <= 0 {
+		reloadInterval = 60
+	}
+	go dao.CertManagerHandler.Watch(time.Duration(reloadInterval) * time.Second)
 	log.Printf(" [INFO] https_proxy_run %s\n", lib.GetStringConf("proxy.https.addr"))
-	// 以下命令只在编译机有效，如果是交叉编译情况下需要单独设置路径
-	//if err := HttpsSrvHandler.ListenAndServeTLS(cert_file.Path("server.crt"), cert_file.Path("server.key")); err != nil && err!=http.ErrServerClosed {
-	if err := HttpsSrvHandler.ListenAndServeTLS("./cert_file/server.crt", "./cert_file/server.key"); err != nil && err != http.ErrServerClosed {
+	if err := HttpsSrvHandler.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
 	}
 }
@@ -63,6 +81,7 @@ func HttpServerStop() {
 func HttpsServerStop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	dao.CertManagerHandler.Stop()
 	if err := HttpsSrvHandler.Shutdown(ctx); err != nil {
 		log.Fatalf(" [ERROR] https_proxy_stop err:%v\n", err)
 	}
@@ -2,15 +2,26 @@ package http_proxy_router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/common/lib"
 	"github.com/yguilai/go-gateway/controller"
 	http_proxy_middleware "github.com/yguilai/go-gateway/htto_proxy_middleware"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/middleware"
+	"github.com/yguilai/go-gateway/public"
+	"log"
 )
 
 func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 	//todo 优化点1
 	//router := gin.Default()
 	router := gin.New()
+	//真实ip只由可信代理决定, 不使用gin默认信任的 X-Forwarded-For
+	router.ForwardedByClientIP = false
+	trusted, err := ip_matcher.New(lib.GetStringSliceConf("proxy.base.trusted_proxies"))
+	if err != nil {
+		log.Printf(" [WARN] proxy.base.trusted_proxies %v\n", err)
+	}
+	router.Use(http_proxy_middleware.HTTPRealIPMiddleware(trusted))
 	router.Use(middlewares...)
 	router.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -18,24 +29,38 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		})
 	})
 
+	//ACME HTTP-01验证, 不经过服务的代理中间件
+	router.GET(public.AcmeChallengePath+":token", controller.AcmeChallenge)
+
 	oauth := router.Group("/oauth")
-	oauth.Use(middleware.TranslationMiddleware())
+	oauth.Use(http_proxy_middleware.HTTPIPBanMiddleware(), middleware.TranslationMiddleware())
 	{
 		controller.OAuthRegister(oauth)
 	}
 
 	router.Use(
+		http_proxy_middleware.HTTPIPBanMiddleware(),
 		http_proxy_middleware.HTTPAccessModeMiddleware(),
+		http_proxy_middleware.HTTPHttpsRedirectMiddleware(),
 		http_proxy_middleware.HTTPFlowCountMiddleware(),
 		http_proxy_middleware.HTTPFlowLimitMiddleware(),
+		http_proxy_middleware.HTTPRequestBodyMiddleware(),
+		http_proxy_middleware.HTTPMtlsAuthMiddleware(),
+		http_proxy_middleware.HTTPApiKeyAuthMiddleware(),
+		http_proxy_middleware.HTTPSignAuthMiddleware(),
 		http_proxy_middleware.HTTPJwtAuthTokenMiddleware(),
+		http_proxy_middleware.HTTPAppWhiteIPMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowCountMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowLimitMiddleware(),
 		http_proxy_middleware.HTTPWhiteListMiddleware(),
 		http_proxy_middleware.HTTPBlackListMiddleware(),
+		http_proxy_middleware.HTTPGeoIPMiddleware(),
 		http_proxy_middleware.HTTPHeaderTransferMiddleware(),
+		http_proxy_middleware.HTTPResponseRewriteMiddleware(),
 		http_proxy_middleware.HTTPStripUriMiddleware(),
 		http_proxy_middleware.HTTPUrlRewriteMiddleware(),
+		http_proxy_middleware.HTTPGrpcWebMiddleware(),
+		http_proxy_middleware.HTTPMirrorMiddleware(),
 		http_proxy_middleware.HTTPReverseProxyMiddleware(),
 	)
 
@@ -0,0 +1,182 @@
+package ip_matcher
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// 支持的格式, 以逗号或换行间隔:
+//
+//	单个ip         10.0.0.1, ::1
+//	CIDR           10.0.0.0/8, 2001:db8::/32
+//	范围           10.0.0.1-10.0.0.100, 2001:db8::1-2001:db8::ff
+//	ipv4前缀       10.0. 或 10.0.*, 兼容旧版本的前缀匹配
+
+type ipRange struct {
+	start net.IP
+	end   net.IP
+}
+
+// Matcher 编译后的ip列表, 创建后只读, 可并发使用
+type Matcher struct {
+	ips    map[string]struct{}
+	nets   []*net.IPNet
+	ranges []ipRange
+}
+
+// Parse 解析以逗号或换行间隔的ip列表
+func Parse(list string) (*Matcher, error) {
+	return New(Split(list))
+}
+
+// New 解析ip列表, 忽略空项, 返回包含全部合法项的Matcher与第一个错误
+func New(list []string) (*Matcher, error) {
+	m := &Matcher{ips: map[string]struct{}{}}
+	var firstErr error
+	for _, item := range list {
+		if err := m.add(strings.TrimSpace(item)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return m, firstErr
+}
+
+// Split 按逗号与换行拆分
+func Split(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+}
+
+func (m *Matcher) add(item string) error {
+	if item == "" {
+		return nil
+	}
+	if strings.Contains(item, "/") {
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return errors.Errorf("invalid cidr %s", item)
+		}
+		m.nets = append(m.nets, ipNet)
+		return nil
+	}
+	if pos := strings.Index(item, "-"); pos > 0 {
+		start := net.ParseIP(strings.TrimSpace(item[:pos]))
+		end := net.ParseIP(strings.TrimSpace(item[pos+1:]))
+		if start == nil || end == nil || (start.To4() == nil) != (end.To4() == nil) || bytes.Compare(start.To16(), end.To16()) > 0 {
+			return errors.Errorf("invalid ip range %s", item)
+		}
+		m.ranges = append(m.ranges, ipRange{start: start.To16(), end: end.To16()})
+		return nil
+	}
+	if strings.HasSuffix(item, ".") || strings.HasSuffix(item, ".*") {
+		ipNet, err := prefixToNet(item)
+		if err != nil {
+			return err
+		}
+		m.nets = append(m.nets, ipNet)
+		return nil
+	}
+	ip := net.ParseIP(item)
+	if ip == nil {
+		return errors.Errorf("invalid ip %s", item)
+	}
+	m.ips[ip.String()] = struct{}{}
+	return nil
+}
+
+// prefixToNet 将 10.0. 或 10.0.* 转换为 10.0.0.0/16
+func prefixToNet(item string) (*net.IPNet, error) {
+	octets := strings.Split(strings.TrimSuffix(strings.TrimSuffix(item, "*"), "."), ".")
+	if len(octets) == 0 || len(octets) > 3 {
+		return nil, errors.Errorf("invalid ip prefix %s", item)
+	}
+	cidr := strings.Join(octets, ".") + strings.Repeat(".0", 4-len(octets))
+	_, ipNet, err := net.ParseCIDR(cidr + "/" + strconv.Itoa(8*len(octets)))
+	if err != nil {
+		return nil, errors.Errorf("invalid ip prefix %s", item)
+	}
+	return ipNet, nil
+}
+
+// Empty 列表为空时白名单不生效
+func (m *Matcher) Empty() bool {
+	return m == nil || (len(m.ips) == 0 && len(m.nets) == 0 && len(m.ranges) == 0)
+}
+
+// Match ip为空或无法解析时不匹配
+func (m *Matcher) Match(ip string) bool {
+	return m.MatchIP(net.ParseIP(ip))
+}
+
+func (m *Matcher) MatchIP(ip net.IP) bool {
+	if m.Empty() || ip == nil {
+		return false
+	}
+	if _, ok := m.ips[ip.String()]; ok {
+		return true
+	}
+	for _, ipNet := range m.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	for _, item := range m.ranges {
+		//ipv4与ipv6不在同一范围内比较
+		if (ip.To4() == nil) != (item.start.To4() == nil) {
+			continue
+		}
+		ip16 := ip.To16()
+		if bytes.Compare(ip16, item.start) >= 0 && bytes.Compare(ip16, item.end) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 从 host:port 中取出ip, 支持 [::1]:80 形式的ipv6地址
+func ClientIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return strings.Trim(addr, "[]")
+	}
+	return host
+}
+
+// RealIP 按可信代理解析客户端真实ip
+// 直连地址不是可信代理时直接使用直连地址, 否则从右向左遍历 X-Forwarded-For, 第一个非可信代理的地址即为客户端ip
+// 无法解析的条目视为伪造, 停止遍历并使用其右侧最近的地址
+func RealIP(remoteIP string, forwardedFor []string, realIP string, trusted *Matcher) string {
+	if !trusted.Match(remoteIP) {
+		return remoteIP
+	}
+	hops := []string{}
+	for _, value := range forwardedFor {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				hops = append(hops, item)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+			return ip.String()
+		}
+		return remoteIP
+	}
+	clientIP := remoteIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(ClientIP(hops[i]))
+		if ip == nil {
+			break
+		}
+		clientIP = ip.String()
+		if !trusted.MatchIP(ip) {
+			break
+		}
+	}
+	return clientIP
+}
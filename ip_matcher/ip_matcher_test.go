@@ -0,0 +1,82 @@
+package ip_matcher
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	matcher, err := Parse("10.0.0.1, 192.168.0.0/16\n172.16.0.10-172.16.0.20,2001:db8::/32, ::1, 100.64.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"10.0.0.1":        true,
+		"10.0.0.2":        false,
+		"192.168.3.4":     true,
+		"172.16.0.15":     true,
+		"172.16.0.21":     false,
+		"2001:db8::1":     true,
+		"2001:db9::1":     false,
+		"0:0:0:0:0:0:0:1": true,
+		"100.64.1.1":      true,
+		"100.65.1.1":      false,
+		"::ffff:10.0.0.1": true,
+		"":                false,
+		"not-an-ip":       false,
+	} {
+		if got := matcher.Match(ip); got != want {
+			t.Fatalf("match %q: want %v, got %v", ip, want, got)
+		}
+	}
+}
+
+func TestMatcherInvalid(t *testing.T) {
+	for _, list := range []string{"10.0.0.300", "10.0.0.0/33", "10.0.0.9-10.0.0.1", "10.0.0.1-::1", "example.com"} {
+		if _, err := Parse(list); err == nil {
+			t.Fatalf("%q should be invalid", list)
+		}
+	}
+	matcher, err := Parse("10.0.0.1,bad")
+	if err == nil || !matcher.Match("10.0.0.1") {
+		t.Fatal("valid entries should still match when the list contains invalid entries")
+	}
+	if empty, _ := Parse(" , "); !empty.Empty() {
+		t.Fatal("blank list should be empty")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for addr, want := range map[string]string{
+		"10.0.0.1:8080":     "10.0.0.1",
+		"[2001:db8::1]:443": "2001:db8::1",
+		"10.0.0.1":          "10.0.0.1",
+		"[::1]":             "::1",
+	} {
+		if got := ClientIP(addr); got != want {
+			t.Fatalf("client ip of %q: want %q, got %q", addr, want, got)
+		}
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, err := Parse("127.0.0.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []struct {
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"1.1.1.1", []string{"2.2.2.2"}, "3.3.3.3", "1.1.1.1"},
+		{"127.0.0.1", []string{"2.2.2.2, 10.0.0.2"}, "", "2.2.2.2"},
+		{"127.0.0.1", []string{"9.9.9.9, 2.2.2.2", "10.0.0.2"}, "", "2.2.2.2"},
+		{"127.0.0.1", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"127.0.0.1", []string{"evil, 10.0.0.2"}, "", "10.0.0.2"},
+		{"127.0.0.1", nil, "2.2.2.2", "2.2.2.2"},
+		{"127.0.0.1", nil, "", "127.0.0.1"},
+	} {
+		if got := RealIP(item.remote, item.xff, item.realIP, trusted); got != item.want {
+			t.Fatalf("real ip %v: want %s, got %s", item, item.want, got)
+		}
+	}
+}
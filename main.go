@@ -6,11 +6,15 @@ import (
 	"github.com/yguilai/go-gateway/dao"
 	"github.com/yguilai/go-gateway/grpc_proxy_router"
 	"github.com/yguilai/go-gateway/http_proxy_router"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/reverse_proxy"
 	"github.com/yguilai/go-gateway/router"
 	"github.com/yguilai/go-gateway/tcp_proxy_router"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 var (
@@ -33,7 +37,7 @@ func main() {
 		defer lib.Destroy()
 		router.HttpServerRun()
 
-		quit := make(chan os.Signal)
+		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 
@@ -43,6 +47,39 @@ func main() {
 		defer lib.Destroy()
 		dao.ServiceManagerHandler.LoadOnce()
 		dao.AppManagerHandler.LoadOnce()
+		dao.AppApiKeyHandler.LoadOnce()
+		apiKeyInterval := lib.GetIntConf("proxy.api_key.reload_interval")
+		if apiKeyInterval <= 0 {
+			apiKeyInterval = 10
+		}
+		go dao.AppApiKeyHandler.Watch(time.Duration(apiKeyInterval) * time.Second)
+		if err := dao.LoadJwtKeys(); err != nil {
+			log.Fatalf(" [ERROR] LoadJwtKeys err:%v\n", err)
+		}
+
+		dao.AcmeManagerHandler.LoadConf()
+		if dao.GeoIPManagerHandler.Configured() {
+			if err := dao.GeoIPManagerHandler.Load(); err != nil {
+				log.Printf(" [WARN] load geoip err:%v\n", err)
+			}
+			geoipInterval := lib.GetIntConf("proxy.geoip.reload_interval")
+			if geoipInterval <= 0 {
+				geoipInterval = 60
+			}
+			go dao.GeoIPManagerHandler.Watch(time.Duration(geoipInterval) * time.Second)
+		}
+		public.IPBanHandler.LoadConf()
+		banSyncInterval := lib.GetIntConf("proxy.ip_ban.sync_interval")
+		if banSyncInterval <= 0 {
+			banSyncInterval = 1
+		}
+		go public.IPBanHandler.Watch(time.Duration(banSyncInterval) * time.Second)
+		public.MirrorHandler.LoadConf()
+		mirrorSyncInterval := lib.GetIntConf("proxy.mirror.sync_interval")
+		if mirrorSyncInterval <= 0 {
+			mirrorSyncInterval = 5
+		}
+		go public.MirrorHandler.Watch(time.Duration(mirrorSyncInterval) * time.Second)
 
 		go func() {
 			http_proxy_router.HttpServerRun()
@@ -56,14 +93,27 @@ func main() {
 		go func() {
 			grpc_proxy_router.GrpcServerRun()
 		}()
+		if dao.AcmeManagerHandler.Enable {
+			go dao.AcmeManagerHandler.Watch()
+		}
 
-		quit := make(chan os.Signal)
+		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 
+		if dao.AcmeManagerHandler.Enable {
+			dao.AcmeManagerHandler.Stop()
+		}
+		dao.GeoIPManagerHandler.Stop()
+		dao.AppApiKeyHandler.Stop()
+		public.IPBanHandler.Stop()
 		tcp_proxy_router.TcpServerStop()
 		grpc_proxy_router.GrpcServerStop()
 		http_proxy_router.HttpServerStop()
 		http_proxy_router.HttpsServerStop()
+		//http服务停止后写入剩余的复制统计
+		public.MirrorHandler.Stop()
+		//grpc 与 gRPC-Web 共用下游连接, 全部停止后再关闭
+		reverse_proxy.GrpcUpstreamHandler.Close()
 	}
 }
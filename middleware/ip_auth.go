@@ -5,18 +5,19 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/yguilai/go-gateway/common/lib"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/public"
+	"log"
 )
 
+// IPAuthMiddleware base.http.allow_ip 支持ip、CIDR与ip范围, 启动时解析一次
 func IPAuthMiddleware() gin.HandlerFunc {
+	matcher, err := ip_matcher.New(lib.GetStringSliceConf("base.http.allow_ip"))
+	if err != nil {
+		log.Printf(" [WARN] base.http.allow_ip err:%v\n", err)
+	}
 	return func(c *gin.Context) {
-		isMatched := false
-		for _, host := range lib.GetStringSliceConf("base.http.allow_ip") {
-			if c.ClientIP() == host {
-				isMatched = true
-			}
-		}
-		if !isMatched{
+		if !matcher.Match(c.ClientIP()) {
 			public.ResponseError(c, public.InternalErrorCode, errors.New(fmt.Sprintf("%v, not in iplist", c.ClientIP())))
 			c.Abort()
 			return
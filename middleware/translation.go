@@ -5,7 +5,12 @@ import (
 	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/zh"
 	"github.com/go-playground/universal-translator"
+	"github.com/yguilai/go-gateway/geoip"
+	"github.com/yguilai/go-gateway/header_transform"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/response_rewrite"
+	"github.com/yguilai/go-gateway/url_rewrite"
 	"gopkg.in/go-playground/validator.v9"
 	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
 	zh_translations "gopkg.in/go-playground/validator.v9/translations/zh"
@@ -56,26 +61,16 @@ func TranslationMiddleware() gin.HandlerFunc {
 				return matched
 			})
 			val.RegisterValidation("valid_url_rewrite", func(fl validator.FieldLevel) bool {
-				if fl.Field().String() == "" {
-					return true
-				}
-				for _, ms := range strings.Split(fl.Field().String(), ",") {
-					if len(strings.Split(ms, " ")) != 2 {
-						return false
-					}
-				}
-				return true
+				_, err := url_rewrite.Parse(fl.Field().String())
+				return err == nil
 			})
 			val.RegisterValidation("valid_header_transfor", func(fl validator.FieldLevel) bool {
-				if fl.Field().String() == "" {
-					return true
-				}
-				for _, ms := range strings.Split(fl.Field().String(), ",") {
-					if len(strings.Split(ms, " ")) != 3 {
-						return false
-					}
-				}
-				return true
+				_, err := header_transform.Parse(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_response_rewrite", func(fl validator.FieldLevel) bool {
+				_, err := response_rewrite.Parse(fl.Field().String())
+				return err == nil
 			})
 			val.RegisterValidation("valid_ipportlist", func(fl validator.FieldLevel) bool {
 				for _, ms := range strings.Split(fl.Field().String(), ",") {
@@ -97,6 +92,34 @@ func TranslationMiddleware() gin.HandlerFunc {
 				}
 				return true
 			})
+			val.RegisterValidation("valid_ip_matcher", func(fl validator.FieldLevel) bool {
+				_, err := ip_matcher.Parse(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_geo_country", func(fl validator.FieldLevel) bool {
+				_, err := geoip.ParseCountries(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_geo_asn", func(fl validator.FieldLevel) bool {
+				_, err := geoip.ParseASNs(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_content_types", func(fl validator.FieldLevel) bool {
+				matched, _ := regexp.Match(`^(\s*[\w.+-]+/([\w.+-]+|\*)\s*(,\s*[\w.+-]+/([\w.+-]+|\*)\s*)*)?$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_schema_path", func(fl validator.FieldLevel) bool {
+				matched, _ := regexp.Match(`^/[^*\s]*\*?$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_grpc_method", func(fl validator.FieldLevel) bool {
+				matched, _ := regexp.Match(`^(\*|/[^*\s]*\*?)$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_grant_service", func(fl validator.FieldLevel) bool {
+				matched, _ := regexp.Match(`^(\*|[a-zA-Z0-9_]{6,128})$`, []byte(fl.Field().String()))
+				return matched
+			})
 			val.RegisterValidation("valid_weightlist", func(fl validator.FieldLevel) bool {
 				for _, ms := range strings.Split(fl.Field().String(), ",") {
 					if matched, _ := regexp.Match(`^\d+$`, []byte(ms)); !matched {
@@ -146,6 +169,54 @@ func TranslationMiddleware() gin.HandlerFunc {
 				t, _ := ut.T("valid_iplist", fe.Field())
 				return t
 			})
+			val.RegisterTranslation("valid_ip_matcher", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_ip_matcher", "{0} 需为ip、CIDR或ip范围, 以逗号间隔", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_ip_matcher", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_geo_country", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_geo_country", "{0} 需为两位国家码, 以逗号间隔", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_geo_country", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_geo_asn", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_geo_asn", "{0} 需为ASN编号, 以逗号间隔", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_geo_asn", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_response_rewrite", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_response_rewrite", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_response_rewrite", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_content_types", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_content_types", "{0} 需为 type/subtype 格式, 以逗号间隔", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_content_types", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_schema_path", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_schema_path", "{0} 需以/开头, 仅支持末尾*通配", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_schema_path", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_grpc_method", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_grpc_method", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_grpc_method", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_grant_service", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_grant_service", "{0} 必须是服务名称或*", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_grant_service", fe.Field())
+				return t
+			})
 			val.RegisterTranslation("valid_weightlist", trans, func(ut ut.Translator) error {
 				return ut.Add("valid_weightlist", "{0} 不符合输入格式", true)
 			}, func(ut ut.Translator, fe validator.FieldError) string {
@@ -0,0 +1,141 @@
+package public
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"sync"
+	"time"
+)
+
+const (
+	AcmeChallengePath = "/.well-known/acme-challenge/"
+
+	RedisAcmeChallengePrefix = "acme_challenge_"
+	RedisAcmeLockPrefix      = "acme_lock_"
+)
+
+// AcmeStore 保存HTTP-01验证内容, ACME服务器可能访问任意一个网关节点, 因此多节点共享
+// Lock 保证同一时刻只有一个节点向ACME服务器申请证书, owner 区分持有者
+// 只有持有者可以 Refresh 延长锁或 Unlock, 避免锁过期后误删其他节点的锁
+type AcmeStore interface {
+	SetChallenge(token, keyAuth string, expires int64) error
+	GetChallenge(token string) (string, error)
+	Lock(name, owner string, expires int64) (bool, error)
+	Refresh(name, owner string, expires int64) (bool, error)
+	Unlock(name, owner string) error
+}
+
+// 持有者一致时才延长或删除锁
+const (
+	acmeRefreshScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("EXPIRE", KEYS[1], ARGV[2]) end return 0`
+	acmeUnlockScript  = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+)
+
+type RedisAcmeStore struct{}
+
+func (s *RedisAcmeStore) SetChallenge(token, keyAuth string, expires int64) error {
+	_, err := RedisConfDo("SET", RedisAcmeChallengePrefix+token, keyAuth, "EX", expires)
+	return err
+}
+
+// GetChallenge token不存在时返回空串
+func (s *RedisAcmeStore) GetChallenge(token string) (string, error) {
+	keyAuth, err := redis.String(RedisConfDo("GET", RedisAcmeChallengePrefix+token))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return keyAuth, err
+}
+
+func (s *RedisAcmeStore) Lock(name, owner string, expires int64) (bool, error) {
+	reply, err := redis.String(RedisConfDo("SET", RedisAcmeLockPrefix+name, owner, "EX", expires, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+// Refresh 返回false表示锁已过期或被其他节点持有
+func (s *RedisAcmeStore) Refresh(name, owner string, expires int64) (bool, error) {
+	reply, err := redis.Int(RedisConfDo("EVAL", acmeRefreshScript, 1, RedisAcmeLockPrefix+name, owner, expires))
+	return reply == 1, err
+}
+
+func (s *RedisAcmeStore) Unlock(name, owner string) error {
+	_, err := RedisConfDo("EVAL", acmeUnlockScript, 1, RedisAcmeLockPrefix+name, owner)
+	return err
+}
+
+// MemoryAcmeStore 单机使用
+type MemoryAcmeStore struct {
+	ChallengeMap map[string]string
+	LockMap      map[string]memoryAcmeLock
+	Locker       sync.Mutex
+}
+
+type memoryAcmeLock struct {
+	owner    string
+	expireAt time.Time
+}
+
+func NewMemoryAcmeStore() *MemoryAcmeStore {
+	return &MemoryAcmeStore{
+		ChallengeMap: map[string]string{},
+		LockMap:      map[string]memoryAcmeLock{},
+		Locker:       sync.Mutex{},
+	}
+}
+
+func (s *MemoryAcmeStore) SetChallenge(token, keyAuth string, expires int64) error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.ChallengeMap[token] = keyAuth
+	return nil
+}
+
+func (s *MemoryAcmeStore) GetChallenge(token string) (string, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	return s.ChallengeMap[token], nil
+}
+
+func (s *MemoryAcmeStore) Lock(name, owner string, expires int64) (bool, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	now := time.Now()
+	if lock, ok := s.LockMap[name]; ok && now.Before(lock.expireAt) {
+		return false, nil
+	}
+	s.LockMap[name] = memoryAcmeLock{owner: owner, expireAt: now.Add(time.Duration(expires) * time.Second)}
+	return true, nil
+}
+
+func (s *MemoryAcmeStore) Refresh(name, owner string, expires int64) (bool, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	now := time.Now()
+	lock, ok := s.LockMap[name]
+	if !ok || lock.owner != owner || !now.Before(lock.expireAt) {
+		return false, nil
+	}
+	lock.expireAt = now.Add(time.Duration(expires) * time.Second)
+	s.LockMap[name] = lock
+	return true, nil
+}
+
+func (s *MemoryAcmeStore) Unlock(name, owner string) error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if lock, ok := s.LockMap[name]; ok && lock.owner == owner {
+		delete(s.LockMap, name)
+	}
+	return nil
+}
+
+var AcmeStoreHandler AcmeStore
+
+func init() {
+	AcmeStoreHandler = &RedisAcmeStore{}
+}
@@ -6,6 +6,9 @@ const (
 	AdminSessionInfoKey = "AdminSessionInfoKey"
 
 	GetGormPoolErrorCode = 1002
+	AppIPNotAllowedCode  = 3002
+	GeoNotAllowedCode    = 3003
+	IPBannedCode         = 3004
 
 	LoadTypeHTTP = 0
 	LoadTypeTCP  = 1
@@ -14,6 +17,10 @@ const (
 	HTTPRuleTypePrefixURL = 0
 	HTTPRuleTypeDomain    = 1
 
+	ForwardedPolicyAppend    = 0
+	ForwardedPolicyOverwrite = 1
+	ForwardedPolicyStrip     = 2
+
 	RedisFlowDayKey  = "flow_day_count"
 	RedisFlowHourKey = "flow_hour_count"
 
@@ -21,8 +28,24 @@ const (
 	FlowServicePrefix  = "flow_service_"
 	FlowAppPrefix = "flow_app_"
 
-	JwtSignKey = "my_sign_key"
 	JwtExpires = 60*60
+	JwtRefreshExpires = 7*24*60*60
+
+	JwtTokenTypeRefresh = "refresh"
+	RedisJwtRevokedPrefix    = "jwt_revoked_"
+	RedisJwtAppRevokedPrefix = "jwt_app_revoked_"
+	RedisApiKeyRevokedPrefix = "api_key_revoked_"
+	RedisAppUpdatedPrefix    = "app_updated_"
+
+	ApiKeyPrefix = "gk_"
+	ApiKeyHeader = "X-Api-Key"
+	ApiKeyQuery  = "api_key"
+
+	GeoCountryHeader = "X-Geo-Country"
+
+	CertExpireWarnDays = 30
+
+	ServiceScopePrefix = "service:"
 )
 
 var (
@@ -0,0 +1,354 @@
+package public
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"github.com/yguilai/go-gateway/common/lib"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	RedisIPBanPrefix      = "ip_ban_"
+	RedisIPBanIndex       = "ip_ban_index"
+	RedisIPBanCountPrefix = "ip_ban_count_"
+
+	IPBanKindUnauthorized = "unauthorized"
+	IPBanKindRateLimit    = "rate_limit"
+	IPBanKindNotFound     = "not_found"
+
+	//两次同步之间最多累计的违规ip数, 超出后新ip的违规不再计数
+	ipBanMaxPending = 100000
+)
+
+// IPBan 动态封禁记录, ExpireAt 为unix时间
+type IPBan struct {
+	IP       string `json:"ip"`
+	Reason   string `json:"reason"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+// IPBanStore 封禁记录与违规计数, 多个网关节点共享
+type IPBanStore interface {
+	Incr(key string, delta, window int64) (int64, error)
+	Ban(ban *IPBan) error
+	Unban(ip string) error
+	List(now int64) ([]*IPBan, error)
+}
+
+// RedisIPBanStore 每个ip的封禁原因单独保存并设置过期, 有序集合按过期时间索引, 便于列出未过期的封禁
+type RedisIPBanStore struct{}
+
+// Incr 计数在窗口内有效, 首次计数时设置过期
+func (s *RedisIPBanStore) Incr(key string, delta, window int64) (int64, error) {
+	count, err := redis.Int64(RedisConfDo("INCRBY", RedisIPBanCountPrefix+key, delta))
+	if err != nil {
+		return 0, err
+	}
+	if count == delta {
+		if _, err := RedisConfDo("EXPIRE", RedisIPBanCountPrefix+key, window); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (s *RedisIPBanStore) Ban(ban *IPBan) error {
+	expires := ban.ExpireAt - time.Now().Unix()
+	if expires <= 0 {
+		return nil
+	}
+	return RedisConfPipline(func(c redis.Conn) {
+		c.Send("SET", RedisIPBanPrefix+ban.IP, ban.Reason, "EX", expires)
+		c.Send("ZADD", RedisIPBanIndex, ban.ExpireAt, ban.IP)
+	})
+}
+
+func (s *RedisIPBanStore) Unban(ip string) error {
+	return RedisConfPipline(func(c redis.Conn) {
+		c.Send("DEL", RedisIPBanPrefix+ip)
+		c.Send("ZREM", RedisIPBanIndex, ip)
+	})
+}
+
+// List 同时清理索引中已过期的记录
+func (s *RedisIPBanStore) List(now int64) ([]*IPBan, error) {
+	if _, err := RedisConfDo("ZREMRANGEBYSCORE", RedisIPBanIndex, "-inf", now); err != nil {
+		return nil, err
+	}
+	values, err := redis.Strings(RedisConfDo("ZRANGEBYSCORE", RedisIPBanIndex, fmt.Sprintf("(%d", now), "+inf", "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*IPBan{}
+	args := []interface{}{}
+	for i := 0; i+1 < len(values); i += 2 {
+		expireAt, _ := strconv.ParseInt(values[i+1], 10, 64)
+		list = append(list, &IPBan{IP: values[i], ExpireAt: expireAt})
+		args = append(args, RedisIPBanPrefix+values[i])
+	}
+	if len(list) == 0 {
+		return list, nil
+	}
+	reasons, err := redis.Strings(RedisConfDo("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	for i, reason := range reasons {
+		list[i].Reason = reason
+	}
+	return list, nil
+}
+
+// MemoryIPBanStore 单机使用
+type MemoryIPBanStore struct {
+	CountMap map[string]int64
+	ResetMap map[string]int64
+	BanMap   map[string]*IPBan
+	Locker   sync.Mutex
+}
+
+func NewMemoryIPBanStore() *MemoryIPBanStore {
+	return &MemoryIPBanStore{
+		CountMap: map[string]int64{},
+		ResetMap: map[string]int64{},
+		BanMap:   map[string]*IPBan{},
+		Locker:   sync.Mutex{},
+	}
+}
+
+func (s *MemoryIPBanStore) Incr(key string, delta, window int64) (int64, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	now := time.Now().Unix()
+	if s.ResetMap[key] <= now {
+		s.CountMap[key] = 0
+		s.ResetMap[key] = now + window
+	}
+	s.CountMap[key] += delta
+	return s.CountMap[key], nil
+}
+
+func (s *MemoryIPBanStore) Ban(ban *IPBan) error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.BanMap[ban.IP] = ban
+	return nil
+}
+
+func (s *MemoryIPBanStore) Unban(ip string) error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	delete(s.BanMap, ip)
+	return nil
+}
+
+func (s *MemoryIPBanStore) List(now int64) ([]*IPBan, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	list := []*IPBan{}
+	for ip, ban := range s.BanMap {
+		if ban.ExpireAt <= now {
+			delete(s.BanMap, ip)
+			continue
+		}
+		list = append(list, ban)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ExpireAt < list[j].ExpireAt
+	})
+	return list, nil
+}
+
+var IPBanHandler *IPBanManager
+
+// IPBanManager 窗口内违规次数超过阈值的ip自动封禁
+// 代理节点定时从存储同步封禁列表到内存, 请求时只查内存; 控制台封禁与解封在同步间隔内生效
+// 违规先在内存累计, 同步时写入存储并判断是否达到阈值, 请求路径上不访问存储
+type IPBanManager struct {
+	Store     IPBanStore
+	Enable    bool
+	Window    int64
+	BanTime   int64
+	LimitMap  map[string]int64
+	BannedMap map[string]*IPBan
+	Locker    sync.RWMutex
+
+	pending       map[ipBanKey]int64
+	pendingLocker sync.Mutex
+	stop          chan struct{}
+}
+
+type ipBanKey struct {
+	ip   string
+	kind string
+}
+
+func NewIPBanManager(store IPBanStore) *IPBanManager {
+	return &IPBanManager{
+		Store:     store,
+		Window:    60,
+		BanTime:   600,
+		LimitMap:  map[string]int64{},
+		BannedMap: map[string]*IPBan{},
+		Locker:    sync.RWMutex{},
+		pending:   map[ipBanKey]int64{},
+		stop:      make(chan struct{}),
+	}
+}
+
+func init() {
+	IPBanHandler = NewIPBanManager(&RedisIPBanStore{})
+}
+
+// LoadConf 读取 proxy.ip_ban 配置, 阈值为0的违规类型不计数
+func (m *IPBanManager) LoadConf() {
+	m.Enable = lib.GetBoolConf("proxy.ip_ban.enable")
+	if window := lib.GetIntConf("proxy.ip_ban.window"); window > 0 {
+		m.Window = int64(window)
+	}
+	if banTime := lib.GetIntConf("proxy.ip_ban.ban_time"); banTime > 0 {
+		m.BanTime = int64(banTime)
+	}
+	m.LimitMap = map[string]int64{
+		IPBanKindUnauthorized: int64(lib.GetIntConf("proxy.ip_ban.unauthorized_limit")),
+		IPBanKindRateLimit:    int64(lib.GetIntConf("proxy.ip_ban.rate_limit_limit")),
+		IPBanKindNotFound:     int64(lib.GetIntConf("proxy.ip_ban.not_found_limit")),
+	}
+}
+
+func (m *IPBanManager) IsBanned(ip string) bool {
+	m.Locker.RLock()
+	defer m.Locker.RUnlock()
+	ban, ok := m.BannedMap[ip]
+	return ok && ban.ExpireAt > time.Now().Unix()
+}
+
+// Report 记录一次违规, 由 Flush 写入存储
+func (m *IPBanManager) Report(ip, kind string) {
+	if !m.Enable || m.LimitMap[kind] <= 0 || ip == "" || m.IsBanned(ip) {
+		return
+	}
+	key := ipBanKey{ip: ip, kind: kind}
+	m.pendingLocker.Lock()
+	defer m.pendingLocker.Unlock()
+	if _, ok := m.pending[key]; !ok && len(m.pending) >= ipBanMaxPending {
+		return
+	}
+	m.pending[key]++
+}
+
+// Flush 将累计的违规写入存储, 达到阈值时封禁
+// 单个ip写入失败时记录日志并继续, 未写入的计数放回待写入列表, 下次重试, 返回第一个错误
+func (m *IPBanManager) Flush() error {
+	m.pendingLocker.Lock()
+	pending := m.pending
+	m.pending = map[ipBanKey]int64{}
+	m.pendingLocker.Unlock()
+
+	var firstErr error
+	failed := map[ipBanKey]int64{}
+	for key, delta := range pending {
+		count, err := m.Store.Incr(key.kind+"_"+key.ip, delta, m.Window)
+		if err != nil {
+			log.Printf(" [ERROR] ip ban incr %s %s err:%v\n", key.ip, key.kind, err)
+			failed[key] = delta
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if count < m.LimitMap[key.kind] {
+			continue
+		}
+		log.Printf(" [WARN] ip %s banned, %s %d times in %ds\n", key.ip, key.kind, count, m.Window)
+		if err := m.Ban(key.ip, fmt.Sprintf("%s %d times in %ds", key.kind, count, m.Window), m.BanTime); err != nil {
+			log.Printf(" [ERROR] ip ban %s err:%v\n", key.ip, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if len(failed) > 0 {
+		m.pendingLocker.Lock()
+		for key, delta := range failed {
+			if _, ok := m.pending[key]; !ok && len(m.pending) >= ipBanMaxPending {
+				continue
+			}
+			m.pending[key] += delta
+		}
+		m.pendingLocker.Unlock()
+	}
+	return firstErr
+}
+
+// Ban 封禁ip, banTime 单位s
+func (m *IPBanManager) Ban(ip, reason string, banTime int64) error {
+	ban := &IPBan{IP: ip, Reason: reason, ExpireAt: time.Now().Unix() + banTime}
+	if err := m.Store.Ban(ban); err != nil {
+		return err
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.BannedMap[ip] = ban
+	return nil
+}
+
+func (m *IPBanManager) Unban(ip string) error {
+	if err := m.Store.Unban(ip); err != nil {
+		return err
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	delete(m.BannedMap, ip)
+	return nil
+}
+
+func (m *IPBanManager) List() ([]*IPBan, error) {
+	return m.Store.List(time.Now().Unix())
+}
+
+// Refresh 从存储同步封禁列表
+func (m *IPBanManager) Refresh() error {
+	list, err := m.List()
+	if err != nil {
+		return err
+	}
+	bannedMap := map[string]*IPBan{}
+	for _, ban := range list {
+		bannedMap[ban.IP] = ban
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.BannedMap = bannedMap
+	return nil
+}
+
+// Watch 定时写入违规计数并同步封禁列表, 直至调用 Stop
+func (m *IPBanManager) Watch(interval time.Duration) {
+	if err := m.Refresh(); err != nil {
+		log.Printf(" [ERROR] refresh ip ban err:%v\n", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Flush(); err != nil {
+				log.Printf(" [ERROR] flush ip ban err:%v\n", err)
+			}
+			if err := m.Refresh(); err != nil {
+				log.Printf(" [ERROR] refresh ip ban err:%v\n", err)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *IPBanManager) Stop() {
+	close(m.stop)
+}
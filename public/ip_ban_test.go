@@ -0,0 +1,44 @@
+package public
+
+import (
+	"github.com/pkg/errors"
+	"testing"
+)
+
+// failIncrStore 指定key的第一次写入失败
+type failIncrStore struct {
+	*MemoryIPBanStore
+	failKey string
+}
+
+func (s *failIncrStore) Incr(key string, delta, window int64) (int64, error) {
+	if key == s.failKey {
+		s.failKey = ""
+		return 0, errors.New("store unavailable")
+	}
+	return s.MemoryIPBanStore.Incr(key, delta, window)
+}
+
+func TestIPBanFlushRetry(t *testing.T) {
+	store := &failIncrStore{MemoryIPBanStore: NewMemoryIPBanStore(), failKey: IPBanKindNotFound + "_1.1.1.1"}
+	manager := NewIPBanManager(store)
+	manager.Enable = true
+	manager.LimitMap = map[string]int64{IPBanKindNotFound: 2}
+	for _, ip := range []string{"1.1.1.1", "1.1.1.1", "2.2.2.2", "2.2.2.2"} {
+		manager.Report(ip, IPBanKindNotFound)
+	}
+	//一个ip写入失败不影响其他ip
+	if err := manager.Flush(); err == nil {
+		t.Fatal("flush should report the store error")
+	}
+	if !manager.IsBanned("2.2.2.2") || manager.IsBanned("1.1.1.1") {
+		t.Fatal("other ips should still be flushed")
+	}
+	//未写入的计数在下次写入时重试
+	if err := manager.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.IsBanned("1.1.1.1") {
+		t.Fatal("failed counts should be retried")
+	}
+}
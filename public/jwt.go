@@ -1,26 +1,69 @@
 package public
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"strings"
 )
 
-func JwtDecode(tokenString string) (*jwt.StandardClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(JwtSignKey), nil
+// JwtClaims 租户token, Scope 为空格间隔的授权范围, 如 service:test_http
+// TokenType 为 refresh 时只能用于换取新token, 不能访问服务
+type JwtClaims struct {
+	jwt.StandardClaims
+	Scope     string `json:"scope,omitempty"`
+	TokenType string `json:"typ,omitempty"`
+}
+
+// NewJwtID 生成token的jti, 用于单个token的吊销
+func NewJwtID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (c *JwtClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// JwtDecode 按token头部的kid选择验证密钥, 未携带kid的旧token使用 LegacyKid, 未配置时拒绝
+func JwtDecode(tokenString string) (*JwtClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if kid = JwtKeyHandler.LegacyKid; kid == "" {
+				return nil, errors.New("jwt kid required")
+			}
+		}
+		key, ok := JwtKeyHandler.GetKey(kid)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("unknown jwt kid %s", kid))
+		}
+		//签名算法必须与密钥一致, 防止算法混淆
+		if token.Method.Alg() != key.Alg {
+			return nil, errors.New(fmt.Sprintf("unexpected jwt alg %s", token.Method.Alg()))
+		}
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*jwt.StandardClaims); ok {
+	if claims, ok := token.Claims.(*JwtClaims); ok {
 		return claims, nil
 	} else {
-		return nil, errors.New("token is not jwt.StandardClaims")
+		return nil, errors.New("token is not JwtClaims")
 	}
 }
 
-func JwtEncode(claims jwt.StandardClaims) (string, error) {
-	mySigningKey := []byte(JwtSignKey)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(mySigningKey)
+// JwtEncode 使用当前签发密钥生成token
+func JwtEncode(claims JwtClaims) (string, error) {
+	key, err := JwtKeyHandler.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.signKey)
 }
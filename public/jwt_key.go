@@ -0,0 +1,270 @@
+package public
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/common/lib"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+const (
+	JwtAlgHS256 = "HS256"
+	JwtAlgRS256 = "RS256"
+	JwtAlgES256 = "ES256"
+
+	// 旧版本内置并公开在源码中的密钥, 任何人都能用它伪造token, 不允许配置
+	jwtSampleSecret = "my_sign_key"
+)
+
+// JwtKey 签名密钥, HS256 使用共享密钥, RS256/ES256 使用私钥签名公钥验证
+// 只配置了公钥的密钥仅用于验证, 轮换时旧密钥保留验证直至token过期
+type JwtKey struct {
+	Kid       string
+	Alg       string
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewJwtKey 私钥与公钥为PEM格式, 配置了私钥时公钥可省略
+func NewJwtKey(kid, alg string, secret, privatePEM, publicPEM []byte) (*JwtKey, error) {
+	key := &JwtKey{Kid: kid, Alg: alg}
+	var err error
+	switch alg {
+	case JwtAlgHS256:
+		if len(secret) == 0 {
+			return nil, errors.New(fmt.Sprintf("jwt key %s miss secret", kid))
+		}
+		if string(bytes.TrimSpace(secret)) == jwtSampleSecret {
+			return nil, errors.New(fmt.Sprintf("jwt key %s uses the public sample secret", kid))
+		}
+		key.signKey, key.verifyKey = secret, secret
+		return key, nil
+	case JwtAlgRS256:
+		if len(privatePEM) > 0 {
+			var privateKey *rsa.PrivateKey
+			if privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privatePEM); err != nil {
+				return nil, errors.WithMessage(err, "jwt key "+kid)
+			}
+			key.signKey, key.verifyKey = privateKey, &privateKey.PublicKey
+		} else if len(publicPEM) > 0 {
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, errors.WithMessage(err, "jwt key "+kid)
+			}
+		}
+	case JwtAlgES256:
+		if len(privatePEM) > 0 {
+			var privateKey *ecdsa.PrivateKey
+			if privateKey, err = jwt.ParseECPrivateKeyFromPEM(privatePEM); err != nil {
+				return nil, errors.WithMessage(err, "jwt key "+kid)
+			}
+			key.signKey, key.verifyKey = privateKey, &privateKey.PublicKey
+		} else if len(publicPEM) > 0 {
+			if key.verifyKey, err = jwt.ParseECPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, errors.WithMessage(err, "jwt key "+kid)
+			}
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("jwt key %s unsupported alg %s", kid, alg))
+	}
+	if key.verifyKey == nil {
+		return nil, errors.New(fmt.Sprintf("jwt key %s miss private or public key", kid))
+	}
+	return key, nil
+}
+
+func (k *JwtKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+func (k *JwtKey) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Alg)
+}
+
+// Jwk 公钥的JWK表示, 共享密钥不会对外公开
+func (k *JwtKey) Jwk() (map[string]string, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch publicKey := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": k.Kid,
+			"alg": k.Alg,
+			"n":   encode(publicKey.N.Bytes()),
+			"e":   encode(big.NewInt(int64(publicKey.E)).Bytes()),
+		}, true
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC",
+			"use": "sig",
+			"kid": k.Kid,
+			"alg": k.Alg,
+			"crv": publicKey.Curve.Params().Name,
+			"x":   encode(padBytes(publicKey.X.Bytes(), size)),
+			"y":   encode(padBytes(publicKey.Y.Bytes(), size)),
+		}, true
+	}
+	return nil, false
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+var JwtKeyHandler *JwtKeyManager
+
+// JwtKeyManager 按kid管理签名密钥, ActiveKid 用于签发, 全部密钥可用于验证
+// 未携带kid的旧token只有配置了 LegacyKid 时才接受
+type JwtKeyManager struct {
+	KeyMap         map[string]*JwtKey
+	ActiveKid      string
+	LegacyKid      string
+	Expires        int
+	RefreshExpires int
+	Locker         sync.RWMutex
+}
+
+func NewJwtKeyManager() *JwtKeyManager {
+	return &JwtKeyManager{
+		KeyMap:         map[string]*JwtKey{},
+		Expires:        JwtExpires,
+		RefreshExpires: JwtRefreshExpires,
+		Locker:         sync.RWMutex{},
+	}
+}
+
+func init() {
+	JwtKeyHandler = NewJwtKeyManager()
+}
+
+// AddKey 添加或替换密钥
+func (m *JwtKeyManager) AddKey(key *JwtKey) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.KeyMap[key.Kid] = key
+}
+
+// SetActive 切换签发使用的密钥
+func (m *JwtKeyManager) SetActive(kid string) error {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	key, ok := m.KeyMap[kid]
+	if !ok {
+		return errors.New(fmt.Sprintf("jwt key %s not found", kid))
+	}
+	if !key.CanSign() {
+		return errors.New(fmt.Sprintf("jwt key %s has no private key", kid))
+	}
+	m.ActiveKid = kid
+	return nil
+}
+
+func (m *JwtKeyManager) GetKey(kid string) (*JwtKey, bool) {
+	m.Locker.RLock()
+	defer m.Locker.RUnlock()
+	key, ok := m.KeyMap[kid]
+	return key, ok
+}
+
+func (m *JwtKeyManager) ActiveKey() (*JwtKey, error) {
+	m.Locker.RLock()
+	defer m.Locker.RUnlock()
+	key, ok := m.KeyMap[m.ActiveKid]
+	if !ok {
+		return nil, errors.New("active jwt key not found")
+	}
+	return key, nil
+}
+
+// Check 必须有可签发的密钥, 服务启动时校验, 不通过时拒绝启动
+func (m *JwtKeyManager) Check() error {
+	if _, err := m.ActiveKey(); err != nil {
+		return errors.New("no jwt signing key configured, set jwt.base.active_kid and its key in jwt.toml or gateway_jwt_key")
+	}
+	if m.LegacyKid != "" {
+		if _, ok := m.GetKey(m.LegacyKid); !ok {
+			return errors.New(fmt.Sprintf("jwt legacy key %s not found", m.LegacyKid))
+		}
+	}
+	return nil
+}
+
+// Jwks 全部非对称密钥的公钥, 供下游自行验证网关签发的token
+func (m *JwtKeyManager) Jwks() []map[string]string {
+	m.Locker.RLock()
+	defer m.Locker.RUnlock()
+	kids := []string{}
+	for kid := range m.KeyMap {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	keys := []map[string]string{}
+	for _, kid := range kids {
+		if jwk, ok := m.KeyMap[kid].Jwk(); ok {
+			keys = append(keys, jwk)
+		}
+	}
+	return keys
+}
+
+// LoadConf 从 jwt.toml 载入密钥, 共享密钥可通过 secret_file 配置, 避免写在配置文件中
+func (m *JwtKeyManager) LoadConf() error {
+	if _, ok := lib.ViperConfMap["jwt"]; !ok {
+		return nil
+	}
+	if expires := lib.GetIntConf("jwt.base.expires"); expires > 0 {
+		m.Expires = expires
+	}
+	if refreshExpires := lib.GetIntConf("jwt.base.refresh_expires"); refreshExpires > 0 {
+		m.RefreshExpires = refreshExpires
+	}
+	for kid := range lib.GetStringMapConf("jwt.keys") {
+		prefix := "jwt.keys." + kid + "."
+		privatePEM, err := readConfPEM(prefix + "private_key")
+		if err != nil {
+			return err
+		}
+		publicPEM, err := readConfPEM(prefix + "public_key")
+		if err != nil {
+			return err
+		}
+		secret, err := readConfPEM(prefix + "secret")
+		if err != nil {
+			return err
+		}
+		//密钥文件末尾的换行不作为密钥内容
+		key, err := NewJwtKey(kid, lib.GetStringConf(prefix+"alg"), bytes.TrimSpace(secret), privatePEM, publicPEM)
+		if err != nil {
+			return err
+		}
+		m.AddKey(key)
+	}
+	m.LegacyKid = lib.GetStringConf("jwt.base.legacy_kid")
+	if activeKid := lib.GetStringConf("jwt.base.active_kid"); activeKid != "" {
+		return m.SetActive(activeKid)
+	}
+	return nil
+}
+
+// readConfPEM 支持直接配置内容, 或通过 xxx_file 配置文件路径
+func readConfPEM(key string) ([]byte, error) {
+	if content := lib.GetStringConf(key); content != "" {
+		return []byte(content), nil
+	}
+	if file := lib.GetStringConf(key + "_file"); file != "" {
+		return ioutil.ReadFile(file)
+	}
+	return nil, nil
+}
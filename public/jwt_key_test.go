@@ -0,0 +1,145 @@
+package public
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/dgrijalva/jwt-go"
+	"testing"
+)
+
+const testJwtSecret = "test_jwt_secret"
+
+// useTestJwtKey 测试使用独立的HS256密钥签发token
+func useTestJwtKey(t *testing.T) func() {
+	origin := JwtKeyHandler
+	JwtKeyHandler = NewJwtKeyManager()
+	key, err := NewJwtKey("test", JwtAlgHS256, []byte(testJwtSecret), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	JwtKeyHandler.AddKey(key)
+	if err := JwtKeyHandler.SetActive("test"); err != nil {
+		t.Fatal(err)
+	}
+	return func() { JwtKeyHandler = origin }
+}
+
+func TestJwtKeyRequired(t *testing.T) {
+	origin := JwtKeyHandler
+	defer func() { JwtKeyHandler = origin }()
+	JwtKeyHandler = NewJwtKeyManager()
+	if err := JwtKeyHandler.Check(); err == nil {
+		t.Fatal("manager without signing key should not pass check")
+	}
+	if _, err := JwtEncode(JwtClaims{}); err == nil {
+		t.Fatal("token should not be issued without signing key")
+	}
+	if _, err := NewJwtKey("sample", JwtAlgHS256, []byte(jwtSampleSecret), nil, nil); err == nil {
+		t.Fatal("public sample secret should be rejected")
+	}
+}
+
+func TestJwtKeyRotation(t *testing.T) {
+	defer useTestJwtKey(t)()
+
+	//未携带kid的旧token只有配置了 LegacyKid 时才接受
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, JwtClaims{Scope: "legacy"}).SignedString([]byte(testJwtSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecode(legacy); err == nil {
+		t.Fatal("token without kid should be rejected without legacy key")
+	}
+	JwtKeyHandler.LegacyKid = "test"
+	if err := JwtKeyHandler.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := JwtDecode(legacy); err != nil || claims.Scope != "legacy" {
+		t.Fatalf("legacy token decode failed: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})
+
+	for kid, item := range map[string]struct {
+		alg string
+		pem []byte
+	}{"rsa1": {JwtAlgRS256, rsaPEM}, "ec1": {JwtAlgES256, ecPEM}} {
+		key, err := NewJwtKey(kid, item.alg, nil, item.pem, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		JwtKeyHandler.AddKey(key)
+	}
+
+	//轮换到rsa1后, 旧密钥签发的token仍可验证
+	if err := JwtKeyHandler.SetActive("rsa1"); err != nil {
+		t.Fatal(err)
+	}
+	rsaToken, err := JwtEncode(JwtClaims{Scope: "rsa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := JwtKeyHandler.SetActive("ec1"); err != nil {
+		t.Fatal(err)
+	}
+	ecToken, err := JwtEncode(JwtClaims{Scope: "ec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for token, scope := range map[string]string{rsaToken: "rsa", ecToken: "ec", legacy: "legacy"} {
+		if claims, err := JwtDecode(token); err != nil || claims.Scope != scope {
+			t.Fatalf("decode %s token failed: %v", scope, err)
+		}
+	}
+
+	//kid与签名算法不一致时拒绝
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, JwtClaims{Scope: "forged"})
+	forged.Header["kid"] = "rsa1"
+	forgedToken, err := forged.SignedString([]byte(testJwtSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecode(forgedToken); err == nil {
+		t.Fatal("alg mismatch token should be rejected")
+	}
+
+	//JWKS 不包含共享密钥
+	jwks := JwtKeyHandler.Jwks()
+	if len(jwks) != 2 || jwks[0]["kid"] != "ec1" || jwks[1]["kid"] != "rsa1" {
+		t.Fatalf("unexpected jwks %v", jwks)
+	}
+	if jwks[0]["crv"] != "P-256" || jwks[1]["kty"] != "RSA" {
+		t.Fatalf("unexpected jwks %v", jwks)
+	}
+
+	//仅有公钥的密钥不能用于签发
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyOnly, err := NewJwtKey("rsa0", JwtAlgRS256, nil, nil, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	JwtKeyHandler.AddKey(verifyOnly)
+	if err := JwtKeyHandler.SetActive("rsa0"); err == nil {
+		t.Fatal("verify only key should not be active")
+	}
+}
@@ -0,0 +1,205 @@
+package public
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// JwtRevokeStore 吊销记录存储, 不存在的key返回0
+type JwtRevokeStore interface {
+	Get(keys ...string) ([]int64, error)
+	Set(key string, value int64, expires int64) error
+	//SetNX key不存在时写入, 已存在时返回false
+	SetNX(key string, value int64, expires int64) (bool, error)
+}
+
+// RedisJwtRevokeStore 吊销记录保存在redis中, 多个网关节点共享
+type RedisJwtRevokeStore struct{}
+
+func (s *RedisJwtRevokeStore) Get(keys ...string) ([]int64, error) {
+	args := []interface{}{}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	return redis.Int64s(RedisConfDo("MGET", args...))
+}
+
+func (s *RedisJwtRevokeStore) Set(key string, value int64, expires int64) error {
+	if expires > 0 {
+		_, err := RedisConfDo("SET", key, value, "EX", expires)
+		return err
+	}
+	_, err := RedisConfDo("SET", key, value)
+	return err
+}
+
+func (s *RedisJwtRevokeStore) SetNX(key string, value int64, expires int64) (bool, error) {
+	reply, err := redis.String(RedisConfDo("SET", key, value, "EX", expires, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+// MemoryJwtRevokeStore 单机使用的吊销记录存储
+type MemoryJwtRevokeStore struct {
+	ValueMap map[string]int64
+	Locker   sync.RWMutex
+}
+
+func NewMemoryJwtRevokeStore() *MemoryJwtRevokeStore {
+	return &MemoryJwtRevokeStore{
+		ValueMap: map[string]int64{},
+		Locker:   sync.RWMutex{},
+	}
+}
+
+func (s *MemoryJwtRevokeStore) Get(keys ...string) ([]int64, error) {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	values := make([]int64, len(keys))
+	for i, key := range keys {
+		values[i] = s.ValueMap[key]
+	}
+	return values, nil
+}
+
+func (s *MemoryJwtRevokeStore) Set(key string, value int64, expires int64) error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.ValueMap[key] = value
+	return nil
+}
+
+func (s *MemoryJwtRevokeStore) SetNX(key string, value int64, expires int64) (bool, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if s.ValueMap[key] != 0 {
+		return false, nil
+	}
+	s.ValueMap[key] = value
+	return true, nil
+}
+
+var JwtRevokeHandler *JwtRevokeManager
+
+// JwtRevokeManager 支持吊销单个token, 以及吊销租户在某一时刻之前签发的全部token
+type JwtRevokeManager struct {
+	Store JwtRevokeStore
+}
+
+func NewJwtRevokeManager(store JwtRevokeStore) *JwtRevokeManager {
+	return &JwtRevokeManager{Store: store}
+}
+
+func init() {
+	JwtRevokeHandler = NewJwtRevokeManager(&RedisJwtRevokeStore{})
+}
+
+// RevokeToken 吊销单个token, 记录保留至token过期
+// 未携带jti的旧token无法单独吊销, 吊销该租户的全部token
+func (m *JwtRevokeManager) RevokeToken(claims *JwtClaims) error {
+	if claims.Id == "" {
+		return m.RevokeApp(claims.Issuer, time.Now())
+	}
+	expires := claims.ExpiresAt - time.Now().Unix()
+	if expires <= 0 {
+		return nil
+	}
+	return m.Store.Set(RedisJwtRevokedPrefix+claims.Id, 1, expires)
+}
+
+// ClaimToken 原子地吊销只能使用一次的token, 返回false表示已被吊销或已被其他请求使用
+// 未携带jti的旧token无法单独吊销, 吊销该租户的全部token
+func (m *JwtRevokeManager) ClaimToken(claims *JwtClaims) (bool, error) {
+	if claims.Id == "" {
+		return true, m.RevokeApp(claims.Issuer, time.Now())
+	}
+	expires := claims.ExpiresAt - time.Now().Unix()
+	if expires <= 0 {
+		return false, nil
+	}
+	return m.Store.SetNX(RedisJwtRevokedPrefix+claims.Id, 1, expires)
+}
+
+// RevokeApp 租户删除或更换密钥时, 吊销at之前签发的全部token
+func (m *JwtRevokeManager) RevokeApp(appID string, at time.Time) error {
+	return m.Store.Set(RedisJwtAppRevokedPrefix+appID, at.Unix(), 0)
+}
+
+// MarkAppUpdated 控制台修改租户后记录更新时间, 网关据此判断内存中的租户信息是否已过期
+func (m *JwtRevokeManager) MarkAppUpdated(appID string, at time.Time) error {
+	return m.Store.Set(RedisAppUpdatedPrefix+appID, at.Unix(), 0)
+}
+
+// AppUpdatedAt 租户最近一次在控制台修改的时间, 未记录时为0
+func (m *JwtRevokeManager) AppUpdatedAt(appID string) (int64, error) {
+	values, err := m.Store.Get(RedisAppUpdatedPrefix + appID)
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// AppRevokedAt 租户最近一次吊销全部token的时间, 未吊销时为0
+func (m *JwtRevokeManager) AppRevokedAt(appID string) (int64, error) {
+	values, err := m.Store.Get(RedisJwtAppRevokedPrefix + appID)
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// IsRevoked 签发时间早于租户吊销时间的token均视为已吊销
+// 与吊销同一秒签发的token仍然有效, 避免更换密钥后立即签发的token被误判
+func (m *JwtRevokeManager) IsRevoked(claims *JwtClaims) (bool, error) {
+	keys := []string{RedisJwtAppRevokedPrefix + claims.Issuer}
+	if claims.Id != "" {
+		keys = append(keys, RedisJwtRevokedPrefix+claims.Id)
+	}
+	values, err := m.Store.Get(keys...)
+	if err != nil {
+		return false, err
+	}
+	if values[0] > 0 && claims.IssuedAt < values[0] {
+		return true, nil
+	}
+	return len(values) > 1 && values[1] > 0, nil
+}
+
+// RevokeApiKey 吊销api key, 记录永久保留
+func (m *JwtRevokeManager) RevokeApiKey(keyHash string) error {
+	return m.Store.Set(RedisApiKeyRevokedPrefix+keyHash, 1, 0)
+}
+
+func (m *JwtRevokeManager) IsApiKeyRevoked(keyHash string) (bool, error) {
+	values, err := m.Store.Get(RedisApiKeyRevokedPrefix + keyHash)
+	if err != nil {
+		return false, err
+	}
+	return values[0] > 0, nil
+}
+
+// JwtDecodeAccessToken 解析访问服务使用的token, 拒绝refresh_token与已吊销的token
+func JwtDecodeAccessToken(tokenString string) (*JwtClaims, error) {
+	claims, err := JwtDecode(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType == JwtTokenTypeRefresh {
+		return nil, errors.New("refresh token can not access service")
+	}
+	revoked, err := JwtRevokeHandler.IsRevoked(claims)
+	if err != nil {
+		return nil, errors.WithMessage(err, "check token revoked")
+	}
+	if revoked {
+		return nil, errors.New("token revoked")
+	}
+	return claims, nil
+}
@@ -0,0 +1,103 @@
+package public
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"testing"
+	"time"
+)
+
+func TestJwtRevoke(t *testing.T) {
+	defer useTestJwtKey(t)()
+	origin := JwtRevokeHandler
+	defer func() { JwtRevokeHandler = origin }()
+	JwtRevokeHandler = NewJwtRevokeManager(NewMemoryJwtRevokeStore())
+
+	now := time.Now()
+	past := now.Add(-2 * time.Second)
+	encode := func(id, tokenType string, issuedAt time.Time) string {
+		token, err := JwtEncode(JwtClaims{
+			StandardClaims: jwt.StandardClaims{
+				Id:        id,
+				Issuer:    "app_revoke",
+				IssuedAt:  issuedAt.Unix(),
+				ExpiresAt: now.Add(time.Hour).Unix(),
+			},
+			TokenType: tokenType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	access := encode("jti_access", "", past)
+	if _, err := JwtDecodeAccessToken(access); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecodeAccessToken(encode("jti_refresh", JwtTokenTypeRefresh, now)); err == nil {
+		t.Fatal("refresh token should not access service")
+	}
+
+	//吊销单个token
+	claims, _ := JwtDecode(access)
+	if err := JwtRevokeHandler.RevokeToken(claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecodeAccessToken(access); err == nil {
+		t.Fatal("revoked token should be rejected")
+	}
+	other := encode("jti_other", "", past)
+	if _, err := JwtDecodeAccessToken(other); err != nil {
+		t.Fatal(err)
+	}
+
+	//吊销租户此前签发的全部token, 之后签发的不受影响
+	if err := JwtRevokeHandler.RevokeApp("app_revoke", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecodeAccessToken(other); err == nil {
+		t.Fatal("token issued before app revoked should be rejected")
+	}
+	if _, err := JwtDecodeAccessToken(encode("jti_new", "", now)); err != nil {
+		t.Fatal(err)
+	}
+	//与吊销同一秒签发的token不受影响
+	if _, err := JwtDecodeAccessToken(encode("jti_same", "", now.Add(-time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if revokedAt, err := JwtRevokeHandler.AppRevokedAt("app_revoke"); err != nil || revokedAt != now.Add(-time.Second).Unix() {
+		t.Fatalf("unexpected revoked at %d %v", revokedAt, err)
+	}
+
+	if err := JwtRevokeHandler.MarkAppUpdated("app_revoke", now); err != nil {
+		t.Fatal(err)
+	}
+	if updatedAt, err := JwtRevokeHandler.AppUpdatedAt("app_revoke"); err != nil || updatedAt != now.Unix() {
+		t.Fatalf("unexpected updated at %d %v", updatedAt, err)
+	}
+}
+
+func TestJwtClaimToken(t *testing.T) {
+	manager := NewJwtRevokeManager(NewMemoryJwtRevokeStore())
+	claims := &JwtClaims{StandardClaims: jwt.StandardClaims{Id: "jti_claim", Issuer: "app_claim", ExpiresAt: time.Now().Add(time.Hour).Unix()}}
+	//并发使用同一refresh_token时只有一个请求成功
+	results := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			claimed, err := manager.ClaimToken(claims)
+			results <- err == nil && claimed
+		}()
+	}
+	claimedCount := 0
+	for i := 0; i < 10; i++ {
+		if <-results {
+			claimedCount++
+		}
+	}
+	if claimedCount != 1 {
+		t.Fatalf("want 1 claim, got %d", claimedCount)
+	}
+	if revoked, err := manager.IsRevoked(claims); err != nil || !revoked {
+		t.Fatal("claimed token should be revoked")
+	}
+}
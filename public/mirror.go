@@ -0,0 +1,200 @@
+package public
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"github.com/yguilai/go-gateway/common/lib"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RedisMirrorStatKey = "mirror_stat"
+	MirrorHeader       = "X-Gateway-Mirror"
+
+	MirrorStatTotal       = "total"
+	MirrorStatError       = "error"
+	MirrorStatDropped     = "dropped"
+	MirrorStatSkipped     = "skipped"
+	MirrorStatCompared    = "compared"
+	MirrorStatStatusDiff  = "status_diff"
+	MirrorStatLatencyDiff = "latency_diff_ms"
+)
+
+var mirrorStatFields = []string{MirrorStatTotal, MirrorStatError, MirrorStatDropped, MirrorStatSkipped,
+	MirrorStatCompared, MirrorStatStatusDiff, MirrorStatLatencyDiff}
+
+// MirrorStat 单个服务的流量复制统计, 先在内存累加, 定时写入redis供控制台查询
+// LatencyDiff 为影子请求与正常请求耗时差的累计值, 单位ms, 除以 Compared 得到平均差值
+type MirrorStat struct {
+	ServiceName string
+	counts      map[string]*int64
+}
+
+func newMirrorStat(serviceName string) *MirrorStat {
+	stat := &MirrorStat{ServiceName: serviceName, counts: map[string]*int64{}}
+	for _, field := range mirrorStatFields {
+		stat.counts[field] = new(int64)
+	}
+	return stat
+}
+
+func (s *MirrorStat) Incr(field string, delta int64) {
+	atomic.AddInt64(s.counts[field], delta)
+}
+
+func (s *MirrorStat) Get(field string) int64 {
+	return atomic.LoadInt64(s.counts[field])
+}
+
+// Compare 记录影子请求与正常请求的对比结果
+func (s *MirrorStat) Compare(primaryStatus, shadowStatus int, latencyDiff time.Duration) {
+	s.Incr(MirrorStatCompared, 1)
+	if primaryStatus != shadowStatus {
+		s.Incr(MirrorStatStatusDiff, 1)
+	}
+	s.Incr(MirrorStatLatencyDiff, int64(latencyDiff/time.Millisecond))
+}
+
+// take 取出累计值并清零
+func (s *MirrorStat) take() map[string]int64 {
+	counts := map[string]int64{}
+	for field, count := range s.counts {
+		if value := atomic.SwapInt64(count, 0); value != 0 {
+			counts[field] = value
+		}
+	}
+	return counts
+}
+
+func MirrorStatDayKey(serviceName string, t time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", RedisMirrorStatKey, t.In(lib.TimeLocation).Format("20060102"), serviceName)
+}
+
+// GetMirrorDayStat 读取服务某日的流量复制统计
+func GetMirrorDayStat(serviceName string, t time.Time) (map[string]int64, error) {
+	values, err := redis.Int64Map(RedisConfDo("HGETALL", MirrorStatDayKey(serviceName, t)))
+	if err != nil {
+		return nil, err
+	}
+	stat := map[string]int64{}
+	for _, field := range mirrorStatFields {
+		stat[field] = values[field]
+	}
+	return stat, nil
+}
+
+var MirrorHandler *MirrorManager
+
+// MirrorManager 管理各服务的复制统计, 并限制同时进行的影子请求数, 超出时丢弃复制
+type MirrorManager struct {
+	StatMap map[string]*MirrorStat
+	Locker  sync.RWMutex
+	slots   chan struct{}
+	stop    chan struct{}
+	//定时写入与停止时的写入依次执行
+	flushLocker sync.Mutex
+}
+
+func NewMirrorManager(maxConcurrency int) *MirrorManager {
+	return &MirrorManager{
+		StatMap: map[string]*MirrorStat{},
+		Locker:  sync.RWMutex{},
+		slots:   make(chan struct{}, maxConcurrency),
+		stop:    make(chan struct{}),
+	}
+}
+
+func init() {
+	MirrorHandler = NewMirrorManager(100)
+}
+
+// LoadConf 读取 proxy.mirror.max_concurrency, 需在处理请求前调用
+func (m *MirrorManager) LoadConf() {
+	if maxConcurrency := lib.GetIntConf("proxy.mirror.max_concurrency"); maxConcurrency > 0 {
+		m.slots = make(chan struct{}, maxConcurrency)
+	}
+}
+
+func (m *MirrorManager) GetStat(serviceName string) *MirrorStat {
+	m.Locker.RLock()
+	stat, ok := m.StatMap[serviceName]
+	m.Locker.RUnlock()
+	if ok {
+		return stat
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if stat, ok = m.StatMap[serviceName]; !ok {
+		stat = newMirrorStat(serviceName)
+		m.StatMap[serviceName] = stat
+	}
+	return stat
+}
+
+// TryAcquire 不等待, 没有空闲名额时返回false
+func (m *MirrorManager) TryAcquire() bool {
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *MirrorManager) Release() {
+	<-m.slots
+}
+
+// Flush 将内存中的统计累加到redis, 保留两天
+func (m *MirrorManager) Flush() error {
+	m.flushLocker.Lock()
+	defer m.flushLocker.Unlock()
+	m.Locker.RLock()
+	stats := make([]*MirrorStat, 0, len(m.StatMap))
+	for _, stat := range m.StatMap {
+		stats = append(stats, stat)
+	}
+	m.Locker.RUnlock()
+
+	now := time.Now()
+	return RedisConfPipline(func(c redis.Conn) {
+		for _, stat := range stats {
+			counts := stat.take()
+			if len(counts) == 0 {
+				continue
+			}
+			key := MirrorStatDayKey(stat.ServiceName, now)
+			for field, count := range counts {
+				c.Send("HINCRBY", key, field, count)
+			}
+			c.Send("EXPIRE", key, 86400*2)
+		}
+	})
+}
+
+// Watch 定时写入统计, 直至调用 Stop
+func (m *MirrorManager) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Flush(); err != nil {
+				log.Printf(" [ERROR] flush mirror stat err:%v\n", err)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Stop 停止定时写入, 并同步写入剩余的统计, 返回时统计已全部写入
+func (m *MirrorManager) Stop() {
+	close(m.stop)
+	if err := m.Flush(); err != nil {
+		log.Printf(" [ERROR] flush mirror stat err:%v\n", err)
+	}
+}
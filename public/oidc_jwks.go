@@ -0,0 +1,211 @@
+package public
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OidcJwks 外部身份提供方的公钥集合, 按kid索引
+type OidcJwks struct {
+	KeyMap    map[string]interface{}
+	FetchedAt time.Time
+}
+
+var OidcJwksHandler *OidcJwksManager
+
+// OidcJwksManager 按地址缓存JWKS, 过期或遇到未知kid时重新拉取
+// 拉取失败时继续使用已缓存的公钥, 避免身份提供方短暂不可用影响请求
+type OidcJwksManager struct {
+	JwksMap    map[string]*OidcJwks
+	JwksURIMap map[string]string
+	TTL        time.Duration
+	MinRefresh time.Duration
+	Client     *http.Client
+	Locker     sync.RWMutex
+}
+
+func NewOidcJwksManager() *OidcJwksManager {
+	return &OidcJwksManager{
+		JwksMap:    map[string]*OidcJwks{},
+		JwksURIMap: map[string]string{},
+		TTL:        time.Hour,
+		MinRefresh: 10 * time.Second,
+		Client:     &http.Client{Timeout: 5 * time.Second},
+		Locker:     sync.RWMutex{},
+	}
+}
+
+func init() {
+	OidcJwksHandler = NewOidcJwksManager()
+}
+
+// GetKey 获取验证公钥, kid为空且只有一个公钥时直接使用
+func (m *OidcJwksManager) GetKey(jwksURL, kid string) (interface{}, error) {
+	m.Locker.RLock()
+	jwks, ok := m.JwksMap[jwksURL]
+	m.Locker.RUnlock()
+	if ok {
+		key, found := jwks.lookup(kid)
+		age := time.Since(jwks.FetchedAt)
+		if found && age < m.TTL {
+			return key, nil
+		}
+		//未知kid时限制拉取频率, 防止伪造kid放大请求
+		if !found && age < m.MinRefresh {
+			return nil, errors.New(fmt.Sprintf("oidc kid %s not found", kid))
+		}
+	}
+
+	fresh, err := m.fetch(jwksURL)
+	if err != nil {
+		if ok {
+			if key, found := jwks.lookup(kid); found {
+				return key, nil
+			}
+		}
+		return nil, err
+	}
+	m.Locker.Lock()
+	m.JwksMap[jwksURL] = fresh
+	m.Locker.Unlock()
+	if key, found := fresh.lookup(kid); found {
+		return key, nil
+	}
+	return nil, errors.New(fmt.Sprintf("oidc kid %s not found", kid))
+}
+
+// DiscoverJwksURL 通过 issuer 的 .well-known/openid-configuration 获取 jwks_uri
+func (m *OidcJwksManager) DiscoverJwksURL(issuer string) (string, error) {
+	m.Locker.RLock()
+	jwksURL, ok := m.JwksURIMap[issuer]
+	m.Locker.RUnlock()
+	if ok {
+		return jwksURL, nil
+	}
+
+	config := struct {
+		JwksURI string `json:"jwks_uri"`
+	}{}
+	if err := m.getJSON(strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &config); err != nil {
+		return "", err
+	}
+	if config.JwksURI == "" {
+		return "", errors.New("oidc discovery miss jwks_uri")
+	}
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.JwksURIMap[issuer] = config.JwksURI
+	return config.JwksURI, nil
+}
+
+func (m *OidcJwksManager) fetch(jwksURL string) (*OidcJwks, error) {
+	body := struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}{}
+	if err := m.getJSON(jwksURL, &body); err != nil {
+		return nil, err
+	}
+	jwks := &OidcJwks{KeyMap: map[string]interface{}{}, FetchedAt: time.Now()}
+	for _, item := range body.Keys {
+		//跳过加密用途与不支持的公钥
+		if use, _ := item["use"].(string); use != "" && use != "sig" {
+			continue
+		}
+		key, err := ParseJwk(item)
+		if err != nil {
+			continue
+		}
+		kid, _ := item["kid"].(string)
+		jwks.KeyMap[kid] = key
+	}
+	if len(jwks.KeyMap) == 0 {
+		return nil, errors.New("oidc jwks has no usable key")
+	}
+	return jwks, nil
+}
+
+func (m *OidcJwksManager) getJSON(url string, v interface{}) error {
+	resp, err := m.Client.Get(url)
+	if err != nil {
+		return errors.WithMessage(err, "oidc fetch "+url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("oidc fetch %s status %d", url, resp.StatusCode))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.WithMessage(err, "oidc decode "+url)
+	}
+	return nil
+}
+
+func (j *OidcJwks) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(j.KeyMap) == 1 {
+		for _, key := range j.KeyMap {
+			return key, true
+		}
+	}
+	key, ok := j.KeyMap[kid]
+	return key, ok
+}
+
+// ParseJwk 解析RSA与EC公钥
+func ParseJwk(jwk map[string]interface{}) (interface{}, error) {
+	decode := func(name string) (*big.Int, error) {
+		value, _ := jwk[name].(string)
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("jwk invalid " + name)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	kty, _ := jwk["kty"].(string)
+	switch kty {
+	case "RSA":
+		n, err := decode("n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		crv, _ := jwk["crv"].(string)
+		switch crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("jwk unsupported crv " + crv)
+		}
+		x, err := decode("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y")
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("jwk point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.New("jwk unsupported kty " + kty)
+}
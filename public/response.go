@@ -32,6 +32,11 @@ type Response struct {
 }
 
 func ResponseError(c *gin.Context, code ResponseCode, err error) {
+	ResponseErrorWithStatus(c, 200, code, err)
+}
+
+// ResponseErrorWithStatus 需要客户端按http状态码处理的错误, 如 413/415
+func ResponseErrorWithStatus(c *gin.Context, status int, code ResponseCode, err error) {
 	trace, _ := c.Get("trace")
 	traceContext, _ := trace.(*lib.TraceContext)
 	traceId := ""
@@ -45,10 +50,10 @@ func ResponseError(c *gin.Context, code ResponseCode, err error) {
 	}
 
 	resp := &Response{Code: code, Msg: err.Error(), Data: "", TraceId: traceId, Stack: stack}
-	c.JSON(200, resp)
+	c.JSON(status, resp)
 	response, _ := json.Marshal(resp)
 	c.Set("response", string(response))
-	c.AbortWithError(200, err)
+	c.AbortWithError(status, err)
 }
 
 func ResponseSuccessWithoutData(c *gin.Context) {
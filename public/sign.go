@@ -0,0 +1,139 @@
+package public
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/garyburd/redigo/redis"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 请求签名方案
+//
+// 客户端使用租户的 secret 对以下内容计算 HMAC-SHA256, 以小写十六进制放入 X-Gw-Signature:
+//
+//	METHOD + "\n" +
+//	PATH + "\n" +                  网关收到的原始路径, 不含query
+//	CANONICAL_QUERY + "\n" +       按key排序并url编码的query, 无query时为空
+//	CANONICAL_HEADERS +            X-Gw-Signed-Headers 中的每个请求头一行 "name:value\n", name小写, value去除首尾空白
+//	SIGNED_HEADERS + "\n" +        X-Gw-Signed-Headers 的值, 小写并以 ; 间隔
+//	BODY_SHA256 + "\n" +           请求体sha256的小写十六进制, 无请求体时为空串的摘要
+//	TIMESTAMP + "\n" +             X-Gw-Timestamp, unix秒, 与网关时间相差不超过 SignTimeWindow
+//	NONCE                          X-Gw-Nonce, SignTimeWindow 内不可重复
+//
+// 租户通过 X-Gw-App-Id 指定
+const (
+	SignAppIDHeader         = "X-Gw-App-Id"
+	SignTimestampHeader     = "X-Gw-Timestamp"
+	SignNonceHeader         = "X-Gw-Nonce"
+	SignSignedHeadersHeader = "X-Gw-Signed-Headers"
+	SignSignatureHeader     = "X-Gw-Signature"
+
+	SignTimeWindow = 300
+	// SignMaxBodySize 计算签名时读取的请求体默认上限, 可通过 proxy.sign.max_body_size 配置
+	SignMaxBodySize = 4 << 20
+
+	RedisSignNoncePrefix = "sign_nonce_"
+)
+
+// SignString 按签名方案拼接待签名字符串
+func SignString(req *http.Request, bodyHash string) string {
+	signedHeaders := []string{}
+	for _, name := range strings.Split(req.Header.Get(SignSignedHeadersHeader), ";") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			signedHeaders = append(signedHeaders, name)
+		}
+	}
+	query, _ := url.ParseQuery(req.URL.RawQuery)
+	builder := strings.Builder{}
+	builder.WriteString(strings.ToUpper(req.Method) + "\n")
+	builder.WriteString(req.URL.Path + "\n")
+	builder.WriteString(query.Encode() + "\n")
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if strings.EqualFold(name, "host") {
+			value = req.Host
+		}
+		builder.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	builder.WriteString(strings.Join(signedHeaders, ";") + "\n")
+	builder.WriteString(bodyHash + "\n")
+	builder.WriteString(req.Header.Get(SignTimestampHeader) + "\n")
+	builder.WriteString(req.Header.Get(SignNonceHeader))
+	return builder.String()
+}
+
+func SignBodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func HmacSign(secret, signString string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signString))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySign 常量时间比较签名
+func VerifySign(secret, signString, signature string) bool {
+	return hmac.Equal([]byte(HmacSign(secret, signString)), []byte(strings.ToLower(signature)))
+}
+
+// SignNonceStore 记录时间窗口内使用过的nonce
+type SignNonceStore interface {
+	// Add nonce未使用过时记录并返回true
+	Add(nonce string, expires int64) (bool, error)
+}
+
+// RedisSignNonceStore 多个网关节点共享nonce记录
+type RedisSignNonceStore struct{}
+
+func (s *RedisSignNonceStore) Add(nonce string, expires int64) (bool, error) {
+	reply, err := redis.String(RedisConfDo("SET", RedisSignNoncePrefix+nonce, 1, "EX", expires, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+// MemorySignNonceStore 单机使用的nonce记录
+type MemorySignNonceStore struct {
+	NonceMap map[string]time.Time
+	Locker   sync.Mutex
+}
+
+func NewMemorySignNonceStore() *MemorySignNonceStore {
+	return &MemorySignNonceStore{
+		NonceMap: map[string]time.Time{},
+		Locker:   sync.Mutex{},
+	}
+}
+
+func (s *MemorySignNonceStore) Add(nonce string, expires int64) (bool, error) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	now := time.Now()
+	for key, expireAt := range s.NonceMap {
+		if now.After(expireAt) {
+			delete(s.NonceMap, key)
+		}
+	}
+	if _, ok := s.NonceMap[nonce]; ok {
+		return false, nil
+	}
+	s.NonceMap[nonce] = now.Add(time.Duration(expires) * time.Second)
+	return true, nil
+}
+
+var SignNonceHandler SignNonceStore
+
+func init() {
+	SignNonceHandler = &RedisSignNonceStore{}
+}
@@ -0,0 +1,49 @@
+package public
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignString(t *testing.T) {
+	body := `{"name":"gateway"}`
+	req := httptest.NewRequest("post", "http://gateway.local/test_http_service/user?b=2&a=1&a=0", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignSignedHeadersHeader, "Content-Type; Host")
+	req.Header.Set(SignTimestampHeader, "1600000000")
+	req.Header.Set(SignNonceHeader, "n1")
+
+	want := "POST\n" +
+		"/test_http_service/user\n" +
+		"a=1&a=0&b=2\n" +
+		"content-type:application/json\n" +
+		"host:gateway.local\n" +
+		"content-type;host\n" +
+		SignBodyHash([]byte(body)) + "\n" +
+		"1600000000\n" +
+		"n1"
+	signString := SignString(req, SignBodyHash([]byte(body)))
+	if signString != want {
+		t.Fatalf("unexpected sign string %q", signString)
+	}
+	signature := HmacSign("secret", signString)
+	if !VerifySign("secret", signString, strings.ToUpper(signature)) {
+		t.Fatal("signature should be valid")
+	}
+	if VerifySign("other", signString, signature) {
+		t.Fatal("signature with wrong secret should be invalid")
+	}
+	req.URL.RawQuery = "a=1&b=3"
+	if VerifySign("secret", SignString(req, SignBodyHash([]byte(body))), signature) {
+		t.Fatal("tampered query should be invalid")
+	}
+
+	nonceStore := NewMemorySignNonceStore()
+	if added, _ := nonceStore.Add("app:n1", SignTimeWindow); !added {
+		t.Fatal("first nonce should be added")
+	}
+	if added, _ := nonceStore.Add("app:n1", SignTimeWindow); added {
+		t.Fatal("replayed nonce should be rejected")
+	}
+}
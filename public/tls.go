@@ -0,0 +1,31 @@
+package public
+
+import (
+	"github.com/yguilai/go-gateway/common/lib"
+	"net"
+)
+
+// ProxyCertFile 代理服务https与grpc tls使用的证书, 未配置时使用 ./cert_file 下的默认证书
+func ProxyCertFile() (string, string) {
+	certFile := lib.GetStringConf("proxy.https.cert_file")
+	if certFile == "" {
+		certFile = "./cert_file/server.crt"
+	}
+	keyFile := lib.GetStringConf("proxy.https.key_file")
+	if keyFile == "" {
+		keyFile = "./cert_file/server.key"
+	}
+	return certFile, keyFile
+}
+
+// ProxyHttpsPort http重定向到https时使用的端口, 网关通过端口映射对外提供443时需配置 redirect_port
+func ProxyHttpsPort() string {
+	if port := lib.GetStringConf("proxy.https.redirect_port"); port != "" {
+		return port
+	}
+	_, port, err := net.SplitHostPort(lib.GetStringConf("proxy.https.addr"))
+	if err != nil || port == "" {
+		return "443"
+	}
+	return port
+}
@@ -0,0 +1,470 @@
+package response_rewrite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/header_transform"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 规则格式与header转换相同, 每行或每个逗号分隔一条, 含空格或逗号的参数用双引号包裹
+// 引号内只有 \" 与 \\ 为转义, 其他反斜杠原样保留, 正则可直接书写 \d 等:
+//   replace 原文 替换为 [if content_type=text/html|application/json]
+//   regex 正则 替换为, 替换内容支持 $1 引用分组
+//   json_del 字段路径, json_rename 字段路径 新名称; 路径以点分隔, 经过数组时对每个元素生效
+// 未指定 content_type 时, 文本规则作用于文本类响应, json规则作用于json响应
+
+const (
+	ActionReplace    = "replace"
+	ActionRegex      = "regex"
+	ActionJSONDel    = "json_del"
+	ActionJSONRename = "json_rename"
+
+	// DefaultMaxBodySize 超过该大小的响应体不改写, 原样转发
+	DefaultMaxBodySize = 4 << 20
+)
+
+var (
+	textTypes = []string{"text/*", "application/json", "application/*+json", "application/javascript", "application/xml", "application/*+xml"}
+	jsonTypes = []string{"application/json", "application/*+json"}
+
+	// streamTypes 流式响应逐块转发, 不能缓冲改写
+	streamTypes = []string{"text/event-stream", "application/grpc", "application/grpc+*", "application/x-ndjson", "multipart/x-mixed-replace"}
+)
+
+type RuleSet struct {
+	rules []*rule
+}
+
+type rule struct {
+	action       string
+	old          []byte
+	new          []byte
+	regexp       *regexp.Regexp
+	keys         []string
+	newName      string
+	contentTypes []string
+}
+
+func Parse(text string) (*RuleSet, error) {
+	set := &RuleSet{}
+	items, err := header_transform.ScanRaw(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, tokens := range items {
+		r, err := parseRule(tokens)
+		if err != nil {
+			return nil, errors.WithMessage(err, strings.Join(tokens, " "))
+		}
+		set.rules = append(set.rules, r)
+	}
+	return set, nil
+}
+
+func (s *RuleSet) Empty() bool {
+	return s == nil || len(s.rules) == 0
+}
+
+func parseRule(tokens []string) (*rule, error) {
+	r := &rule{action: tokens[0]}
+	args := tokens[1:]
+	for i, token := range args {
+		if token != "if" {
+			continue
+		}
+		if i != len(args)-2 || !strings.HasPrefix(args[i+1], "content_type=") {
+			return nil, errors.New("only one content_type condition is supported")
+		}
+		for _, item := range strings.Split(strings.TrimPrefix(args[i+1], "content_type="), "|") {
+			if _, err := path.Match(item, ""); err != nil || !strings.Contains(item, "/") {
+				return nil, errors.Errorf("invalid content type %s", item)
+			}
+			r.contentTypes = append(r.contentTypes, strings.ToLower(item))
+		}
+		args = args[:i]
+		break
+	}
+
+	switch r.action {
+	case ActionReplace, ActionRegex:
+		if len(args) != 2 || args[0] == "" {
+			return nil, errors.Errorf("%s requires a pattern and a replacement", r.action)
+		}
+		r.old, r.new = []byte(args[0]), []byte(args[1])
+		if r.action == ActionRegex {
+			re, err := regexp.Compile(args[0])
+			if err != nil {
+				return nil, err
+			}
+			r.regexp = re
+		}
+		if r.contentTypes == nil {
+			r.contentTypes = textTypes
+		}
+	case ActionJSONDel, ActionJSONRename:
+		if (r.action == ActionJSONDel && len(args) != 1) || (r.action == ActionJSONRename && len(args) != 2) {
+			return nil, errors.Errorf("wrong number of arguments for %s", r.action)
+		}
+		r.keys = strings.Split(args[0], ".")
+		for _, key := range r.keys {
+			if key == "" {
+				return nil, errors.Errorf("invalid json path %s", args[0])
+			}
+		}
+		if r.action == ActionJSONRename {
+			if r.newName = args[1]; r.newName == "" || strings.Contains(r.newName, ".") {
+				return nil, errors.Errorf("invalid json field name %s", r.newName)
+			}
+		}
+		if r.contentTypes == nil {
+			r.contentTypes = jsonTypes
+		}
+	default:
+		return nil, errors.Errorf("unknown action %s", r.action)
+	}
+	return r, nil
+}
+
+func (r *rule) isJSON() bool {
+	return r.action == ActionJSONDel || r.action == ActionJSONRename
+}
+
+// Match 是否有规则作用于该类型的响应
+func (s *RuleSet) Match(mediaType string) bool {
+	if s == nil {
+		return false
+	}
+	for _, r := range s.rules {
+		if matchType(r.contentTypes, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 按书写顺序执行规则, 连续的json规则只解析与序列化一次; 响应体不是合法json时跳过json规则
+func (s *RuleSet) Apply(mediaType string, body []byte) ([]byte, bool) {
+	changed := false
+	var doc interface{}
+	parsed, dirty := false, false
+	flush := func() {
+		if dirty {
+			if data, err := marshalJSON(doc); err == nil {
+				body, changed = data, true
+			}
+		}
+		parsed, dirty = false, false
+	}
+	for _, r := range s.rules {
+		if !matchType(r.contentTypes, mediaType) {
+			continue
+		}
+		if r.isJSON() {
+			if !parsed {
+				decoder := json.NewDecoder(bytes.NewReader(body))
+				decoder.UseNumber()
+				if err := decoder.Decode(&doc); err != nil {
+					continue
+				}
+				parsed = true
+			}
+			if r.applyJSON(doc) {
+				dirty = true
+			}
+			continue
+		}
+		flush()
+		var result []byte
+		if r.regexp != nil {
+			result = r.regexp.ReplaceAll(body, r.new)
+		} else {
+			result = bytes.Replace(body, r.old, r.new, -1)
+		}
+		if !bytes.Equal(result, body) {
+			body, changed = result, true
+		}
+	}
+	flush()
+	return body, changed
+}
+
+func (r *rule) applyJSON(doc interface{}) bool {
+	return walkJSON(doc, r.keys, func(obj map[string]interface{}, key string) bool {
+		value, ok := obj[key]
+		if !ok {
+			return false
+		}
+		delete(obj, key)
+		if r.action == ActionJSONRename {
+			obj[r.newName] = value
+		}
+		return true
+	})
+}
+
+// walkJSON 按路径找到字段所在的对象, 经过数组时对每个元素生效
+func walkJSON(value interface{}, keys []string, fn func(obj map[string]interface{}, key string) bool) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		changed := false
+		for _, item := range v {
+			if walkJSON(item, keys, fn) {
+				changed = true
+			}
+		}
+		return changed
+	case map[string]interface{}:
+		if len(keys) == 1 {
+			return fn(v, keys[0])
+		}
+		child, ok := v[keys[0]]
+		if !ok {
+			return false
+		}
+		return walkJSON(child, keys[1:], fn)
+	}
+	return false
+}
+
+// marshalJSON 不转义html字符, 避免改写无关内容
+func marshalJSON(doc interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// matchType 支持 text/* 与 application/*+json 形式的通配
+func matchType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, mediaType); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewriter 一次请求的响应改写配置
+type Rewriter struct {
+	Rules       *RuleSet
+	Location    bool   //将指向下游的 Location 与 Set-Cookie 改写为网关地址
+	Prefix      string //strip_uri 去掉的前缀, 下游返回的路径需要加回
+	Proto       string //客户端访问网关的协议
+	Host        string //客户端访问网关的域名
+	MaxBodySize int64
+}
+
+// Rewrite 作为 ReverseProxy 的 ModifyResponse 调用
+func (r *Rewriter) Rewrite(resp *http.Response) error {
+	if r.Location {
+		upstreamHost := ""
+		if resp.Request != nil {
+			upstreamHost = resp.Request.URL.Host
+		}
+		if location := resp.Header.Get("Location"); location != "" {
+			resp.Header.Set("Location", r.rewriteLocation(location, upstreamHost))
+		}
+		if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+			resp.Header.Del("Set-Cookie")
+			for _, cookie := range cookies {
+				resp.Header.Add("Set-Cookie", r.rewriteSetCookie(cookie, upstreamHost))
+			}
+		}
+	}
+	if r.Rules.Empty() || !r.bodyRewritable(resp) {
+		return nil
+	}
+	return r.rewriteBody(resp)
+}
+
+// rewriteLocation 指向下游的绝对地址改为网关地址, 以 / 开头的路径加回 strip_uri 前缀, 其他地址不变
+func (r *Rewriter) rewriteLocation(location, upstreamHost string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	if u.Host != "" {
+		if !strings.EqualFold(u.Host, upstreamHost) {
+			return location
+		}
+		if u.Scheme != "" {
+			u.Scheme = r.Proto
+		}
+		u.Host = r.Host
+	} else if u.Scheme != "" || !strings.HasPrefix(u.Path, "/") {
+		return location
+	}
+	u.Path = r.prefixPath(u.Path)
+	if u.RawPath != "" {
+		u.RawPath = r.prefixPath(u.RawPath)
+	}
+	return u.String()
+}
+
+// rewriteSetCookie 去掉指向下游域名的 Domain, 使cookie归属网关域名; Path 加回 strip_uri 前缀
+func (r *Rewriter) rewriteSetCookie(cookie, upstreamHost string) string {
+	upstreamName := hostname(upstreamHost)
+	parts := strings.Split(cookie, ";")
+	result := []string{parts[0]}
+	for _, part := range parts[1:] {
+		attr := strings.TrimSpace(part)
+		index := strings.Index(attr, "=")
+		if index < 0 {
+			result = append(result, part)
+			continue
+		}
+		value := strings.TrimSpace(attr[index+1:])
+		switch strings.ToLower(strings.TrimSpace(attr[:index])) {
+		case "domain":
+			if strings.EqualFold(strings.TrimPrefix(value, "."), upstreamName) {
+				continue
+			}
+		case "path":
+			if strings.HasPrefix(value, "/") {
+				part = " " + attr[:index] + "=" + r.prefixPath(value)
+			}
+		}
+		result = append(result, part)
+	}
+	return strings.Join(result, ";")
+}
+
+func (r *Rewriter) prefixPath(p string) string {
+	if r.Prefix == "" {
+		return p
+	}
+	return strings.TrimSuffix(r.Prefix, "/") + p
+}
+
+func hostname(host string) string {
+	u := url.URL{Host: host}
+	return u.Hostname()
+}
+
+// bodyRewritable 跳过协议升级、流式响应、无响应体、未知压缩方式与超出大小上限的响应
+func (r *Rewriter) bodyRewritable(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusSwitchingProtocols || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	if strings.Contains(strings.ToLower(resp.Header.Get("Connection")), "upgrade") {
+		return false
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch resp.Header.Get("Content-Encoding") {
+	case "", "identity", "gzip", "br":
+	default:
+		return false
+	}
+	if resp.ContentLength > r.maxBodySize() {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || matchType(streamTypes, mediaType) {
+		return false
+	}
+	return r.Rules.Match(mediaType)
+}
+
+func (r *Rewriter) maxBodySize() int64 {
+	if r.MaxBodySize > 0 {
+		return r.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// rewriteBody 响应体长度未知时最多读取上限大小, 超出时将已读取部分与剩余部分拼接后原样转发
+func (r *Rewriter) rewriteBody(resp *http.Response) error {
+	limit := r.maxBodySize()
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(raw)) > limit {
+		resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(raw), resp.Body), Closer: resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	encoding := resp.Header.Get("Content-Encoding")
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	body := raw
+	if plain, err := decode(encoding, raw, limit); err == nil {
+		if result, changed := r.Rules.Apply(mediaType, plain); changed {
+			if body, err = encode(encoding, result); err != nil {
+				return err
+			}
+		}
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.TransferEncoding = nil
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// decode 解压后的大小同样受上限约束
+func decode(encoding string, data []byte, limit int64) ([]byte, error) {
+	var reader io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		reader = gr
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	plain, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(plain)) > limit {
+		return nil, errors.New("decoded body too large")
+	}
+	return plain, nil
+}
+
+func encode(encoding string, data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(buf)
+	case "br":
+		writer = brotli.NewWriter(buf)
+	default:
+		return data, nil
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package response_rewrite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	rules, err := Parse(`replace "internal.local" "api.example.com"
+json_del data.password
+json_rename data.items.uid id
+regex "token=\w+" "token=***" if content_type=text/*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"host":"internal.local","data":{"password":"x","items":[{"uid":1},{"uid":2}]},"html":"<b>"}`)
+	result, changed := rules.Apply("application/json", body)
+	if !changed {
+		t.Fatal("body not changed")
+	}
+	want := `{"data":{"items":[{"id":1},{"id":2}]},"host":"api.example.com","html":"<b>"}`
+	if string(result) != want {
+		t.Fatalf("got %s", result)
+	}
+
+	result, changed = rules.Apply("text/html", []byte("see internal.local?token=abc"))
+	if !changed || string(result) != "see api.example.com?token=***" {
+		t.Fatalf("got %s", result)
+	}
+	if _, changed := rules.Apply("image/png", []byte("internal.local")); changed {
+		t.Fatal("binary body should not change")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, text := range []string{
+		"replace a",
+		`replace "" b`,
+		"regex ( b",
+		"json_del a..b",
+		"json_rename a b.c",
+		"json_del a if status=200",
+		"json_del a if content_type=json",
+		"gzip a",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q should be invalid", text)
+		}
+	}
+}
+
+func TestRewriteGzip(t *testing.T) {
+	rules, _ := Parse("replace foo bar")
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	gw.Write([]byte("foo foo"))
+	gw.Close()
+	resp := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "Content-Encoding": {"gzip"}},
+		Body:          ioutil.NopCloser(buf),
+		ContentLength: -1,
+	}
+	if err := (&Rewriter{Rules: rules}).Rewrite(resp); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := ioutil.ReadAll(gr)
+	if string(plain) != "bar bar" {
+		t.Fatalf("got %s", plain)
+	}
+}
+
+func TestRewriteTooLarge(t *testing.T) {
+	rules, _ := Parse("replace foo bar")
+	resp := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          ioutil.NopCloser(strings.NewReader("foo foo foo")),
+		ContentLength: -1,
+	}
+	if err := (&Rewriter{Rules: rules, MaxBodySize: 4}).Rewrite(resp); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "foo foo foo" {
+		t.Fatalf("got %s", body)
+	}
+}
+
+func TestRewriteLocation(t *testing.T) {
+	upstream, _ := url.Parse("http://127.0.0.1:2003/abc")
+	resp := &http.Response{
+		StatusCode: 302,
+		Header: http.Header{
+			"Location":   {"http://127.0.0.1:2003/login?next=/abc"},
+			"Set-Cookie": {"sid=1; Path=/; Domain=127.0.0.1; HttpOnly", "lang=zh; Domain=.example.org"},
+		},
+		Request: &http.Request{URL: upstream},
+	}
+	r := &Rewriter{Location: true, Prefix: "/test_http", Proto: "https", Host: "gw.example.com"}
+	if err := r.Rewrite(resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("Location"); got != "https://gw.example.com/test_http/login?next=/abc" {
+		t.Fatalf("Location %s", got)
+	}
+	cookies := resp.Header.Values("Set-Cookie")
+	if cookies[0] != "sid=1; Path=/test_http/; HttpOnly" || cookies[1] != "lang=zh; Domain=.example.org" {
+		t.Fatalf("Set-Cookie %v", cookies)
+	}
+
+	for location, want := range map[string]string{
+		"/home":                  "/test_http/home",
+		"next":                   "next",
+		"https://other.com/home": "https://other.com/home",
+	} {
+		if got := r.rewriteLocation(location, upstream.Host); got != want {
+			t.Errorf("%s: got %s, want %s", location, got, want)
+		}
+	}
+}
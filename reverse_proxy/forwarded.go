@@ -0,0 +1,94 @@
+package reverse_proxy
+
+import (
+	"github.com/yguilai/go-gateway/ip_matcher"
+	"github.com/yguilai/go-gateway/public"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedInfo 生成转发头所需的请求信息
+type ForwardedInfo struct {
+	PeerIP   string //直连网关的地址
+	ClientIP string //按可信代理解析出的客户端ip
+	Proto    string
+	Host     string
+	Trusted  bool //直连地址是否为可信代理
+}
+
+// SetForwardedHeaders 按服务的转发头策略设置 X-Forwarded-For/-Proto/-Host、X-Real-Ip 与 Forwarded
+// 追加: 在已有链路后追加直连地址; 覆盖: 丢弃已有链路只保留客户端ip; 剔除: 不向下游透露任何转发信息
+// 追加时直连地址不是可信代理则先丢弃客户端自带的转发头, 避免伪造的链路与协议传给下游
+func SetForwardedHeaders(header http.Header, info *ForwardedInfo, policy int) {
+	switch policy {
+	case public.ForwardedPolicyStrip:
+		for _, key := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Real-Ip", "Forwarded"} {
+			header.Del(key)
+		}
+	case public.ForwardedPolicyOverwrite:
+		header.Set("X-Forwarded-For", info.ClientIP)
+		header.Set("X-Forwarded-Proto", info.Proto)
+		header.Set("X-Forwarded-Host", info.Host)
+		header.Set("X-Real-Ip", info.ClientIP)
+		header.Set("Forwarded", forwardedElement(info.ClientIP, info.Proto, info.Host))
+	default:
+		if !info.Trusted {
+			for _, key := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "Forwarded"} {
+				header.Del(key)
+			}
+		}
+		if prior := header.Values("X-Forwarded-For"); len(prior) > 0 {
+			header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+info.PeerIP)
+		} else {
+			header.Set("X-Forwarded-For", info.PeerIP)
+		}
+		if header.Get("X-Forwarded-Proto") == "" {
+			header.Set("X-Forwarded-Proto", info.Proto)
+		}
+		if header.Get("X-Forwarded-Host") == "" {
+			header.Set("X-Forwarded-Host", info.Host)
+		}
+		header.Set("X-Real-Ip", info.ClientIP)
+		element := forwardedElement(info.PeerIP, info.Proto, info.Host)
+		if prior := header.Values("Forwarded"); len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		header.Set("Forwarded", element)
+	}
+}
+
+// forwardedElement 生成 RFC 7239 的 Forwarded 元素, ipv6 地址需加方括号并用引号包裹
+func forwardedElement(ip, proto, host string) string {
+	node := ip
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		node = `"[` + ip + `]"`
+	}
+	items := []string{"for=" + node}
+	if host != "" {
+		items = append(items, "host="+forwardedValue(host))
+	}
+	if proto != "" {
+		items = append(items, "proto="+proto)
+	}
+	return strings.Join(items, ";")
+}
+
+// forwardedValue host中含有token以外的字符(如ipv6端口)时需加引号
+func forwardedValue(value string) string {
+	if strings.ContainsAny(value, ":[]\" ") {
+		return `"` + strings.Replace(value, `"`, `\"`, -1) + `"`
+	}
+	return value
+}
+
+// NewForwardedInfo 由直连地址与真实客户端地址生成转发信息, 地址为 host:port 形式
+func NewForwardedInfo(peerAddr, clientAddr, proto, host string, trusted bool) *ForwardedInfo {
+	return &ForwardedInfo{
+		PeerIP:   ip_matcher.ClientIP(peerAddr),
+		ClientIP: ip_matcher.ClientIP(clientAddr),
+		Proto:    proto,
+		Host:     host,
+		Trusted:  trusted,
+	}
+}
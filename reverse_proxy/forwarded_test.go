@@ -0,0 +1,105 @@
+package reverse_proxy
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// proxyForwarded 经 NewLoadBalanceReverseProxy 转发一次请求, 返回下游收到的请求头
+// RemoteAddr 为真实ip中间件改写后的客户端地址, peer_addr 为直连网关的地址
+func proxyForwarded(t *testing.T, policy int, peerAddr string, peerTrusted bool, header http.Header) http.Header {
+	received := make(chan http.Header, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	lb := &load_balance.RandomBalance{}
+	if err := lb.Add(upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	c.Request.RemoteAddr = "2.2.2.2:5000"
+	for key, values := range header {
+		c.Request.Header[key] = values
+	}
+	c.Set("peer_addr", peerAddr)
+	c.Set("peer_trusted", peerTrusted)
+
+	NewLoadBalanceReverseProxy(c, lb, http.DefaultTransport, policy).ServeHTTP(w, c.Request)
+	if w.Code != http.StatusOK {
+		t.Fatalf("proxy status %d: %s", w.Code, w.Body.String())
+	}
+	return <-received
+}
+
+func TestForwardedHeadersThroughProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newHeader := func() http.Header {
+		header := http.Header{}
+		header.Set("X-Forwarded-For", "2.2.2.2")
+		header.Set("X-Forwarded-Proto", "https")
+		header.Set("Forwarded", "for=2.2.2.2")
+		return header
+	}
+
+	got := proxyForwarded(t, public.ForwardedPolicyAppend, "10.0.0.2:5000", true, newHeader())
+	for key, want := range map[string]string{
+		"X-Forwarded-For":   "2.2.2.2, 10.0.0.2",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "example.com",
+		"X-Real-Ip":         "2.2.2.2",
+		"Forwarded":         "for=2.2.2.2, for=10.0.0.2;host=example.com;proto=https",
+	} {
+		if v := strings.Join(got.Values(key), ", "); v != want {
+			t.Fatalf("append %s: want %q, got %q", key, want, v)
+		}
+	}
+
+	//直连地址不可信时丢弃客户端自带的转发头
+	header := newHeader()
+	header.Set("X-Forwarded-Proto", "http")
+	header.Set("X-Forwarded-Host", "evil.com")
+	got = proxyForwarded(t, public.ForwardedPolicyAppend, "2.2.2.2:5000", false, header)
+	for key, want := range map[string]string{
+		"X-Forwarded-For":   "2.2.2.2",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "example.com",
+		"X-Real-Ip":         "2.2.2.2",
+		"Forwarded":         "for=2.2.2.2;host=example.com;proto=https",
+	} {
+		if v := strings.Join(got.Values(key), ", "); v != want {
+			t.Fatalf("untrusted append %s: want %q, got %q", key, want, v)
+		}
+	}
+
+	header = newHeader()
+	header.Set("X-Forwarded-For", "6.6.6.6, 2.2.2.2")
+	got = proxyForwarded(t, public.ForwardedPolicyOverwrite, "10.0.0.2:5000", true, header)
+	if v := strings.Join(got.Values("X-Forwarded-For"), ", "); v != "2.2.2.2" {
+		t.Fatalf("overwrite X-Forwarded-For: got %q", v)
+	}
+	if v := got.Get("Forwarded"); v != "for=2.2.2.2;host=example.com;proto=https" {
+		t.Fatalf("overwrite Forwarded: got %q", v)
+	}
+
+	got = proxyForwarded(t, public.ForwardedPolicyStrip, "10.0.0.2:5000", true, newHeader())
+	for _, key := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Real-Ip", "Forwarded"} {
+		if _, ok := got[key]; ok {
+			t.Fatalf("strip %s: still present", key)
+		}
+	}
+}
+
+func TestForwardedElementIPv6(t *testing.T) {
+	if got := forwardedElement("2001:db8::1", "http", "example.com:8080"); got != `for="[2001:db8::1]";host="example.com:8080";proto=http` {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -2,26 +2,182 @@ package reverse_proxy
 
 import (
 	"context"
-	"github.com/e421083458/grpc-proxy/proxy"
+	"github.com/pkg/errors"
 	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"log"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
 )
 
-func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance) grpc.StreamHandler {
-	return func() grpc.StreamHandler {
-		nextAddr, err := lb.Get("")
+// 单次请求选择节点时最多尝试的次数, 跳过连接异常的节点
+const grpcMaxPickTimes = 3
+
+var grpcProxyStreamDesc = &grpc.StreamDesc{
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// GrpcDirector 为每个请求选择下游连接, 返回的连接由连接池管理, 调用方不负责关闭
+type GrpcDirector func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error)
+
+// GrpcUpstream 一组下游节点的负载均衡与连接池
+type GrpcUpstream struct {
+	LoadBalance load_balance.LoadBalance
+	ConnPool    *GrpcConnPool
+}
+
+// GrpcUpstreamSelector 按请求选择下游节点组
+type GrpcUpstreamSelector func(ctx context.Context, fullMethodName string) *GrpcUpstream
+
+func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance, pool *GrpcConnPool) grpc.StreamHandler {
+	upstream := &GrpcUpstream{LoadBalance: lb, ConnPool: pool}
+	return NewGrpcRouteHandler(func(ctx context.Context, fullMethodName string) *GrpcUpstream {
+		return upstream
+	})
+}
+
+// NewGrpcRouteHandler 每个请求先选择下游节点组, 再在组内负载均衡
+func NewGrpcRouteHandler(selector GrpcUpstreamSelector) grpc.StreamHandler {
+	director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
+		upstream := selector(ctx, fullMethodName)
+		if upstream == nil {
+			return nil, nil, status.Errorf(codes.Unimplemented, "no upstream for %s", fullMethodName)
+		}
+		conn, err := pickGrpcConn(grpcPeerKey(ctx), upstream.LoadBalance, upstream.ConnPool)
+		if err != nil {
+			return nil, nil, err
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		outCtx := metadata.NewOutgoingContext(ctx, md.Copy())
+		return outCtx, conn, nil
+	}
+	return NewGrpcTransparentHandler(director)
+}
+
+// grpcPeerKey 以客户端ip作为负载均衡的key
+func grpcPeerKey(ctx context.Context) string {
+	if peerCtx, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(peerCtx.Addr.String()); err == nil {
+			return host
+		}
+	}
+	return ""
+}
+
+func pickGrpcConn(key string, lb load_balance.LoadBalance, pool *GrpcConnPool) (*grpc.ClientConn, error) {
+	var fallback *grpc.ClientConn
+	for i := 0; i < grpcMaxPickTimes; i++ {
+		nextAddr, err := lb.Get(key)
+		if err != nil || nextAddr == "" {
+			break
+		}
+		conn, err := pool.Get(nextAddr)
 		if err != nil {
-			log.Fatal("get next addr fail")
+			continue
 		}
-		director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
-			c, err := grpc.DialContext(ctx, nextAddr, grpc.WithCodec(proxy.Codec()), grpc.WithInsecure())
-			md, _ := metadata.FromIncomingContext(ctx)
-			outCtx, _ := context.WithCancel(ctx)
-			outCtx = metadata.NewOutgoingContext(outCtx, md.Copy())
-			return outCtx, c, err
+		if pool.IsHealthy(conn) {
+			return conn, nil
+		}
+		if fallback == nil {
+			fallback = conn
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, status.Error(codes.Unavailable, "get next addr fail")
+}
+
+// NewGrpcTransparentHandler 透传所有未注册的grpc请求, 与 grpc-proxy 的 TransparentHandler 一致,
+// 区别在于不会在请求结束时关闭下游连接, 以便连接池复用
+func NewGrpcTransparentHandler(director GrpcDirector) grpc.StreamHandler {
+	return func(srv interface{}, serverStream grpc.ServerStream) error {
+		fullMethodName, ok := grpc.MethodFromServerStream(serverStream)
+		if !ok {
+			return status.Error(codes.Internal, "lowLevelServerStream not exists in context")
+		}
+		outgoingCtx, backendConn, err := director(serverStream.Context(), fullMethodName)
+		if err != nil {
+			return err
+		}
+
+		clientCtx, clientCancel := context.WithCancel(outgoingCtx)
+		defer clientCancel()
+		clientStream, err := grpc.NewClientStream(clientCtx, grpcProxyStreamDesc, backendConn, fullMethodName)
+		if err != nil {
+			return err
+		}
+
+		s2cErrChan := forwardServerToClient(serverStream, clientStream)
+		c2sErrChan := forwardClientToServer(clientStream, serverStream)
+		for i := 0; i < 2; i++ {
+			select {
+			case s2cErr := <-s2cErrChan:
+				if s2cErr != io.EOF {
+					return status.Errorf(codes.Internal, "failed proxying s2c: %v", s2cErr)
+				}
+				//客户端发送完毕, 继续等待下游返回
+				clientStream.CloseSend()
+			case c2sErr := <-c2sErrChan:
+				serverStream.SetTrailer(clientStream.Trailer())
+				if c2sErr != io.EOF {
+					return c2sErr
+				}
+				return nil
+			}
+		}
+		return status.Error(codes.Internal, "gRPC proxying should never reach this stage.")
+	}
+}
+
+func forwardClientToServer(src grpc.ClientStream, dst grpc.ServerStream) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		f := &GrpcFrame{}
+		for i := 0; ; i++ {
+			if i == 0 {
+				//下游header需要在第一个消息发出前写回客户端
+				md, err := src.Header()
+				if err != nil {
+					ret <- err
+					break
+				}
+				if err := dst.SendHeader(md); err != nil {
+					ret <- errors.WithMessage(err, "SendHeader")
+					break
+				}
+			}
+			if err := src.RecvMsg(f); err != nil {
+				ret <- err
+				break
+			}
+			if err := dst.SendMsg(f); err != nil {
+				ret <- err
+				break
+			}
+		}
+	}()
+	return ret
+}
+
+func forwardServerToClient(src grpc.ServerStream, dst grpc.ClientStream) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		f := &GrpcFrame{}
+		for {
+			if err := src.RecvMsg(f); err != nil {
+				ret <- err
+				break
+			}
+			if err := dst.SendMsg(f); err != nil {
+				ret <- err
+				break
+			}
 		}
-		return proxy.TransparentHandler(director)
 	}()
+	return ret
 }
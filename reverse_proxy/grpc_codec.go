@@ -0,0 +1,42 @@
+package reverse_proxy
+
+import (
+	"fmt"
+	"github.com/e421083458/grpc-proxy/proxy"
+	"google.golang.org/grpc"
+)
+
+// GrpcFrame 透传的原始grpc消息帧, 不做protobuf解析
+type GrpcFrame struct {
+	Payload []byte
+}
+
+type grpcRawCodec struct {
+	parentCodec grpc.Codec
+}
+
+// GrpcCodec 识别 GrpcFrame 直接透传字节, 其余消息交给 grpc-proxy 的 codec 处理
+func GrpcCodec() grpc.Codec {
+	return &grpcRawCodec{parentCodec: proxy.Codec()}
+}
+
+func (c *grpcRawCodec) Marshal(v interface{}) ([]byte, error) {
+	out, ok := v.(*GrpcFrame)
+	if !ok {
+		return c.parentCodec.Marshal(v)
+	}
+	return out.Payload, nil
+}
+
+func (c *grpcRawCodec) Unmarshal(data []byte, v interface{}) error {
+	dst, ok := v.(*GrpcFrame)
+	if !ok {
+		return c.parentCodec.Unmarshal(data, v)
+	}
+	dst.Payload = data
+	return nil
+}
+
+func (c *grpcRawCodec) String() string {
+	return fmt.Sprintf("gateway>%s", c.parentCodec.String())
+}
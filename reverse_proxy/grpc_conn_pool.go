@@ -0,0 +1,95 @@
+package reverse_proxy
+
+import (
+	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"log"
+	"strings"
+	"sync"
+)
+
+// GrpcConnPool 每个下游节点复用一个 grpc.ClientConn
+// 作为观察者挂载到负载均衡配置上, 节点被摘除时关闭对应连接
+type GrpcConnPool struct {
+	conf        load_balance.LoadBalanceConf
+	dialOptions []grpc.DialOption
+	connMap     map[string]*grpc.ClientConn
+	locker      sync.RWMutex
+}
+
+func NewGrpcConnPool(conf load_balance.LoadBalanceConf, opts ...grpc.DialOption) *GrpcConnPool {
+	dialOptions := []grpc.DialOption{
+		grpc.WithCodec(GrpcCodec()),
+		grpc.WithInsecure(),
+	}
+	pool := &GrpcConnPool{
+		conf:        conf,
+		dialOptions: append(dialOptions, opts...),
+		connMap:     map[string]*grpc.ClientConn{},
+	}
+	if conf != nil {
+		conf.Attach(pool)
+	}
+	return pool
+}
+
+// Get 获取节点连接, 不存在或已关闭时重新建立(非阻塞)
+func (p *GrpcConnPool) Get(addr string) (*grpc.ClientConn, error) {
+	p.locker.RLock()
+	conn, ok := p.connMap[addr]
+	p.locker.RUnlock()
+	if ok && conn.GetState() != connectivity.Shutdown {
+		return conn, nil
+	}
+
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if conn, ok := p.connMap[addr]; ok && conn.GetState() != connectivity.Shutdown {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(addr, p.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	p.connMap[addr] = conn
+	return conn, nil
+}
+
+// IsHealthy 连接处于失败或关闭状态时视为不可用
+func (p *GrpcConnPool) IsHealthy(conn *grpc.ClientConn) bool {
+	state := conn.GetState()
+	return state != connectivity.TransientFailure && state != connectivity.Shutdown
+}
+
+// Update 负载均衡配置变更时回调, 关闭已不在节点列表中的连接
+func (p *GrpcConnPool) Update() {
+	if p.conf == nil {
+		return
+	}
+	activeMap := map[string]bool{}
+	for _, item := range p.conf.GetConf() {
+		activeMap[strings.Split(item, ",")[0]] = true
+	}
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	for addr, conn := range p.connMap {
+		if activeMap[addr] {
+			continue
+		}
+		if err := conn.Close(); err != nil {
+			log.Printf(" [WARN] grpc_conn_pool close %v err:%v\n", addr, err)
+		}
+		delete(p.connMap, addr)
+	}
+}
+
+// Close 关闭所有连接
+func (p *GrpcConnPool) Close() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	for addr, conn := range p.connMap {
+		conn.Close()
+		delete(p.connMap, addr)
+	}
+}
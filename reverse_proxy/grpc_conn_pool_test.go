@@ -0,0 +1,52 @@
+package reverse_proxy
+
+import (
+	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"net"
+	"testing"
+)
+
+func TestGrpcConnPool(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	go s.Serve(lis)
+	defer s.Stop()
+
+	addr := lis.Addr().String()
+	mConf, err := load_balance.NewLoadBalanceCheckConf("%s", map[string]string{addr: "50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewGrpcConnPool(mConf)
+	defer pool.Close()
+
+	conn1, err := pool.Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn2, err := pool.Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn1 != conn2 {
+		t.Fatal("conn should be reused for the same addr")
+	}
+
+	//节点被摘除后连接应当关闭
+	mConf.UpdateConf([]string{})
+	if conn1.GetState() != connectivity.Shutdown {
+		t.Fatalf("conn state %v, want Shutdown", conn1.GetState())
+	}
+	conn3, err := pool.Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn3 == conn1 {
+		t.Fatal("closed conn should not be reused")
+	}
+}
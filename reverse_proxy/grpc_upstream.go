@@ -0,0 +1,105 @@
+package reverse_proxy
+
+import (
+	"github.com/yguilai/go-gateway/dao"
+	"sync"
+)
+
+var GrpcUpstreamHandler *GrpcUpstreamManager
+
+// GrpcServiceUpstream 服务的默认节点组, 以及方法规则配置的独立节点组
+type GrpcServiceUpstream struct {
+	ServiceName string
+	Default     *GrpcUpstream
+	Methods     map[int64]*GrpcUpstream
+}
+
+// Select 按匹配到的方法规则选择节点组, 规则未配置独立节点时使用默认节点组
+func (s *GrpcServiceUpstream) Select(rule *dao.GrpcMethodRule) *GrpcUpstream {
+	if rule != nil {
+		if upstream, ok := s.Methods[rule.ID]; ok {
+			return upstream
+		}
+	}
+	return s.Default
+}
+
+func (s *GrpcServiceUpstream) Close() {
+	s.Default.ConnPool.Close()
+	for _, upstream := range s.Methods {
+		upstream.ConnPool.Close()
+	}
+}
+
+// GrpcUpstreamManager grpc 代理与 gRPC-Web 接入共用下游连接池
+type GrpcUpstreamManager struct {
+	UpstreamMap   map[string]*GrpcServiceUpstream
+	UpstreamSlice []*GrpcServiceUpstream
+	Locker        sync.RWMutex
+}
+
+func NewGrpcUpstreamManager() *GrpcUpstreamManager {
+	return &GrpcUpstreamManager{
+		UpstreamMap:   map[string]*GrpcServiceUpstream{},
+		UpstreamSlice: []*GrpcServiceUpstream{},
+		Locker:        sync.RWMutex{},
+	}
+}
+
+func init() {
+	GrpcUpstreamHandler = NewGrpcUpstreamManager()
+}
+
+func (m *GrpcUpstreamManager) GetUpstream(service *dao.ServiceDetail) (*GrpcServiceUpstream, error) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if item, ok := m.UpstreamMap[service.Info.ServiceName]; ok {
+		return item, nil
+	}
+
+	lb, err := dao.LoadBalancerHandler.GetLoadBalancer(service)
+	if err != nil {
+		return nil, err
+	}
+	lbConf, err := dao.LoadBalancerHandler.GetLoadBalanceConf(service)
+	if err != nil {
+		return nil, err
+	}
+	item := &GrpcServiceUpstream{
+		ServiceName: service.Info.ServiceName,
+		Default: &GrpcUpstream{
+			LoadBalance: lb,
+			ConnPool:    NewGrpcConnPool(lbConf),
+		},
+		Methods: map[int64]*GrpcUpstream{},
+	}
+	for _, rule := range service.GRPCMethodRules {
+		if rule.IpList == "" {
+			continue
+		}
+		lbItem, err := dao.LoadBalancerHandler.GetGrpcMethodLoadBalancer(service, rule)
+		if err != nil {
+			item.Close()
+			return nil, err
+		}
+		item.Methods[rule.ID] = &GrpcUpstream{
+			LoadBalance: lbItem.LoadBalance,
+			ConnPool:    NewGrpcConnPool(lbItem.LoadBalanceConf),
+		}
+	}
+
+	m.UpstreamSlice = append(m.UpstreamSlice, item)
+	m.UpstreamMap[service.Info.ServiceName] = item
+	return item, nil
+}
+
+// Close 关闭所有下游连接, 服务停止时调用
+func (m *GrpcUpstreamManager) Close() {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	for _, item := range m.UpstreamSlice {
+		item.Close()
+	}
+	m.UpstreamMap = map[string]*GrpcServiceUpstream{}
+	m.UpstreamSlice = []*GrpcServiceUpstream{}
+}
@@ -0,0 +1,479 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	grpcWebFlagCompressed byte = 0x01
+	grpcWebFlagTrailer    byte = 0x80
+
+	// DefaultGrpcWebMaxBodySize 与 grpc 默认的最大接收消息一致
+	DefaultGrpcWebMaxBodySize = 4 << 20
+)
+
+// 不透传到下游 metadata 的请求头
+var grpcWebSkipHeaders = map[string]bool{
+	"accept":            true,
+	"accept-encoding":   true,
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+	"keep-alive":        true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"user-agent":        true,
+	"x-grpc-web":        true,
+	"x-user-agent":      true,
+}
+
+// GrpcWebProxy 将 gRPC-Web 请求, 以及依据 protobuf 描述文件转码的 JSON 请求, 转换为对下游的 grpc 调用
+// 请求路径为 /pkg.Service/Method, 下游连接与 grpc 代理共用
+type GrpcWebProxy struct {
+	Selector GrpcUpstreamSelector
+	Files    *protoregistry.Files //描述文件, 为空时不支持JSON转码
+
+	MaxBodySize int64 //请求体上限, 0=DefaultGrpcWebMaxBodySize
+}
+
+func NewGrpcWebProxy(selector GrpcUpstreamSelector, files *protoregistry.Files) *GrpcWebProxy {
+	return &GrpcWebProxy{Selector: selector, Files: files}
+}
+
+// readBody 一元调用需要完整读取请求体, 最多读取 MaxBodySize+1 字节
+func (p *GrpcWebProxy) readBody(req *http.Request) ([]byte, error) {
+	maxSize := p.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = DefaultGrpcWebMaxBodySize
+	}
+	if req.ContentLength > maxSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "request body exceeds %d bytes", maxSize)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if int64(len(body)) > maxSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "request body exceeds %d bytes", maxSize)
+	}
+	return body, nil
+}
+
+// IsGrpcWebRequest 包括 application/grpc-web 与 application/grpc-web-text
+func IsGrpcWebRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+func (p *GrpcWebProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if IsGrpcWebRequest(req) {
+		p.serveGrpcWeb(w, req)
+		return
+	}
+	p.serveJSON(w, req)
+}
+
+func (p *GrpcWebProxy) serveGrpcWeb(w http.ResponseWriter, req *http.Request) {
+	contentType := req.Header.Get("Content-Type")
+	isText := strings.HasPrefix(contentType, grpcWebTextContentType)
+	w.Header().Set("Content-Type", contentType)
+	if req.Method != http.MethodPost {
+		writeGrpcWebTrailersOnly(w, status.Newf(codes.Unimplemented, "method %s not allowed", req.Method))
+		return
+	}
+	fullMethod := req.URL.Path
+	if !isGrpcFullMethod(fullMethod) {
+		writeGrpcWebTrailersOnly(w, status.Newf(codes.Unimplemented, "malformed method name %q", fullMethod))
+		return
+	}
+	body, err := p.readBody(req)
+	if err != nil {
+		writeGrpcWebTrailersOnly(w, status.Convert(err))
+		return
+	}
+	if isText {
+		body, err = decodeGrpcWebText(body)
+		if err != nil {
+			writeGrpcWebTrailersOnly(w, status.New(codes.InvalidArgument, err.Error()))
+			return
+		}
+	}
+	msgs, err := readGrpcWebFrames(body)
+	if err != nil {
+		writeGrpcWebTrailersOnly(w, status.Convert(err))
+		return
+	}
+
+	ctx, cancel := p.newContext(req)
+	defer cancel()
+	conn, err := p.pickConn(ctx, req, fullMethod)
+	if err != nil {
+		writeGrpcWebTrailersOnly(w, status.Convert(err))
+		return
+	}
+	clientStream, err := grpc.NewClientStream(ctx, grpcProxyStreamDesc, conn, fullMethod)
+	if err != nil {
+		writeGrpcWebTrailersOnly(w, status.Convert(err))
+		return
+	}
+	for _, msg := range msgs {
+		//发送失败时下游状态由 RecvMsg 返回
+		if err := clientStream.SendMsg(&GrpcFrame{Payload: msg}); err != nil {
+			break
+		}
+	}
+	clientStream.CloseSend()
+	header, err := clientStream.Header()
+	if err != nil {
+		writeGrpcWebTrailersOnly(w, status.Convert(err))
+		return
+	}
+	writeMetadataHeader(w.Header(), header)
+	w.WriteHeader(http.StatusOK)
+
+	//服务端流式响应逐帧写回
+	f := &GrpcFrame{}
+	for {
+		if err = clientStream.RecvMsg(f); err != nil {
+			break
+		}
+		if writeGrpcWebFrame(w, isText, 0, f.Payload) != nil {
+			return
+		}
+	}
+	st := status.New(codes.OK, "")
+	if err != io.EOF {
+		st = status.Convert(err)
+	}
+	trailer := http.Header{}
+	writeMetadataHeader(trailer, clientStream.Trailer())
+	writeGrpcStatusHeader(trailer, st)
+	buf := &bytes.Buffer{}
+	for k, vs := range trailer {
+		for _, v := range vs {
+			fmt.Fprintf(buf, "%s: %s\r\n", strings.ToLower(k), v)
+		}
+	}
+	writeGrpcWebFrame(w, isText, grpcWebFlagTrailer, buf.Bytes())
+}
+
+func (p *GrpcWebProxy) serveJSON(w http.ResponseWriter, req *http.Request) {
+	if p.Files == nil {
+		writeGrpcJSONError(w, status.New(codes.Unimplemented, "grpc descriptor not uploaded"))
+		return
+	}
+	if req.Method != http.MethodPost {
+		writeGrpcJSONError(w, status.Newf(codes.Unimplemented, "method %s not allowed", req.Method))
+		return
+	}
+	fullMethod := req.URL.Path
+	method, err := p.findMethod(fullMethod)
+	if err != nil {
+		writeGrpcJSONError(w, status.Convert(err))
+		return
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		writeGrpcJSONError(w, status.Newf(codes.Unimplemented, "streaming method %s not supported", fullMethod))
+		return
+	}
+	body, err := p.readBody(req)
+	if err != nil {
+		writeGrpcJSONError(w, status.Convert(err))
+		return
+	}
+	in := dynamicpb.NewMessage(method.Input())
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, in); err != nil {
+			writeGrpcJSONError(w, status.New(codes.InvalidArgument, err.Error()))
+			return
+		}
+	}
+	payload, err := proto.Marshal(in)
+	if err != nil {
+		writeGrpcJSONError(w, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	ctx, cancel := p.newContext(req)
+	defer cancel()
+	conn, err := p.pickConn(ctx, req, fullMethod)
+	if err != nil {
+		writeGrpcJSONError(w, status.Convert(err))
+		return
+	}
+	var header metadata.MD
+	reply := &GrpcFrame{}
+	err = conn.Invoke(ctx, fullMethod, &GrpcFrame{Payload: payload}, reply, grpc.Header(&header))
+	writeMetadataHeader(w.Header(), header)
+	if err != nil {
+		writeGrpcJSONError(w, status.Convert(err))
+		return
+	}
+	out := dynamicpb.NewMessage(method.Output())
+	if err := proto.Unmarshal(reply.Payload, out); err != nil {
+		writeGrpcJSONError(w, status.New(codes.Internal, err.Error()))
+		return
+	}
+	data, err := (protojson.MarshalOptions{EmitUnpopulated: true}).Marshal(out)
+	if err != nil {
+		writeGrpcJSONError(w, status.New(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// findMethod 在描述文件中查找 /pkg.Service/Method 对应的方法
+func (p *GrpcWebProxy) findMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	if !isGrpcFullMethod(fullMethod) {
+		return nil, status.Errorf(codes.Unimplemented, "malformed method name %q", fullMethod)
+	}
+	parts := strings.Split(fullMethod, "/")
+	desc, err := p.Files.FindDescriptorByName(protoreflect.FullName(parts[1]))
+	if err != nil {
+		return nil, status.Errorf(codes.Unimplemented, "unknown service %s", parts[1])
+	}
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown service %s", parts[1])
+	}
+	method := serviceDesc.Methods().ByName(protoreflect.Name(parts[2]))
+	if method == nil {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+	return method, nil
+}
+
+// newContext 透传请求头为 metadata, 并设置 grpc-timeout
+func (p *GrpcWebProxy) newContext(req *http.Request) (context.Context, context.CancelFunc) {
+	ctx := metadata.NewOutgoingContext(req.Context(), grpcMetadataFromHeader(req.Header))
+	if timeout, ok := parseGrpcTimeout(req.Header.Get("grpc-timeout")); ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (p *GrpcWebProxy) pickConn(ctx context.Context, req *http.Request, fullMethod string) (*grpc.ClientConn, error) {
+	upstream := p.Selector(ctx, fullMethod)
+	if upstream == nil {
+		return nil, status.Errorf(codes.Unimplemented, "no upstream for %s", fullMethod)
+	}
+	key := ""
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		key = host
+	}
+	return pickGrpcConn(key, upstream.LoadBalance, upstream.ConnPool)
+}
+
+func isGrpcFullMethod(fullMethod string) bool {
+	parts := strings.Split(fullMethod, "/")
+	return len(parts) == 3 && parts[0] == "" && parts[1] != "" && parts[2] != ""
+}
+
+func grpcMetadataFromHeader(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range header {
+		key := strings.ToLower(k)
+		if grpcWebSkipHeaders[key] || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		for _, v := range vs {
+			if strings.HasSuffix(key, "-bin") {
+				decoded, err := base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					continue
+				}
+				v = string(decoded)
+			}
+			md.Append(key, v)
+		}
+	}
+	return md
+}
+
+func writeMetadataHeader(header http.Header, md metadata.MD) {
+	for k, vs := range md {
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			header.Add(k, v)
+		}
+	}
+}
+
+func writeGrpcStatusHeader(header http.Header, st *status.Status) {
+	header.Set("grpc-status", strconv.Itoa(int(st.Code())))
+	if st.Message() != "" {
+		header.Set("grpc-message", encodeGrpcMessage(st.Message()))
+	}
+}
+
+// writeGrpcWebTrailersOnly 未收到下游响应时, 状态直接写在响应头中
+func writeGrpcWebTrailersOnly(w http.ResponseWriter, st *status.Status) {
+	writeGrpcStatusHeader(w.Header(), st)
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeGrpcWebFrame(w http.ResponseWriter, isText bool, flag byte, payload []byte) error {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	if isText {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// readGrpcWebFrames 解析请求体中的消息帧, 帧格式: 1字节标志 + 4字节大端长度 + 消息
+func readGrpcWebFrames(data []byte) ([][]byte, error) {
+	msgs := [][]byte{}
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, status.Error(codes.InvalidArgument, "grpc-web frame truncated")
+		}
+		flag := data[0]
+		length := int(binary.BigEndian.Uint32(data[1:5]))
+		if len(data)-5 < length {
+			return nil, status.Error(codes.InvalidArgument, "grpc-web frame truncated")
+		}
+		if flag&grpcWebFlagCompressed != 0 {
+			return nil, status.Error(codes.Unimplemented, "grpc-web compressed frame not supported")
+		}
+		if flag&grpcWebFlagTrailer == 0 {
+			msgs = append(msgs, data[5:5+length])
+		}
+		data = data[5+length:]
+	}
+	return msgs, nil
+}
+
+// decodeGrpcWebText 文本模式下请求体可能由多段带填充的base64拼接而成, 按4字节分组解码
+func decodeGrpcWebText(data []byte) ([]byte, error) {
+	data = bytes.Join(bytes.Fields(data), nil)
+	if len(data)%4 != 0 {
+		return nil, errors.New("grpc-web-text body is not valid base64")
+	}
+	out := make([]byte, 0, base64.StdEncoding.DecodedLen(len(data)))
+	buf := make([]byte, 3)
+	for i := 0; i < len(data); i += 4 {
+		n, err := base64.StdEncoding.Decode(buf, data[i:i+4])
+		if err != nil {
+			return nil, errors.WithMessage(err, "grpc-web-text")
+		}
+		out = append(out, buf[:n]...)
+	}
+	return out, nil
+}
+
+// parseGrpcTimeout 解析 grpc-timeout 请求头, 如 10S 100m
+func parseGrpcTimeout(timeout string) (time.Duration, bool) {
+	if len(timeout) < 2 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[timeout[len(timeout)-1]]
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(timeout[:len(timeout)-1], 10, 64)
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return time.Duration(value) * unit, true
+}
+
+// encodeGrpcMessage grpc-message 需要对不可见字符及%做百分号编码
+func encodeGrpcMessage(msg string) string {
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// writeGrpcJSONError JSON转码时按grpc状态码返回对应的http状态码
+func writeGrpcJSONError(w http.ResponseWriter, st *status.Status) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"code":    st.Code(),
+		"message": st.Message(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(GrpcCodeToHTTPStatus(st.Code()))
+	w.Write(data)
+}
+
+func GrpcCodeToHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,167 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestGrpcWebProxy(t *testing.T) (*GrpcWebProxy, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+
+	mConf, err := load_balance.NewLoadBalanceCheckConf("%s", map[string]string{lis.Addr().String(): "50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &GrpcUpstream{
+		LoadBalance: load_balance.LoadBanlanceFactorWithConf(load_balance.LbRoundRobin, mConf),
+		ConnPool:    NewGrpcConnPool(mConf),
+	}
+
+	//模拟 protoc --include_imports --descriptor_set_out 生成的描述文件
+	fd, err := protoregistry.GlobalFiles.FindFileByPath("grpc/health/v1/health.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(fd)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewGrpcWebProxy(func(ctx context.Context, fullMethodName string) *GrpcUpstream {
+		return upstream
+	}, files)
+	return proxy, func() {
+		upstream.ConnPool.Close()
+		s.Stop()
+	}
+}
+
+func grpcWebBody(t *testing.T, msg proto.Message) []byte {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	return frame
+}
+
+func TestGrpcWebProxy(t *testing.T) {
+	proxy, stop := newTestGrpcWebProxy(t)
+	defer stop()
+
+	for _, contentType := range []string{grpcWebContentType + "+proto", grpcWebTextContentType + "+proto"} {
+		isText := strings.HasPrefix(contentType, grpcWebTextContentType)
+		body := grpcWebBody(t, &healthpb.HealthCheckRequest{})
+		if isText {
+			body = []byte(base64.StdEncoding.EncodeToString(body))
+		}
+		req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+
+		respBody := rw.Body.Bytes()
+		if isText {
+			decoded, err := decodeGrpcWebText(respBody)
+			if err != nil {
+				t.Fatal(err)
+			}
+			respBody = decoded
+		}
+		//消息帧 + trailer帧
+		if len(respBody) < 5 || respBody[0] != 0 {
+			t.Fatalf("%s unexpected response %q", contentType, rw.Body.String())
+		}
+		length := binary.BigEndian.Uint32(respBody[1:5])
+		resp := &healthpb.HealthCheckResponse{}
+		if err := proto.Unmarshal(respBody[5:5+length], resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("%s status %v, want SERVING", contentType, resp.Status)
+		}
+		trailer := respBody[5+length:]
+		if len(trailer) < 5 || trailer[0] != grpcWebFlagTrailer || !bytes.Contains(trailer[5:], []byte("grpc-status: 0\r\n")) {
+			t.Fatalf("%s unexpected trailer %q", contentType, trailer)
+		}
+	}
+
+	//未知服务, 状态写在响应头中
+	req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check",
+		bytes.NewReader(grpcWebBody(t, &healthpb.HealthCheckRequest{Service: "unknown"})))
+	req.Header.Set("Content-Type", grpcWebContentType)
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	if !bytes.Contains(rw.Body.Bytes(), []byte("grpc-status: 5\r\n")) && rw.Header().Get("grpc-status") != "5" {
+		t.Fatalf("unexpected not found response %q %v", rw.Body.String(), rw.Header())
+	}
+}
+
+func TestGrpcWebProxyJSON(t *testing.T) {
+	proxy, stop := newTestGrpcWebProxy(t)
+	defer stop()
+
+	req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader(`{"service":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	body, _ := ioutil.ReadAll(rw.Body)
+	if rw.Code != http.StatusOK || !strings.Contains(string(body), `"SERVING"`) {
+		t.Fatalf("unexpected response %d %s", rw.Code, body)
+	}
+
+	cases := map[string]int{
+		"/grpc.health.v1.Health/Watch":   http.StatusNotImplemented, //流式方法不支持JSON转码
+		"/grpc.health.v1.Health/Unknown": http.StatusNotImplemented,
+	}
+	for path, code := range cases {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+		if rw.Code != code {
+			t.Fatalf("%s code %d, want %d", path, rw.Code, code)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader(`{"service":"unknown"}`))
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("unknown service code %d, want %d", rw.Code, http.StatusNotFound)
+	}
+
+	proxy.MaxBodySize = 8
+	req = httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader(`{"service":""}`))
+	req.ContentLength = -1
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	if rw.Code != http.StatusTooManyRequests {
+		t.Fatalf("oversized body code %d, want %d", rw.Code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,58 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// 逐跳头不转发给影子节点, 与 httputil.ReverseProxy 的处理保持一致
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// NewMirrorRequest 按当前请求构造发往影子节点的请求, body 为调用方缓存的完整请求体
+// ctx 应独立于客户端请求, 以免正常响应结束后影子请求被取消
+func NewMirrorRequest(ctx context.Context, c *gin.Context, lb load_balance.LoadBalance, body []byte, forwardedPolicy int) (*http.Request, error) {
+	req := c.Request.Clone(ctx)
+	nextAddr, err := lb.Get(req.URL.String())
+	if err != nil || nextAddr == "" {
+		return nil, errors.New("get mirror addr fail")
+	}
+	for _, name := range strings.Split(req.Header.Get("Connection"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.Header.Del(name)
+		}
+	}
+	for _, name := range hopHeaders {
+		req.Header.Del(name)
+	}
+	if err := directRequest(c, req, nextAddr, forwardedPolicy); err != nil {
+		return nil, err
+	}
+	req.Header.Set(public.MirrorHeader, "1")
+
+	req.RequestURI = ""
+	req.RemoteAddr = ""
+	req.Body = http.NoBody
+	req.GetBody = nil
+	req.ContentLength = int64(len(body))
+	if len(body) > 0 {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return req, nil
+}
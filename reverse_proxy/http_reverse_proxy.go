@@ -2,7 +2,9 @@ package reverse_proxy
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/yguilai/go-gateway/header_transform"
 	"github.com/yguilai/go-gateway/public"
+	"github.com/yguilai/go-gateway/response_rewrite"
 	"github.com/yguilai/go-gateway/reverse_proxy/load_balance"
 	"net/http"
 	"net/http/httputil"
@@ -10,7 +12,8 @@ import (
 	"strings"
 )
 
-func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport) *httputil.ReverseProxy {
+func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans http.RoundTripper, forwardedPolicy int) *httputil.ReverseProxy {
+	forwardedTrans := &forwardedTransport{RoundTripper: trans}
 	//请求协调者
 	director := func(req *http.Request) {
 		nextAddr, err := lb.Get(req.URL.String())
@@ -18,54 +21,28 @@ func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, tra
 		if err != nil || nextAddr == "" {
 			panic("get next addr fail")
 		}
-		target, err := url.Parse(nextAddr)
-		if err != nil {
+		if err := directRequest(c, req, nextAddr, forwardedPolicy); err != nil {
 			panic(err)
 		}
-		targetQuery := target.RawQuery
-		req.URL.Scheme = target.Scheme
-		req.URL.Host = target.Host
-		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
-		req.Host = target.Host
-		if targetQuery == "" || req.URL.RawQuery == "" {
-			req.URL.RawQuery = targetQuery + req.URL.RawQuery
-		} else {
-			req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
-		}
-		if _, ok := req.Header["User-Agent"]; !ok {
-			req.Header.Set("User-Agent", "user-agent")
-		}
+		//ReverseProxy 会在 Director 之后把 RemoteAddr 追加到 X-Forwarded-For, 置为 nil 阻止追加, 由 forwardedTrans 写入策略生成的值
+		forwardedTrans.forwardedFor = req.Header.Get("X-Forwarded-For")
+		req.Header["X-Forwarded-For"] = nil
 	}
 
 	//更改内容
 	modifyFunc := func(resp *http.Response) error {
-		if strings.Contains(resp.Header.Get("Connection"), "Upgrade") {
-			return nil
+		//服务配置的响应体与重定向改写, 协议升级的响应不改写
+		if rewriter, ok := c.Get("response_rewriter"); ok && !strings.Contains(resp.Header.Get("Connection"), "Upgrade") {
+			if err := rewriter.(*response_rewrite.Rewriter).Rewrite(resp); err != nil {
+				return err
+			}
+		}
+		//服务配置的响应头转换规则, 在改写之后执行, 以规则设置的值为准
+		if rules, ok := c.Get("header_transform"); ok {
+			transformContext := *c.MustGet("header_transform_context").(*header_transform.Context)
+			transformContext.Status = resp.StatusCode
+			rules.(*header_transform.RuleSet).ApplyResponse(resp.Header, &transformContext)
 		}
-
-		// TODO 优化点
-		//var payload []byte
-		//var readErr error
-		//
-		//if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
-		//	gr, err := gzip.NewReader(resp.Body)
-		//	if err != nil {
-		//		return err
-		//	}
-		//	payload, readErr = ioutil.ReadAll(gr)
-		//	resp.Header.Del("Content-Encoding")
-		//} else {
-		//	payload, readErr = ioutil.ReadAll(resp.Body)
-		//}
-		//if readErr != nil {
-		//	return readErr
-		//}
-		//
-		//c.Set("status_code", resp.StatusCode)
-		//c.Set("payload", payload)
-		//resp.Body = ioutil.NopCloser(bytes.NewBuffer(payload))
-		//resp.ContentLength = int64(len(payload))
-		//resp.Header.Set("Content-Length", strconv.FormatInt(int64(len(payload)), 10))
 		return nil
 	}
 
@@ -74,7 +51,72 @@ func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, tra
 	errFunc := func(w http.ResponseWriter, r *http.Request, err error) {
 		public.ResponseError(c, 999, err)
 	}
-	return &httputil.ReverseProxy{Director: director, ModifyResponse: modifyFunc, ErrorHandler: errFunc}
+	return &httputil.ReverseProxy{Director: director, Transport: forwardedTrans, ModifyResponse: modifyFunc, ErrorHandler: errFunc}
+}
+
+// forwardedTransport 发送前写入转发策略生成的 X-Forwarded-For, 剔除策略下不写入
+type forwardedTransport struct {
+	http.RoundTripper
+	forwardedFor string
+}
+
+func (t *forwardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", t.forwardedFor)
+	}
+	if t.RoundTripper == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// directRequest 将请求指向下游节点, 并按服务策略设置转发头
+func directRequest(c *gin.Context, req *http.Request, nextAddr string, forwardedPolicy int) error {
+	target, err := url.Parse(nextAddr)
+	if err != nil {
+		return err
+	}
+	targetQuery := target.RawQuery
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
+	req.Host = target.Host
+	//url重写规则指定了Host时以规则为准
+	if host := c.GetString("rewrite_host"); host != "" {
+		req.Host = host
+	}
+	if targetQuery == "" || req.URL.RawQuery == "" {
+		req.URL.RawQuery = targetQuery + req.URL.RawQuery
+	} else {
+		req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "user-agent")
+	}
+	//转发头由服务策略决定, 未经过真实ip中间件时以 RemoteAddr 作为直连地址, 并视为不可信
+	peerAddr := c.GetString("peer_addr")
+	if peerAddr == "" {
+		peerAddr = c.Request.RemoteAddr
+	}
+	proto, host := ClientProtoHost(c)
+	SetForwardedHeaders(req.Header, NewForwardedInfo(peerAddr, c.Request.RemoteAddr, proto, host, c.GetBool("peer_trusted")), forwardedPolicy)
+	return nil
+}
+
+// ClientProtoHost 客户端访问网关时使用的协议与域名, 可信代理传入的 X-Forwarded-Proto/-Host 优先
+func ClientProtoHost(c *gin.Context) (string, string) {
+	proto := c.GetString("forwarded_proto")
+	if proto == "" {
+		proto = "http"
+		if c.Request.TLS != nil {
+			proto = "https"
+		}
+	}
+	host := c.GetString("forwarded_host")
+	if host == "" {
+		host = c.Request.Host
+	}
+	return proto, host
 }
 
 func singleJoiningSlash(a, b string) string {
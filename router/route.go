@@ -134,5 +134,29 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		controller.RegisterAppController(appGroup)
 	}
 
+	certGroup := router.Group("/certs")
+	certGroup.Use(
+		sessionMd,
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware(),
+	)
+	{
+		controller.RegisterCertController(certGroup)
+	}
+
+	ipBanGroup := router.Group("/ip_bans")
+	ipBanGroup.Use(
+		sessionMd,
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware(),
+	)
+	{
+		controller.RegisterIPBanController(ipBanGroup)
+	}
+
 	return router
 }
@@ -3,8 +3,7 @@ package tcp_proxy_middleware
 import (
 	"fmt"
 	"github.com/yguilai/go-gateway/dao"
-	"github.com/yguilai/go-gateway/public"
-	"strings"
+	"github.com/yguilai/go-gateway/ip_matcher"
 )
 
 // TCPBlackListMiddleware 匹配接入方式 基于请求信息
@@ -18,23 +17,10 @@ func TCPBlackListMiddleware() func(c *TcpSliceRouterContext) {
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
 
-		whileIpList := make([]string, 0)
-		if serviceDetail.AccessControl.WhiteList != "" {
-			whileIpList = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
-		}
-
-		blackIpList := make([]string, 0)
-		if serviceDetail.AccessControl.BlackList != "" {
-			blackIpList = strings.Split(serviceDetail.AccessControl.BlackList, ",")
-		}
-
-		splits := strings.Split(c.conn.RemoteAddr().String(), ":")
-		clientIP := ""
-		if len(splits) == 2 {
-			clientIP = splits[0]
-		}
-		if serviceDetail.AccessControl.OpenAuth == 1 && len(whileIpList) == 0 && len(blackIpList) > 0 {
-			if public.InStringSlice(blackIpList, clientIP) {
+		clientIP := ip_matcher.ClientIP(c.conn.RemoteAddr().String())
+		blackMatcher := serviceDetail.AccessControl.BlackMatcher()
+		if serviceDetail.AccessControl.OpenAuth == 1 && serviceDetail.AccessControl.WhiteMatcher().Empty() && !blackMatcher.Empty() {
+			if blackMatcher.Match(clientIP) {
 				c.conn.Write([]byte(fmt.Sprintf("%s in black ip list", clientIP)))
 				c.Abort()
 				return
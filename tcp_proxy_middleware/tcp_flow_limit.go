@@ -3,8 +3,8 @@ package tcp_proxy_middleware
 import (
 	"fmt"
 	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/ip_matcher"
 	"github.com/yguilai/go-gateway/public"
-	"strings"
 )
 
 func TCPFlowLimitMiddleware() func(c *TcpSliceRouterContext) {
@@ -33,11 +33,7 @@ func TCPFlowLimitMiddleware() func(c *TcpSliceRouterContext) {
 			}
 		}
 
-		splits := strings.Split(c.conn.RemoteAddr().String(), ":")
-		clientIP := ""
-		if len(splits) == 2 {
-			clientIP = splits[0]
-		}
+		clientIP := ip_matcher.ClientIP(c.conn.RemoteAddr().String())
 		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
 			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
@@ -48,6 +44,7 @@ func TCPFlowLimitMiddleware() func(c *TcpSliceRouterContext) {
 				return
 			}
 			if !clientLimiter.Allow() {
+				c.Set("ip_ban_kind", public.IPBanKindRateLimit)
 				c.conn.Write([]byte(fmt.Sprintf("%v flow limit %v", clientIP, serviceDetail.AccessControl.ClientIPFlowLimit), ))
 				c.Abort()
 				return
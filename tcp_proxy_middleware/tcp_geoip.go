@@ -0,0 +1,30 @@
+package tcp_proxy_middleware
+
+import (
+	"github.com/yguilai/go-gateway/dao"
+	"github.com/yguilai/go-gateway/ip_matcher"
+)
+
+// TCPGeoIPMiddleware 按国家与ASN规则校验客户端, tcp无法向下游传递国家码
+func TCPGeoIPMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		policy := serviceDetail.AccessControl.GeoPolicy()
+		if serviceDetail.AccessControl.OpenAuth == 1 && !policy.Empty() {
+			clientIP := ip_matcher.ClientIP(c.conn.RemoteAddr().String())
+			if ok, reason := policy.Allow(dao.GeoIPManagerHandler.Lookup(clientIP)); !ok {
+				c.conn.Write([]byte(clientIP + " " + reason))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
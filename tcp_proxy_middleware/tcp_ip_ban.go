@@ -0,0 +1,25 @@
+package tcp_proxy_middleware
+
+import (
+	"fmt"
+	"github.com/yguilai/go-gateway/ip_matcher"
+	"github.com/yguilai/go-gateway/public"
+)
+
+// TCPIPBanMiddleware 拒绝被动态封禁的ip, 并统计被限流的连接
+// 网关自身的拒绝由各中间件设置 ip_ban_kind 标记
+func TCPIPBanMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		clientIP := ip_matcher.ClientIP(c.conn.RemoteAddr().String())
+		if public.IPBanHandler.IsBanned(clientIP) {
+			c.conn.Write([]byte(fmt.Sprintf("%s is banned", clientIP)))
+			c.Abort()
+			return
+		}
+		c.Next()
+
+		if kind, ok := c.Get("ip_ban_kind").(string); ok && kind != "" {
+			public.IPBanHandler.Report(clientIP, kind)
+		}
+	}
+}
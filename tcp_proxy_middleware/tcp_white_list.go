@@ -3,8 +3,7 @@ package tcp_proxy_middleware
 import (
 	"fmt"
 	"github.com/yguilai/go-gateway/dao"
-	"github.com/yguilai/go-gateway/public"
-	"strings"
+	"github.com/yguilai/go-gateway/ip_matcher"
 )
 
 //匹配接入方式 基于请求信息
@@ -17,18 +16,11 @@ func TCPWhiteListMiddleware() func(c *TcpSliceRouterContext) {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
-		splits := strings.Split(c.conn.RemoteAddr().String(), ":")
-		clientIP := ""
-		if len(splits) == 2 {
-			clientIP = splits[0]
-		}
+		clientIP := ip_matcher.ClientIP(c.conn.RemoteAddr().String())
 
-		iplist := []string{}
-		if serviceDetail.AccessControl.WhiteList != "" {
-			iplist = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
-		}
-		if serviceDetail.AccessControl.OpenAuth == 1 && len(iplist) > 0 {
-			if !public.InStringSlice(iplist, clientIP) {
+		whiteMatcher := serviceDetail.AccessControl.WhiteMatcher()
+		if serviceDetail.AccessControl.OpenAuth == 1 && !whiteMatcher.Empty() {
+			if !whiteMatcher.Match(clientIP) {
 				c.conn.Write([]byte(fmt.Sprintf("%s not in white ip list", clientIP)))
 				c.Abort()
 				return
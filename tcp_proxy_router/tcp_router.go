@@ -34,10 +34,12 @@ func TcpServerRun() {
 
 			router := tcp_proxy_middleware.NewTcpSliceRouter()
 			router.Group("/").Use(
+				tcp_proxy_middleware.TCPIPBanMiddleware(),
 				tcp_proxy_middleware.TCPFlowCountMiddleware(),
 				tcp_proxy_middleware.TCPFlowLimitMiddleware(),
 				tcp_proxy_middleware.TCPWhiteListMiddleware(),
 				tcp_proxy_middleware.TCPBlackListMiddleware(),
+				tcp_proxy_middleware.TCPGeoIPMiddleware(),
 			)
 
 			routerHandler := tcp_proxy_middleware.NewTcpSliceRouterHandler(func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
@@ -0,0 +1,228 @@
+package url_rewrite
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yguilai/go-gateway/header_transform"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 规则格式, 每行或每个逗号分隔一条, 按书写顺序依次执行:
+//   regex 正则 目标 [选项...]    替换路径中匹配的部分, 目标中 $1、${name} 引用分组
+//   path 路径模板 目标 [选项...] 整段匹配, 模板中 {name} 匹配单段, 末段 {name*} 匹配剩余路径, 目标中以 {name} 引用
+// 目标可带查询参数, 如 /v2/users/{id}?from=gateway, 参数会覆盖请求中的同名参数
+// 选项: last 命中后不再执行后续规则, drop_query 丢弃请求原有的查询参数, host=域名 改写转发给下游的Host
+// 兼容旧格式 正则 替换路径
+
+const (
+	TypeRegex = "regex"
+	TypePath  = "path"
+)
+
+var (
+	paramRegexp   = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	templateParam = regexp.MustCompile(`^\{([A-Za-z_][A-Za-z0-9_]*)(\*?)\}$`)
+)
+
+// RuleSet 解析时完成正则编译, 请求时只做匹配
+type RuleSet struct {
+	rules []*rule
+}
+
+type rule struct {
+	regexp    *regexp.Regexp
+	path      string
+	query     url.Values
+	host      string
+	dropQuery bool
+	last      bool
+}
+
+func Parse(text string) (*RuleSet, error) {
+	set, errs := ParseValid(text)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return set, nil
+}
+
+// ParseValid 跳过无法解析的规则, 返回其余规则与各条规则的错误, 用于载入升级前保存的规则
+func ParseValid(text string) (*RuleSet, []error) {
+	set := &RuleSet{}
+	items, err := header_transform.Scan(text)
+	if err != nil {
+		return set, []error{err}
+	}
+	errs := []error{}
+	for _, tokens := range items {
+		r, err := parseRule(tokens)
+		if err != nil {
+			errs = append(errs, errors.WithMessage(err, strings.Join(tokens, " ")))
+			continue
+		}
+		set.rules = append(set.rules, r)
+	}
+	return set, errs
+}
+
+func (s *RuleSet) Empty() bool {
+	return s == nil || len(s.rules) == 0
+}
+
+func parseRule(tokens []string) (*rule, error) {
+	r := &rule{}
+	var pattern, target string
+	var options []string
+	var err error
+	switch {
+	case len(tokens) == 2:
+		pattern, target = tokens[0], tokens[1]
+		if r.regexp, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	case len(tokens) >= 3 && tokens[0] == TypeRegex:
+		pattern, target, options = tokens[1], tokens[2], tokens[3:]
+		if r.regexp, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	case len(tokens) >= 3 && tokens[0] == TypePath:
+		pattern, target, options = tokens[1], tokens[2], tokens[3:]
+		if r.regexp, err = compileTemplate(pattern); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("rule requires a pattern and a target")
+	}
+
+	for _, option := range options {
+		switch {
+		case option == "last":
+			r.last = true
+		case option == "drop_query":
+			r.dropQuery = true
+		case strings.HasPrefix(option, "host=") && len(option) > len("host="):
+			r.host = expandParams(strings.TrimPrefix(option, "host="))
+		default:
+			return nil, errors.Errorf("unknown option %s", option)
+		}
+	}
+
+	target = expandParams(target)
+	if index := strings.Index(target, "?"); index >= 0 {
+		if r.query, err = url.ParseQuery(target[index+1:]); err != nil {
+			return nil, err
+		}
+		target = target[:index]
+	}
+	r.path = target
+	return r, nil
+}
+
+// compileTemplate 将路径模板转换为整段匹配的正则, 参数转换为命名分组
+func compileTemplate(template string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, errors.Errorf("path template %s must start with /", template)
+	}
+	segments := strings.Split(template[1:], "/")
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	names := map[string]bool{}
+	for i, segment := range segments {
+		match := templateParam.FindStringSubmatch(segment)
+		if match == nil {
+			if strings.ContainsAny(segment, "{}") {
+				return nil, errors.Errorf("invalid path segment %s", segment)
+			}
+			expr.WriteString("/" + regexp.QuoteMeta(segment))
+			continue
+		}
+		if names[match[1]] {
+			return nil, errors.Errorf("duplicate path param %s", match[1])
+		}
+		names[match[1]] = true
+		if match[2] == "" {
+			expr.WriteString("/(?P<" + match[1] + ">[^/]+)")
+			continue
+		}
+		if i != len(segments)-1 {
+			return nil, errors.Errorf("%s must be the last segment", segment)
+		}
+		expr.WriteString("(?:/(?P<" + match[1] + ">.*))?")
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// expandParams 目标中的 {name} 转换为正则替换使用的 ${name}
+func expandParams(target string) string {
+	return paramRegexp.ReplaceAllString(target, "$${$1}")
+}
+
+// Rewrite 改写请求路径与查询参数, 返回需要转发给下游的Host, 为空表示不改写
+func (s *RuleSet) Rewrite(u *url.URL) string {
+	host := ""
+	if s == nil {
+		return host
+	}
+	for _, r := range s.rules {
+		match := r.regexp.FindStringSubmatchIndex(u.Path)
+		if match == nil {
+			continue
+		}
+		expand := func(template string) string {
+			return string(r.regexp.ExpandString(nil, template, u.Path, match))
+		}
+		if r.host != "" {
+			host = expand(r.host)
+		}
+		if r.dropQuery || len(r.query) > 0 {
+			values := url.Values{}
+			if !r.dropQuery {
+				values = u.Query()
+			}
+			for key, items := range r.query {
+				values.Del(key)
+				for _, item := range items {
+					values.Add(key, expand(item))
+				}
+			}
+			u.RawQuery = values.Encode()
+		}
+		u.Path = r.regexp.ReplaceAllString(u.Path, r.path)
+		u.RawPath = ""
+		if r.last {
+			break
+		}
+	}
+	return host
+}
+
+// StripPrefix 仅去掉路径开头按 / 分段完整匹配的前缀, 去掉后保证路径以 / 开头
+// 如前缀 /test_http 不会去掉 /test_httpx/a 的开头
+func StripPrefix(u *url.URL, prefix string) {
+	if prefix == "" || !hasPathPrefix(u.Path, prefix) {
+		return
+	}
+	u.Path = ensureSlash(strings.TrimPrefix(u.Path, prefix))
+	//编码后的路径与前缀不一致时交由 Path 重新编码
+	if hasPathPrefix(u.RawPath, prefix) {
+		u.RawPath = ensureSlash(strings.TrimPrefix(u.RawPath, prefix))
+	} else {
+		u.RawPath = ""
+	}
+}
+
+func hasPathPrefix(p, prefix string) bool {
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	return len(p) == len(prefix) || strings.HasSuffix(prefix, "/") || p[len(prefix)] == '/'
+}
+
+func ensureSlash(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		return "/" + p
+	}
+	return p
+}
@@ -0,0 +1,68 @@
+package url_rewrite
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	rules, err := Parse(`path /users/{id} /v2/users/{id}?from=gateway last
+path /t/{tenant}/{rest*} /{rest} drop_query host={tenant}.svc.local
+regex ^/api/(\w+)/(\d+) /$1/item/$2
+^/old(.*) /new$1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string][2]string{
+		"/users/42?a=1&from=x":  {"/v2/users/42?a=1&from=gateway", ""},
+		"/users/42/orders":      {"/users/42/orders", ""},
+		"/t/acme/js/app.js?v=1": {"/js/app.js", "acme.svc.local"},
+		"/api/order/7/detail":   {"/order/item/7/detail", ""},
+		"/old/api/user/1":       {"/new/api/user/1", ""},
+	} {
+		u, _ := url.Parse(raw)
+		host := rules.Rewrite(u)
+		if u.RequestURI() != want[0] || host != want[1] {
+			t.Errorf("%s: got %s %s, want %s %s", raw, u.RequestURI(), host, want[0], want[1])
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, text := range []string{
+		"/a",
+		"regex ( /b",
+		"path users/{id} /b",
+		"path /{id}/{id} /b",
+		"path /{rest*}/a /b",
+		"path /a{id} /b",
+		"path /a /b first",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q should be invalid", text)
+		}
+	}
+}
+
+func TestParseValid(t *testing.T) {
+	rules, errs := ParseValid("^/old/(.*) /new/$1,regex ( /b")
+	if len(errs) != 1 || rules.Empty() {
+		t.Fatalf("want the valid rule kept and 1 error, got %v", errs)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	for raw, want := range map[string]string{
+		"/test_http/abc":      "/abc",
+		"/test_http":          "/",
+		"/other/test_http/ab": "/other/test_http/ab",
+		"/test_http/a%2Fb":    "/a%2Fb",
+		"/test_httpx/a":       "/test_httpx/a",
+	} {
+		u, _ := url.Parse(raw)
+		StripPrefix(u, "/test_http")
+		if u.EscapedPath() != want {
+			t.Errorf("%s: got %s, want %s", raw, u.EscapedPath(), want)
+		}
+	}
+}